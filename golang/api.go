@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-sdk-examples/golang/internal/contacts"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+)
+
+// maxAPIBody limits the size of API request bodies, agreements included
+const maxAPIBody = 16 << 20
+
+// registerAPI serves the endpoints backend systems use to manage contacts and
+// start flows with them, and to look up invitations and inboxes. Requests
+// sent to peers are returned with their ID, and can be polled at
+// /requests/{id} or followed through webhooks.
+func (s *server) registerAPI(mux *http.ServeMux) {
+	mux.Handle("GET /contacts", s.requireAPIToken(s.handleListContacts))
+	mux.Handle("GET /contacts/{address}", s.requireAPIToken(s.handleGetContact))
+	mux.Handle("DELETE /contacts/{address}", s.requireAPIToken(s.handleDeleteContact))
+	mux.Handle("POST /peers/{address}/credential-requests", s.requireAPIToken(s.handleAPICredentialRequest))
+	mux.Handle("POST /peers/{address}/agreements", s.requireAPIToken(s.handleAPIAgreement))
+	mux.Handle("POST /peers/{address}/credentials", s.requireAPIToken(s.handleAPIIssueCredential))
+	mux.Handle("POST /peers/{address}/chat", s.requireAPIToken(s.handleAPIChat))
+	mux.Handle("GET /requests", s.requireAPIToken(s.handleAPIListRequests))
+	mux.Handle("GET /requests/{id}", s.requireAPIToken(s.handleAPIGetRequest))
+	mux.Handle("GET /invitations", s.requireAPIToken(s.handleListInvitations))
+	mux.Handle("GET /invitations/{id}", s.requireAPIToken(s.handleGetInvitation))
+	mux.Handle("GET /inboxes", s.requireAPIToken(s.handleListInboxes))
+	mux.Handle("GET /debug/vars", s.requireAPIToken(handleMetrics))
+}
+
+// requireAPIToken rejects requests without the configured bearer token
+func (s *server) requireAPIToken(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.HTTP.APIToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid API token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// sentMessage is the API response for messages that expect no response
+type sentMessage struct {
+	MessageID   string `json:"message_id"`
+	PeerAddress string `json:"peer_address"`
+}
+
+type credentialRequestBody struct {
+	// Definition names the credential definition to request.
+	Definition string `json:"definition"`
+}
+
+func (s *server) handleAPICredentialRequest(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.apiPeer(w, r)
+	if !ok {
+		return
+	}
+
+	var body credentialRequestBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	if _, ok := s.definitions[body.Definition]; !ok {
+		names := slices.Sorted(maps.Keys(s.definitions))
+		http.Error(w, fmt.Sprintf("definition must be one of %s", strings.Join(names, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	req, err := s.sendCredentialRequest(peer, body.Definition, pending.OriginAPI)
+	if err != nil {
+		writeFlowError(w, "handleAPICredentialRequest", err)
+		return
+	}
+
+	writeRequest(w, req)
+}
+
+type agreementBody struct {
+	// Reference is printed on the standard agreement and included in its
+	// claims.
+	Reference string `json:"reference"`
+	// Document is a base64 encoded PDF to sign instead of the standard
+	// agreement.
+	Document []byte `json:"document"`
+}
+
+func (s *server) handleAPIAgreement(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.apiPeer(w, r)
+	if !ok {
+		return
+	}
+
+	var body agreementBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	if body.Document != nil && !bytes.HasPrefix(body.Document, []byte("%PDF-")) {
+		http.Error(w, "document must be a base64 encoded PDF", http.StatusBadRequest)
+		return
+	}
+
+	req, err := s.sendDocumentSigningRequest(peer, body.Reference, body.Document, pending.OriginAPI)
+	if err != nil {
+		writeFlowError(w, "handleAPIAgreement", err)
+		return
+	}
+
+	writeRequest(w, req)
+}
+
+type issueCredentialBody struct {
+	// Type defaults to credentials.custom_type.
+	Type string `json:"type"`
+	// Claims default to credentials.custom_claims.
+	Claims map[string]any `json:"claims"`
+}
+
+func (s *server) handleAPIIssueCredential(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.apiPeer(w, r)
+	if !ok {
+		return
+	}
+
+	var body issueCredentialBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	if body.Type == "" {
+		body.Type = s.config.Credentials.CustomType
+	}
+	if body.Claims == nil {
+		body.Claims = s.config.Credentials.CustomClaims
+	}
+
+	id, err := s.issueCredential(peer, body.Type, body.Claims)
+	if err != nil {
+		writeFlowError(w, "handleAPIIssueCredential", err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, sentMessage{MessageID: id, PeerAddress: peer.String()})
+}
+
+type chatBody struct {
+	Message string `json:"message"`
+}
+
+func (s *server) handleAPIChat(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.apiPeer(w, r)
+	if !ok {
+		return
+	}
+
+	var body chatBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	if strings.TrimSpace(body.Message) == "" {
+		http.Error(w, "message must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	content, err := message.NewChat().
+		Message(body.Message).
+		Finish()
+	if err != nil {
+		log.Printf("handleAPIChat: Failed to build chat message: %v", err)
+		http.Error(w, "failed to build chat message", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.account.MessageSend(peer, content)
+	if err != nil {
+		log.Printf("handleAPIChat: Failed to send chat message to %s: %v", peer, err)
+		http.Error(w, "failed to send chat message", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, sentMessage{MessageID: hex.EncodeToString(content.ID()), PeerAddress: peer.String()})
+}
+
+// handleAPIListRequests lists the requests sent to peers, optionally only
+// those sent to the peer query parameter
+func (s *server) handleAPIListRequests(w http.ResponseWriter, r *http.Request) {
+	requests := s.requests.List()
+
+	if peer := r.URL.Query().Get("peer"); peer != "" {
+		requests = slices.DeleteFunc(requests, func(req pending.Request) bool {
+			return req.PeerAddress != peer
+		})
+	}
+
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *server) handleAPIGetRequest(w http.ResponseWriter, r *http.Request) {
+	req, err := s.requests.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, req)
+}
+
+// errInvalidAddress is returned for peer addresses that cannot be parsed
+var errInvalidAddress = errors.New("invalid peer address")
+
+// contactPeer returns the peer with the given address, which must be a
+// contact
+func (s *server) contactPeer(ctx context.Context, address string) (*signing.PublicKey, error) {
+	peer, err := signing.FromAddress(address)
+	if err != nil {
+		return nil, errInvalidAddress
+	}
+
+	_, err = s.contacts.Get(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// apiPeer returns the peer named by the address path value, which must be a
+// contact
+func (s *server) apiPeer(w http.ResponseWriter, r *http.Request) (*signing.PublicKey, bool) {
+	peer, err := s.contactPeer(r.Context(), r.PathValue("address"))
+	if errors.Is(err, errInvalidAddress) {
+		http.Error(w, "invalid peer address", http.StatusBadRequest)
+		return nil, false
+	}
+	if errors.Is(err, contacts.ErrNotFound) {
+		http.Error(w, "peer is not a contact", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("apiPeer: %v", err)
+		http.Error(w, "failed to get contact", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return peer, true
+}
+
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// writeRequest responds with a request sent to a peer and where to poll it
+func writeRequest(w http.ResponseWriter, req pending.Request) {
+	w.Header().Set("Location", "/requests/"+req.ID)
+	writeJSON(w, http.StatusAccepted, req)
+}
+
+// writeFlowError responds to a flow that failed; failures to reach the peer
+// are reported as a bad gateway
+func writeFlowError(w http.ResponseWriter, op string, err error) {
+	log.Printf("%s: %v", op, err)
+
+	if errors.Is(err, errUntrustedDefinition) {
+		http.Error(w, "no issuer is trusted for that credential, configure credentials.trust", http.StatusConflict)
+		return
+	}
+
+	var fe *flowError
+	if errors.As(err, &fe) && (fe.stage == stageSend || fe.stage == stageUpload) {
+		http.Error(w, fmt.Sprintf("failed to %s %s", fe.stage, fe.what), http.StatusBadGateway)
+		return
+	}
+
+	http.Error(w, "failed to start flow", http.StatusInternalServerError)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+)
+
+// serveAPIRequest serves an API request with a JSON body through the
+// server's HTTP endpoints, with the bearer token if it is not empty
+func serveAPIRequest(s *server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.newHTTPHandler().ServeHTTP(w, req)
+	return w
+}
+
+// newTestAPIServer returns a test server serving the API with the token
+// "secret"
+func newTestAPIServer(t *testing.T) *server {
+	t.Helper()
+
+	s, _ := newTestServer(t)
+	s.config.HTTP.APIToken = "secret"
+	return s
+}
+
+func TestAPIRoutesRequireToken(t *testing.T) {
+	s := newTestAPIServer(t)
+	peer := connectTestPeer(t, s)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodPost, "/peers/" + peer.String() + "/credential-requests"},
+		{http.MethodPost, "/peers/" + peer.String() + "/agreements"},
+		{http.MethodPost, "/peers/" + peer.String() + "/credentials"},
+		{http.MethodPost, "/peers/" + peer.String() + "/chat"},
+		{http.MethodGet, "/requests"},
+		{http.MethodGet, "/requests/unknown"},
+	} {
+		for _, token := range []string{"", "wrong"} {
+			w := serveAPIRequest(s, tc.method, tc.path, token, "{}")
+			if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("%s %s with token %q = %d, want 401", tc.method, tc.path, token, w.Code)
+			}
+		}
+	}
+}
+
+func TestAPIPeerErrors(t *testing.T) {
+	s := newTestAPIServer(t)
+	stranger := newTestAddress(t)
+
+	for _, path := range []string{"credential-requests", "agreements", "credentials", "chat"} {
+		if w := serveAPIRequest(s, http.MethodPost, "/peers/not-an-address/"+path, "secret", "{}"); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s to an invalid address = %d, want 400", path, w.Code)
+		}
+		if w := serveAPIRequest(s, http.MethodPost, "/peers/"+stranger.String()+"/"+path, "secret", "{}"); w.Code != http.StatusNotFound {
+			t.Errorf("POST %s to a stranger = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestAPICredentialRequest(t *testing.T) {
+	s := newTestAPIServer(t)
+	peer := connectTestPeer(t, s)
+	path := "/peers/" + peer.String() + "/credential-requests"
+
+	for _, body := range []string{`{"definition":"unknown"}`, `{"definiton":"email"}`, `not json`} {
+		if w := serveAPIRequest(s, http.MethodPost, path, "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("credential request %s = %d, want 400", body, w.Code)
+		}
+	}
+
+	w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"definition":"email"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("credential request = %d: %s", w.Code, w.Body)
+	}
+
+	var req pending.Request
+	err := json.Unmarshal(w.Body.Bytes(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Kind != pending.KindCredentialPresentation || req.Origin != pending.OriginAPI || req.Subject != "email" || req.PeerAddress != peer.String() {
+		t.Errorf("request = %+v", req)
+	}
+	if location := w.Header().Get("Location"); location != "/requests/"+req.ID {
+		t.Errorf("Location = %q, want /requests/%s", location, req.ID)
+	}
+
+	distrustTestServer(t, s)
+	if w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"definition":"email"}`); w.Code != http.StatusConflict {
+		t.Errorf("credential request without trusted issuers = %d, want 409", w.Code)
+	}
+}
+
+func TestAPIAgreement(t *testing.T) {
+	s := newTestAPIServer(t)
+	peer := connectTestPeer(t, s)
+	path := "/peers/" + peer.String() + "/agreements"
+
+	// "aGVsbG8=" is hello, which is not a PDF
+	if w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"document":"aGVsbG8="}`); w.Code != http.StatusBadRequest {
+		t.Errorf("agreement with a document that is not a PDF = %d, want 400", w.Code)
+	}
+
+	// "JVBERi0xLjQK" is %PDF-1.4
+	for _, body := range []string{`{"reference":"contract-42"}`, `{"document":"JVBERi0xLjQK"}`} {
+		w := serveAPIRequest(s, http.MethodPost, path, "secret", body)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("agreement %s = %d: %s", body, w.Code, w.Body)
+		}
+
+		var req pending.Request
+		err := json.Unmarshal(w.Body.Bytes(), &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Kind != pending.KindCredentialVerification || w.Header().Get("Location") != "/requests/"+req.ID {
+			t.Errorf("agreement %s = %+v at %q", body, req, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAPIIssueCredential(t *testing.T) {
+	s := newTestAPIServer(t)
+	peer := connectTestPeer(t, s)
+	path := "/peers/" + peer.String() + "/credentials"
+
+	if w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"claims":"gold"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("credential with claims that are not an object = %d, want 400", w.Code)
+	}
+
+	w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"claims":{"tier":"gold"}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("credential = %d: %s", w.Code, w.Body)
+	}
+
+	var sent sentMessage
+	err := json.Unmarshal(w.Body.Bytes(), &sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.MessageID == "" || sent.PeerAddress != peer.String() {
+		t.Errorf("credential = %+v", sent)
+	}
+}
+
+func TestAPIChat(t *testing.T) {
+	s, fake := newTestServer(t)
+	s.config.HTTP.APIToken = "secret"
+	peer := connectTestPeer(t, s)
+	path := "/peers/" + peer.String() + "/chat"
+	before := len(fake.Sent())
+
+	if w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"message":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty chat message = %d, want 400", w.Code)
+	}
+
+	w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"message":"hello"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("chat message = %d: %s", w.Code, w.Body)
+	}
+	if sent := fake.Sent(); len(sent) != before+1 || !sent[len(sent)-1].To.Matches(peer) {
+		t.Errorf("sent %d messages, want the chat message to %s", len(sent)-before, peer)
+	}
+
+	fake.Fail("MessageSend", errors.New("Network"))
+	if w := serveAPIRequest(s, http.MethodPost, path, "secret", `{"message":"hello"}`); w.Code != http.StatusBadGateway {
+		t.Errorf("chat message the SDK failed to send = %d, want 502", w.Code)
+	}
+}
+
+func TestAPIRequests(t *testing.T) {
+	s := newTestAPIServer(t)
+	peer := connectTestPeer(t, s)
+	other := connectTestPeer(t, s)
+
+	sent, err := s.sendCredentialRequest(peer, "email", pending.OriginAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.sendCredentialRequest(other, "email", pending.OriginAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listRequests := func(path string) []pending.Request {
+		t.Helper()
+
+		w := serveAPIRequest(s, http.MethodGet, path, "secret", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, w.Code)
+		}
+
+		var requests []pending.Request
+		err := json.Unmarshal(w.Body.Bytes(), &requests)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return requests
+	}
+
+	if requests := listRequests("/requests"); len(requests) != 2 {
+		t.Errorf("listed %d requests, want 2", len(requests))
+	}
+	if requests := listRequests("/requests?peer=" + peer.String()); len(requests) != 1 || requests[0].ID != sent.ID {
+		t.Errorf("requests to %s = %+v", peer, requests)
+	}
+
+	w := serveAPIRequest(s, http.MethodGet, "/requests/"+sent.ID, "secret", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /requests/%s = %d", sent.ID, w.Code)
+	}
+
+	var req pending.Request
+	err = json.Unmarshal(w.Body.Bytes(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ID != sent.ID || req.State != pending.StatePending {
+		t.Errorf("request = %+v", req)
+	}
+
+	if w := serveAPIRequest(s, http.MethodGet, "/requests/unknown", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /requests/unknown = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// authState is the position of an authentication in its lifecycle
+type authState string
+
+const (
+	// authPending authentications wait for a peer to connect
+	authPending authState = "pending"
+	// authConnected authentications wait for the peer to share credentials
+	authConnected authState = "connected"
+	// authVerified authentications have every requested credential verified
+	authVerified authState = "verified"
+	// authRejected authentications failed, see the reason
+	authRejected authState = "rejected"
+)
+
+// authentication follows a peer through connecting, unless already
+// connected, and sharing the credentials of a set of definitions
+type authentication struct {
+	State       authState
+	PeerAddress string
+	Definitions []string
+	// Credentials holds the verified credentials shared so far.
+	Credentials []verification.Credential
+	// Reason explains why the authentication was rejected.
+	Reason string
+}
+
+// authTracker holds the authentications in progress, keyed by the IDs of
+// the credential requests they are waiting for
+type authTracker struct {
+	mu        sync.Mutex
+	byRequest map[string]*authFlow
+}
+
+type authFlow struct {
+	authentication
+	// requests maps the unanswered requests to their definitions
+	requests map[string]string
+	// accepted counts the definitions verified so far
+	accepted int
+	onChange func(authentication)
+}
+
+func newAuthTracker() *authTracker {
+	return &authTracker{
+		byRequest: make(map[string]*authFlow),
+	}
+}
+
+// authenticate starts authenticating a peer with the credentials of the
+// given definitions. A nil peer is a new one: the connection request it must
+// use is returned, recorded as an invitation with opts. onChange is called
+// with a copy of the authentication after every state change, and must not
+// block.
+func (s *server) authenticate(peer *signing.PublicKey, definitions []string, opts invitation.Options, onChange func(authentication)) (*connectionRequest, error) {
+	for _, name := range definitions {
+		if _, ok := s.definitions[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownDefinition, name)
+		}
+		if !s.trust.Trusted(name) {
+			return nil, fmt.Errorf("%w: %s", errUntrustedDefinition, name)
+		}
+	}
+
+	flow := &authFlow{
+		authentication: authentication{
+			State:       authPending,
+			Definitions: definitions,
+		},
+		requests: make(map[string]string),
+		onChange: onChange,
+	}
+
+	if peer != nil {
+		go s.requestAuthentication(flow, peer)
+		return nil, nil
+	}
+
+	opts.OnChange = func(inv invitation.Invitation) {
+		switch inv.State {
+		case invitation.StateConnected:
+			peer, err := signing.FromAddress(inv.PeerAddress)
+			if err != nil {
+				s.auths.reject(flow, "invalid peer address")
+				return
+			}
+			// the connection is still being recorded, request after it
+			go s.requestAuthentication(flow, peer)
+		case invitation.StateExpired:
+			s.auths.reject(flow, "the connection request expired")
+		}
+	}
+
+	return s.newConnectionRequest(opts)
+}
+
+// requestAuthentication sends a connected peer the credential requests of an
+// authentication
+func (s *server) requestAuthentication(flow *authFlow, peer *signing.PublicKey) {
+	s.auths.connected(flow, peer.String())
+
+	for _, name := range flow.Definitions {
+		if !s.auths.active(flow) {
+			return
+		}
+
+		req, err := s.sendCredentialRequest(peer, name, pending.OriginAuthentication)
+		if err != nil {
+			log.Printf("requestAuthentication: %v", err)
+			s.auths.reject(flow, "the "+name+" credential could not be requested")
+			return
+		}
+
+		s.auths.track(flow, req.ID, name)
+
+		// the response may have been handled before the request was tracked
+		req, err = s.requests.Get(req.ID)
+		if result, ok := req.Result.(*verification.Result); err == nil && ok {
+			s.auths.answered(result)
+		}
+	}
+}
+
+// authenticationEvent moves authentications along as the credential
+// requests they wait for are answered or expire
+func (s *server) authenticationEvent(e events.Event) {
+	switch data := e.Data.(type) {
+	case *verification.Result:
+		s.auths.answered(data)
+	case pending.Request:
+		if e.Type == events.RequestExpired {
+			s.auths.expired(data.ID)
+		}
+	}
+}
+
+func (t *authTracker) active(flow *authFlow) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return flow.State == authConnected
+}
+
+func (t *authTracker) track(flow *authFlow, requestID, definition string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if flow.State != authConnected {
+		return
+	}
+
+	flow.requests[requestID] = definition
+	t.byRequest[requestID] = flow
+}
+
+func (t *authTracker) connected(flow *authFlow, peerAddress string) {
+	t.change(flow, func() bool {
+		if flow.State != authPending {
+			return false
+		}
+		flow.State = authConnected
+		flow.PeerAddress = peerAddress
+		return true
+	})
+}
+
+func (t *authTracker) answered(result *verification.Result) {
+	t.mu.Lock()
+	flow, ok := t.byRequest[result.RequestID]
+	t.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if result.Decision != verification.DecisionAccepted {
+		reasons := make([]string, 0, len(result.Reasons))
+		for _, reason := range result.Reasons {
+			reasons = append(reasons, reason.Message)
+		}
+		t.reject(flow, fmt.Sprintf("the %s credential was %s: %s", result.Definition, result.Decision, strings.Join(reasons, "; ")))
+		return
+	}
+
+	t.change(flow, func() bool {
+		_, waiting := flow.requests[result.RequestID]
+		if flow.State != authConnected || !waiting {
+			return false
+		}
+
+		delete(flow.requests, result.RequestID)
+		delete(t.byRequest, result.RequestID)
+		flow.Credentials = append(flow.Credentials, result.Accepted()...)
+		flow.accepted++
+
+		if flow.accepted == len(flow.Definitions) {
+			flow.State = authVerified
+		}
+		return flow.State == authVerified
+	})
+}
+
+func (t *authTracker) expired(requestID string) {
+	t.mu.Lock()
+	flow, ok := t.byRequest[requestID]
+	var definition string
+	if ok {
+		definition = flow.requests[requestID]
+	}
+	t.mu.Unlock()
+
+	if ok {
+		t.reject(flow, "the "+definition+" credential request expired")
+	}
+}
+
+func (t *authTracker) reject(flow *authFlow, reason string) {
+	t.change(flow, func() bool {
+		if flow.State == authVerified || flow.State == authRejected {
+			return false
+		}
+		flow.State = authRejected
+		flow.Reason = reason
+		return true
+	})
+}
+
+// change applies fn to a flow and reports the change, if any. Finished
+// flows stop being tracked.
+func (t *authTracker) change(flow *authFlow, fn func() bool) {
+	t.mu.Lock()
+
+	changed := fn()
+	if flow.State == authVerified || flow.State == authRejected {
+		for id := range flow.requests {
+			delete(t.byRequest, id)
+		}
+	}
+
+	auth := flow.authentication
+	auth.Definitions = append([]string(nil), flow.Definitions...)
+	auth.Credentials = append([]verification.Credential(nil), flow.Credentials...)
+
+	t.mu.Unlock()
+
+	if changed && flow.onChange != nil {
+		flow.onChange(auth)
+	}
+}
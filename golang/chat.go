@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-sdk-examples/golang/internal/command"
+	"github.com/joinself/self-sdk-examples/golang/internal/conversation"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+)
+
+// newCommandRouter registers the chat commands the server responds to
+func (s *server) newCommandRouter() (*command.Router, error) {
+	commands := s.config.Chat.Commands
+
+	router := command.NewRouter()
+	router.Use(
+		command.Logging(),
+		command.RateLimit(s.chatLimiter),
+		s.answerConversation,
+		command.Authorize(s.authorizeCommand),
+	)
+
+	credentialRequest := func(name string) command.Handler {
+		return func(req *command.Request) error {
+			reply, err := s.requestCredential(req.Peer, name)
+			if err != nil {
+				return err
+			}
+			return req.Reply(reply)
+		}
+	}
+
+	for _, cmd := range []command.Command{
+		{
+			Name:        commands.Help,
+			Description: "list the available commands",
+			Handler: func(req *command.Request) error {
+				return req.Reply(router.Help())
+			},
+		},
+		{
+			Name:        commands.ShareCredential,
+			Description: "choose a credential to share",
+			Handler: func(req *command.Request) error {
+				return req.Reply(s.conversations.Start(req.Peer.String(), s.shareCredentialMenu(req.Peer)))
+			},
+		},
+		{
+			Name:        commands.RequestCredential,
+			Args:        "<" + strings.Join(slices.Sorted(maps.Keys(s.definitions)), "|") + ">",
+			Description: "share the named credential",
+			Handler: func(req *command.Request) error {
+				_, ok := s.definitions[strings.ToLower(req.Arg(0))]
+				if len(req.Args) != 1 || !ok {
+					return command.ErrUsage
+				}
+				return credentialRequest(strings.ToLower(req.Arg(0)))(req)
+			},
+		},
+		{
+			Name:        commands.RequestAuth,
+			Description: "verify yourself with a liveness check",
+			Handler:     credentialRequest("liveness"),
+		},
+		{
+			Name:        commands.RequestEmail,
+			Description: "share your verified email address",
+			Handler:     credentialRequest("email"),
+		},
+		{
+			Name:        commands.RequestDocument,
+			Description: "share your verified passport",
+			Handler:     credentialRequest("document"),
+		},
+		{
+			Name:        commands.RequestCustom,
+			Description: "share the custom credential we issued you",
+			Handler:     credentialRequest("custom"),
+		},
+		{
+			Name:        commands.IssueCustomCredential,
+			Description: "receive a custom credential",
+			Restricted:  true,
+			Handler: func(req *command.Request) error {
+				err := s.sendCustomCredential(req.Peer)
+				if err != nil {
+					return err
+				}
+				return req.Reply("Your credential is on its way to your Self app.")
+			},
+		},
+		{
+			Name:        commands.RequestSigning,
+			Args:        "[reference]",
+			Description: "sign an agreement, optionally for the given reference",
+			Restricted:  true,
+			Handler: func(req *command.Request) error {
+				if len(req.Args) > 1 {
+					return command.ErrUsage
+				}
+				_, err := s.sendDocumentSigningRequest(req.Peer, req.Arg(0), nil, pending.OriginChat)
+				if err != nil {
+					return err
+				}
+				return req.Reply("The agreement has been sent to your Self app for signing.")
+			},
+		},
+	} {
+		err := router.Register(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return router, nil
+}
+
+// requestCredential sends the credential request of the named definition to
+// a peer and returns the confirmation to reply with
+func (s *server) requestCredential(peer *signing.PublicKey, name string) (string, error) {
+	_, err := s.sendCredentialRequest(peer, name, pending.OriginChat)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Please check your Self app to share your %s.", s.definitions[name].Description), nil
+}
+
+// shareCredentialMenu asks a peer which of the defined credentials it wants
+// to share
+func (s *server) shareCredentialMenu(peer *signing.PublicKey) *conversation.Menu {
+	menu := &conversation.Menu{
+		Prompt: "Which credential do you want to share?",
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.definitions)) {
+		menu.Options = append(menu.Options, conversation.Option{
+			Label: s.definitions[name].Description,
+			Action: func() (string, error) {
+				return s.requestCredential(peer, name)
+			},
+		})
+	}
+
+	return menu
+}
+
+// answerConversation answers the menu a peer is at instead of running a
+// command. Answers take precedence over commands, and are rate limited like
+// them.
+func (s *server) answerConversation(next command.Handler) command.Handler {
+	return func(req *command.Request) error {
+		reply, ok, err := s.conversations.Answer(req.Peer.String(), req.Text)
+		if !ok {
+			return next(req)
+		}
+		if err != nil || reply == "" {
+			return err
+		}
+		return req.Reply(reply)
+	}
+}
+
+// authorizeCommand only lets known contacts run restricted commands
+func (s *server) authorizeCommand(req *command.Request) error {
+	_, err := s.contacts.Get(context.Background(), req.Peer.String())
+	return err
+}
+
+func (s *server) handleChatMessage(msg *event.Message) error {
+	chatMessage, err := message.DecodeChat(msg.Content())
+	if err != nil {
+		return fmt.Errorf("failed to decode chat message: %w", err)
+	}
+
+	peer := msg.FromAddress()
+
+	err = s.commands.Route(&command.Request{
+		Peer: peer,
+		Text: chatMessage.Message(),
+		Reply: func(text string) error {
+			return s.sendChat(peer, text)
+		},
+	})
+
+	return s.replyCommandError(peer, err)
+}
+
+// replyCommandError tells a peer why their message could not be routed to a
+// command. Peers over the rate limit are told once a window, further
+// messages are dropped without a reply.
+func (s *server) replyCommandError(peer *signing.PublicKey, err error) error {
+	reply, ok := s.commandErrorReply(err)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, command.ErrRateLimited) && !s.chatNotices.Allow(peer.String(), time.Now()):
+		return nil
+	case errors.Is(err, command.ErrUnknownCommand) && s.conversations.Active(peer.String()):
+		reply = "Sorry, that is not one of the options.\n" + s.conversations.Prompt(peer.String())
+	}
+
+	log.Printf("handleChatMessage: %v", err)
+
+	err = s.sendChat(peer, reply)
+	if err != nil {
+		return fmt.Errorf("failed to reply to %s: %w", peer, err)
+	}
+
+	return nil
+}
+
+// commandErrorReply returns what to tell a peer whose message could not be
+// routed to a command. Failures inside a command are left to dispatch.
+func (s *server) commandErrorReply(err error) (string, bool) {
+	var usage *command.UsageError
+
+	switch {
+	case err == nil:
+		return "", false
+	case errors.Is(err, command.ErrUnknownCommand):
+		return fmt.Sprintf("Sorry, I don't know that command. Send %s for a list of commands.", s.config.Chat.Commands.Help), true
+	case errors.As(err, &usage):
+		return "Usage: " + usage.Command.Usage(), true
+	case errors.Is(err, command.ErrUnauthorized):
+		return "Sorry, you are not allowed to do that. Please connect with us first.", true
+	case errors.Is(err, command.ErrRateLimited):
+		return "You are sending commands too quickly. Please wait a moment and try again.", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/command"
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+)
+
+// routeTestChat routes a chat message from peer and returns the replies
+func routeTestChat(s *server, peer *signing.PublicKey, text string) ([]string, error) {
+	var replies []string
+
+	err := s.commands.Route(&command.Request{
+		Peer: peer,
+		Text: text,
+		Reply: func(text string) error {
+			replies = append(replies, text)
+			return nil
+		},
+	})
+
+	return replies, err
+}
+
+func TestMenuAnswers(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+	before := len(fake.Sent())
+
+	replies, err := routeTestChat(s, peer, s.config.Chat.Commands.ShareCredential)
+	if err != nil || len(replies) != 1 || !s.conversations.Active(peer.String()) {
+		t.Fatalf("share credential = %q, %v", replies, err)
+	}
+
+	// the answer picks an option instead of running a command
+	replies, err = routeTestChat(s, peer, "1")
+	if err != nil || len(replies) != 1 {
+		t.Fatalf("menu answer = %q, %v", replies, err)
+	}
+	if s.conversations.Active(peer.String()) || len(fake.Sent()) != before+1 {
+		t.Errorf("the menu answer sent %d requests", len(fake.Sent())-before)
+	}
+}
+
+func TestMenuAnswersRateLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.chatLimiter = ratelimit.New(2, time.Minute)
+
+	var err error
+	s.commands, err = s.newCommandRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := connectTestPeer(t, s)
+
+	_, err = routeTestChat(s, peer, s.config.Chat.Commands.ShareCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an option that does not exist keeps the menu open
+	_, err = routeTestChat(s, peer, "99")
+	if !errors.Is(err, command.ErrUnknownCommand) {
+		t.Fatalf("answer that is not an option = %v, want %v", err, command.ErrUnknownCommand)
+	}
+
+	replies, err := routeTestChat(s, peer, "1")
+	if !errors.Is(err, command.ErrRateLimited) || len(replies) != 0 {
+		t.Errorf("menu answer over the limit = %q, %v, want %v", replies, err, command.ErrRateLimited)
+	}
+	if !s.conversations.Active(peer.String()) {
+		t.Error("the menu was answered over the limit")
+	}
+}
+
+func TestRateLimitNotice(t *testing.T) {
+	s, fake := newTestServer(t)
+	s.chatNotices = ratelimit.New(1, 50*time.Millisecond)
+
+	peer := connectTestPeer(t, s)
+	other := connectTestPeer(t, s)
+	before := len(fake.Sent())
+
+	limited := fmt.Errorf("%w: more than 2 commands in 1m0s", command.ErrRateLimited)
+
+	// a peer flooding the server is told once a window
+	for range 5 {
+		err := s.replyCommandError(peer, limited)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent := len(fake.Sent()) - before; sent != 1 {
+		t.Errorf("sent %d notices, want 1", sent)
+	}
+
+	// other peers are told separately
+	err := s.replyCommandError(other, limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := len(fake.Sent()) - before; sent != 2 {
+		t.Errorf("sent %d notices, want 2", sent)
+	}
+
+	// and told again in the next window
+	time.Sleep(60 * time.Millisecond)
+
+	err = s.replyCommandError(peer, limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := len(fake.Sent()) - before; sent != 3 {
+		t.Errorf("sent %d notices, want 3", sent)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/contacts"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+)
+
+// connectionEstablished records a new connection with a peer, made through
+// one of our inboxes and the invitation issued for it, if any
+func (s *server) connectionEstablished(inboxAddress, peerAddress, connectionAddress *signing.PublicKey, invitationID string) {
+	connectionsEstablished.Add(1)
+	s.inboxes.markInUse(inboxAddress)
+	s.invitationConnected(invitationID, peerAddress, connectionAddress)
+
+	err := s.contacts.Save(context.Background(), contacts.Contact{
+		PeerAddress:       peerAddress.String(),
+		ConnectionAddress: connectionAddress.String(),
+		InvitationID:      invitationID,
+		ConnectedAt:       time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record contact %s: %v", peerAddress, err)
+	}
+
+	s.events.Publish(events.ConnectionEstablished, events.ConnectionData{
+		PeerAddress:       peerAddress.String(),
+		ConnectionAddress: connectionAddress.String(),
+		InvitationID:      invitationID,
+	})
+}
+
+// connectionFailure classifies why a connection could not be established
+type connectionFailure string
+
+const (
+	// connectionFailureExpired key packages were negotiated for an expired
+	// invitation
+	connectionFailureExpired connectionFailure = "expired"
+	// connectionFailureDuplicate key packages were already used, usually a
+	// replay
+	connectionFailureDuplicate connectionFailure = "duplicate"
+	// connectionFailureMalformed key packages could not be decoded
+	connectionFailureMalformed connectionFailure = "malformed"
+	// connectionFailureUnknown key packages were sent without a known
+	// invitation, to the primary inbox or replayed after a restart
+	connectionFailureUnknown connectionFailure = "unknown"
+	// connectionFailureTransient failures may succeed when retried
+	connectionFailureTransient connectionFailure = "transient"
+)
+
+// classifyConnectionError sorts connection errors by the errors they wrap
+// and, as the SDK reports its failures as plain errors, by the state of the
+// invitation the peer connected with and the payload they sent
+func (s *server) classifyConnectionError(invitationID string, payload []byte, err error) connectionFailure {
+	switch {
+	case errors.Is(err, selfaccount.ErrExpired):
+		return connectionFailureExpired
+	case errors.Is(err, selfaccount.ErrDuplicate):
+		return connectionFailureDuplicate
+	case errors.Is(err, selfaccount.ErrMalformed), len(payload) == 0:
+		return connectionFailureMalformed
+	}
+
+	// without an invitation there is nothing a retry could wait for
+	inv, lookupErr := s.invitations.Get(invitationID)
+	if lookupErr != nil {
+		return connectionFailureUnknown
+	}
+
+	switch {
+	case inv.State == invitation.StateConnected:
+		// someone already connected with it, this is a replay
+		return connectionFailureDuplicate
+	case inv.State == invitation.StateExpired, time.Now().After(inv.ExpiresAt):
+		return connectionFailureExpired
+	default:
+		return connectionFailureTransient
+	}
+}
+
+// acceptConnection accepts a peer's welcome to one of our inboxes, and
+// reports whether the connection was made
+func (s *server) acceptConnection(inboxAddress, peerAddress *signing.PublicKey, welcome []byte) bool {
+	invitationID := s.findInvitation(inboxAddress, nil)
+	s.invitationScanned(invitationID, peerAddress)
+
+	groupAddress, err := s.account.ConnectionAccept(inboxAddress, welcome)
+	if err != nil {
+		log.Printf("Failed to accept connection: %v", err)
+		s.connectionFailed(invitationID, peerAddress, welcome, err)
+		return false
+	}
+
+	s.connectionEstablished(inboxAddress, peerAddress, groupAddress, invitationID)
+	return true
+}
+
+// establishConnection completes a connection from a peer's key package.
+// Failures never stop the server; transient ones are retried in the
+// background with exponential backoff. A failure is recorded once, when it
+// is permanent or the retries run out.
+func (s *server) establishConnection(inboxAddress, peerAddress *signing.PublicKey, keyPackage []byte) {
+	invitationID := s.findInvitation(inboxAddress, keyPackage)
+	s.invitationScanned(invitationID, peerAddress)
+
+	retries := s.config.Connection.EstablishRetries
+
+	establish := func(attempt int) bool {
+		groupAddress, err := s.account.ConnectionEstablish(inboxAddress, keyPackage)
+		if err != nil {
+			log.Println("OnKeyPackage: Failed to establish connection:", err)
+
+			failure := s.classifyConnectionError(invitationID, keyPackage, err)
+			if failure == connectionFailureTransient {
+				if attempt < retries {
+					return false
+				}
+				log.Printf("OnKeyPackage: Giving up on connection with %s", peerAddress)
+			}
+
+			s.recordConnectionFailure(invitationID, peerAddress, failure, err)
+			return true
+		}
+
+		log.Println("OnKeyPackage: Successfully established connection with client:", peerAddress)
+		s.connectionEstablished(inboxAddress, peerAddress, groupAddress, invitationID)
+		return true
+	}
+
+	if establish(0) {
+		return
+	}
+
+	go func() {
+		backoff := s.config.Connection.RetryBackoff
+
+		for attempt := 1; attempt <= retries; attempt++ {
+			time.Sleep(backoff)
+			backoff *= 2
+
+			log.Printf("OnKeyPackage: Retrying connection with %s (attempt %d of %d)", peerAddress, attempt, retries)
+			if establish(attempt) {
+				return
+			}
+		}
+	}()
+}
+
+// connectionFailed classifies and records a failed connection with a peer
+func (s *server) connectionFailed(invitationID string, peerAddress *signing.PublicKey, payload []byte, err error) {
+	s.recordConnectionFailure(invitationID, peerAddress, s.classifyConnectionError(invitationID, payload, err), err)
+}
+
+// recordConnectionFailure records a failed connection with a peer against
+// its invitation, in the metrics and as an event
+func (s *server) recordConnectionFailure(invitationID string, peerAddress *signing.PublicKey, failure connectionFailure, err error) {
+	connectionFailures.Add(string(failure), 1)
+	s.invitationFailed(invitationID, peerAddress, fmt.Sprintf("%s: %v", failure, err))
+
+	s.events.Publish(events.ConnectionFailed, events.ConnectionData{
+		PeerAddress: peerAddress.String(),
+		Failure:     string(failure),
+		Error:       err.Error(),
+	})
+}
+
+// recordFacts stores the facts a peer has verified
+func (s *server) recordFacts(peerAddress *signing.PublicKey, facts []contacts.Fact) {
+	if len(facts) == 0 {
+		return
+	}
+
+	err := s.contacts.SetFacts(context.Background(), peerAddress.String(), facts...)
+	if errors.Is(err, contacts.ErrNotFound) {
+		log.Printf("Not recording facts for %s: not a known contact", peerAddress)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record facts for %s: %v", peerAddress, err)
+	}
+}
+
+func (s *server) handleListContacts(w http.ResponseWriter, r *http.Request) {
+	list, err := s.contacts.List(r.Context())
+	if err != nil {
+		log.Printf("handleListContacts: %v", err)
+		http.Error(w, "failed to list contacts", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *server) handleGetContact(w http.ResponseWriter, r *http.Request) {
+	contact, err := s.contacts.Get(r.Context(), r.PathValue("address"))
+	if errors.Is(err, contacts.ErrNotFound) {
+		http.Error(w, "contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("handleGetContact: %v", err)
+		http.Error(w, "failed to get contact", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contact)
+}
+
+func (s *server) handleDeleteContact(w http.ResponseWriter, r *http.Request) {
+	err := s.contacts.Delete(r.Context(), r.PathValue("address"))
+	if errors.Is(err, contacts.ErrNotFound) {
+		http.Error(w, "contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("handleDeleteContact: %v", err)
+		http.Error(w, "failed to delete contact", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// contactFacts maps the credential claims kept as contact facts to the
+// facts' names. Other claims, document numbers included, are never stored.
+var contactFacts = map[string]string{
+	"emailAddress":    "email",
+	"sourceImageHash": "liveness",
+	"displayName":     "display_name",
+}
+
+// claimFacts turns the claims of a verified credential into contact facts
+func claimFacts(claims map[string]any, verifiedAt time.Time) []contacts.Fact {
+	var facts []contacts.Fact
+
+	for k, v := range claims {
+		name, ok := contactFacts[k]
+		if !ok {
+			continue
+		}
+
+		value := fmt.Sprint(v)
+		if name == "liveness" {
+			// the image hash itself is of no use to anyone
+			value = "true"
+		}
+
+		facts = append(facts, contacts.Fact{Name: name, Value: value, VerifiedAt: verifiedAt})
+	}
+
+	return facts
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+)
+
+func TestClaimFacts(t *testing.T) {
+	facts := claimFacts(map[string]any{
+		"emailAddress":           "ada@example.com",
+		"sourceImageHash":        "3f1c",
+		"documentNumber":         "123456789",
+		"passportDocumentNumber": "123456789",
+	}, time.Now())
+
+	got := make(map[string]string)
+	for _, f := range facts {
+		got[f.Name] = f.Value
+	}
+
+	if len(got) != 2 || got["email"] != "ada@example.com" || got["liveness"] != "true" {
+		t.Errorf("facts = %v, want only email and liveness", got)
+	}
+}
+
+func TestContactRoutesRequireToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	// without a token the API, contacts included, is not served at all
+	srv := httptest.NewServer(s.newHTTPHandler())
+	resp, err := http.Get(srv.URL + "/contacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	srv.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /contacts without an API = %d, want 404", resp.StatusCode)
+	}
+
+	s.config.HTTP.APIToken = "secret"
+	srv = httptest.NewServer(s.newHTTPHandler())
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{http.MethodGet, "/contacts", "", http.StatusUnauthorized},
+		{http.MethodGet, "/contacts/" + peer.String(), "wrong", http.StatusUnauthorized},
+		{http.MethodDelete, "/contacts/" + peer.String(), "", http.StatusUnauthorized},
+		{http.MethodGet, "/contacts", "secret", http.StatusOK},
+		{http.MethodGet, "/contacts/" + peer.String(), "secret", http.StatusOK},
+		{http.MethodDelete, "/contacts/" + peer.String(), "secret", http.StatusNoContent},
+	} {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s with token %q = %d, want %d", tc.method, tc.path, tc.token, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+// issueTestInvitation mints a connection request and returns its invitation
+// and inbox
+func issueTestInvitation(t *testing.T, s *server) (invitation.Invitation, *signing.PublicKey) {
+	t.Helper()
+
+	request, err := s.newConnectionRequest(invitation.Options{Creator: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inbox, err := signing.FromAddress(request.invitation.InboxAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request.invitation, inbox
+}
+
+func failureCount(failure connectionFailure) int64 {
+	if v, ok := connectionFailures.Get(string(failure)).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestConnectionFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		err     error
+		welcome []byte
+		setup   func(t *testing.T, s *server, inbox *signing.PublicKey)
+		want    connectionFailure
+	}{
+		{"negotiation expired", errors.New("Network"), []byte("welcome"), func(t *testing.T, s *server, inbox *signing.PublicKey) {
+			// the invitation is still open, only the SDK's expiry has passed
+			_, err := s.account.ConnectionNegotiateOutOfBand(inbox, time.Now().Add(-time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, connectionFailureExpired},
+		{"replayed welcome", nil, []byte("welcome"), func(t *testing.T, s *server, inbox *signing.PublicKey) {
+			_, err := s.account.ConnectionAccept(inbox, []byte("welcome"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, connectionFailureDuplicate},
+		{"empty welcome", nil, nil, nil, connectionFailureMalformed},
+		{"unknown error", errors.New("Network"), []byte("welcome"), nil, connectionFailureTransient},
+		{"expired invitation", errors.New("Network"), []byte("welcome"), func(t *testing.T, s *server, _ *signing.PublicKey) {
+			s.invitations.Expire(time.Now().Add(2 * s.config.Connection.QRExpiry))
+		}, connectionFailureExpired},
+		{"used invitation", errors.New("Network"), []byte("welcome"), func(t *testing.T, s *server, inbox *signing.PublicKey) {
+			if !s.acceptConnection(inbox, newTestAddress(t), []byte("welcome")) {
+				t.Fatal("connection was not accepted")
+			}
+		}, connectionFailureDuplicate},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, fake := newTestServer(t)
+			published := recordEvents(s)
+			inv, inbox := issueTestInvitation(t, s)
+
+			if tc.setup != nil {
+				tc.setup(t, s, inbox)
+			}
+
+			before := failureCount(tc.want)
+			if tc.err != nil {
+				fake.Fail("ConnectionAccept", tc.err)
+			}
+
+			peer := newTestAddress(t)
+			if s.acceptConnection(inbox, peer, tc.welcome) {
+				t.Fatal("a failed connection was accepted")
+			}
+
+			if got := failureCount(tc.want) - before; got != 1 {
+				t.Errorf("recorded %d %s failures, want 1", got, tc.want)
+			}
+
+			inv, _ = s.invitations.Get(inv.ID)
+			if inv.Failures != 1 || !strings.HasPrefix(inv.LastError, string(tc.want)+":") {
+				t.Errorf("invitation = %+v", inv)
+			}
+
+			var failed []events.Event
+			for _, e := range published() {
+				if e.Type == events.ConnectionFailed {
+					failed = append(failed, e)
+				}
+			}
+			if len(failed) != 1 || failed[0].Data.(events.ConnectionData).Failure != string(tc.want) {
+				t.Errorf("events = %+v", failed)
+			}
+
+			// the server carries on once the SDK recovers
+			fake.Fail("ConnectionAccept", nil)
+			connectTestPeer(t, s)
+		})
+	}
+}
+
+func TestEstablishConnectionRetries(t *testing.T) {
+	s, fake := newTestServer(t)
+	s.config.Connection.RetryBackoff = time.Millisecond
+	s.config.Connection.EstablishRetries = 3
+
+	inv, inbox := issueTestInvitation(t, s)
+	peer := newTestAddress(t)
+
+	fake.Fail("ConnectionEstablish", errors.New("Network"))
+	s.establishConnection(inbox, peer, []byte("key package"))
+
+	// transient failures are not recorded while they are retried
+	inv, _ = s.invitations.Get(inv.ID)
+	if inv.State != invitation.StateScanned || inv.Failures != 0 {
+		t.Errorf("invitation after a transient failure = %+v", inv)
+	}
+
+	fake.Fail("ConnectionEstablish", nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		_, err := s.contacts.Get(context.Background(), peer.String())
+		if err == nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Error("the connection was not retried")
+}
+
+// waitForFailures waits for an invitation to record n failed connection
+// attempts, and a little longer to catch any more
+func waitForFailures(t *testing.T, s *server, id string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		inv, _ := s.invitations.Get(id)
+		if inv.Failures >= n {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	inv, _ := s.invitations.Get(id)
+	if inv.Failures != n {
+		t.Errorf("recorded %d failed attempts, want %d", inv.Failures, n)
+	}
+}
+
+func TestEstablishConnectionGivesUp(t *testing.T) {
+	s, fake := newTestServer(t)
+	s.config.Connection.RetryBackoff = time.Millisecond
+	s.config.Connection.EstablishRetries = 3
+
+	// malformed key packages are not retried
+	inv, inbox := issueTestInvitation(t, s)
+	s.establishConnection(inbox, newTestAddress(t), nil)
+	waitForFailures(t, s, inv.ID, 1)
+
+	// transient failures are retried until the retries run out, and
+	// recorded once
+	published := recordEvents(s)
+
+	inv, inbox = issueTestInvitation(t, s)
+	fake.Fail("ConnectionEstablish", errors.New("Network"))
+	s.establishConnection(inbox, newTestAddress(t), []byte("key package"))
+	waitForFailures(t, s, inv.ID, 1)
+
+	var failed int
+	for _, e := range published() {
+		if e.Type == events.ConnectionFailed {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("published %d connection failures, want 1", failed)
+	}
+
+	if n := len(fake.Connections()); n != 0 {
+		t.Errorf("made %d connections", n)
+	}
+}
+
+func TestEstablishConnectionWithoutInvitation(t *testing.T) {
+	s, fake := newTestServer(t)
+	s.config.Connection.RetryBackoff = time.Millisecond
+	s.config.Connection.EstablishRetries = 3
+	published := recordEvents(s)
+
+	before := failureCount(connectionFailureUnknown)
+
+	// a key package sent to the primary inbox, or replayed after a restart,
+	// has no invitation to wait for and is not retried
+	fake.Fail("ConnectionEstablish", errors.New("Network"))
+	s.establishConnection(s.address, newTestAddress(t), []byte("key package"))
+
+	if got := failureCount(connectionFailureUnknown) - before; got != 1 {
+		t.Errorf("recorded %d unknown failures, want 1", got)
+	}
+
+	fake.Fail("ConnectionEstablish", nil)
+	time.Sleep(50 * time.Millisecond)
+
+	if n := len(fake.Connections()); n != 0 {
+		t.Errorf("retried and made %d connections", n)
+	}
+
+	var failed []events.Event
+	for _, e := range published() {
+		if e.Type == events.ConnectionFailed {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) != 1 || failed[0].Data.(events.ConnectionData).Failure != string(connectionFailureUnknown) {
+		t.Errorf("events = %+v", failed)
+	}
+}
+
+func TestConnectQRSurvivesInboxFailures(t *testing.T) {
+	s, fake := newTestServer(t)
+
+	fake.Fail("InboxOpen", errors.New("Network"))
+	if w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=unicode", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("GET /connect/qr with a failing SDK = %d, want 500", w.Code)
+	}
+
+	fake.Fail("InboxOpen", nil)
+	fake.Fail("ConnectionNegotiateOutOfBand", errors.New("Network"))
+	if w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=unicode", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("GET /connect/qr with a failing SDK = %d, want 500", w.Code)
+	}
+
+	fake.Fail("ConnectionNegotiateOutOfBand", nil)
+	if w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=unicode", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /connect/qr after the SDK recovered = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+)
+
+// flowStage is the step of a flow that failed
+type flowStage string
+
+const (
+	stageBuild  flowStage = "build"
+	stageUpload flowStage = "upload"
+	stageIssue  flowStage = "issue"
+	stageSend   flowStage = "send"
+)
+
+// flowError is returned by message handlers when a flow cannot complete. It
+// carries what the peer should be told about it.
+type flowError struct {
+	op    string
+	stage flowStage
+	what  string
+	reply string
+	err   error
+}
+
+func (e *flowError) Error() string {
+	return fmt.Sprintf("%s: failed to %s %s: %v", e.op, e.stage, e.what, e.err)
+}
+
+func (e *flowError) Unwrap() error {
+	return e.err
+}
+
+// newFlowError wraps the error from a failed step of a flow. reply is sent to
+// the peer as a chat message, or nothing is sent if it is empty.
+func newFlowError(op string, stage flowStage, what, reply string, err error) error {
+	return &flowError{
+		op:    op,
+		stage: stage,
+		what:  what,
+		reply: reply,
+		err:   err,
+	}
+}
+
+// startupQueue holds the SDK's callbacks until the server is set up. Events
+// queued while the server was offline can be delivered before account.New
+// returns, possibly on the goroutine calling it, so callbacks are queued
+// rather than made to wait.
+type startupQueue struct {
+	mu      sync.Mutex
+	ready   bool
+	pending []func()
+}
+
+// do runs f, or queues it if the server is not set up yet
+func (q *startupQueue) do(f func()) {
+	q.mu.Lock()
+	if !q.ready {
+		q.pending = append(q.pending, f)
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+
+	f()
+}
+
+// release runs the queued callbacks in the order they arrived, including any
+// that arrive meanwhile, then lets later callbacks run straight away
+func (q *startupQueue) release() {
+	for {
+		q.mu.Lock()
+		pending := q.pending
+		q.pending = nil
+		if len(pending) == 0 {
+			q.ready = true
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+
+		for _, f := range pending {
+			f()
+		}
+	}
+}
+
+// dispatch runs a handler for a message from a peer. A failing handler is
+// logged, counted and explained to the peer; it never stops the server.
+func (s *server) dispatch(peer *signing.PublicKey, op string, handler func() error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			handlerFailures.Add(op, 1)
+			log.Printf("%s: recovered from panic handling message from %s: %v", op, peer, r)
+		}
+	}()
+
+	err := handler()
+	if err == nil {
+		return
+	}
+
+	handlerFailures.Add(op, 1)
+	log.Printf("%s: message from %s failed: %v", op, peer, err)
+
+	var fe *flowError
+	if !errors.As(err, &fe) || fe.reply == "" {
+		return
+	}
+
+	err = s.sendChat(peer, fe.reply)
+	if err != nil {
+		log.Printf("%s: failed to send failure reply to %s: %v", op, peer, err)
+	}
+}
+
+// sendChat replies to a peer with a chat message
+func (s *server) sendChat(peer *signing.PublicKey, text string) error {
+	content, err := message.NewChat().
+		Message(text).
+		Finish()
+	if err != nil {
+		return err
+	}
+
+	return s.account.MessageSend(peer, content)
+}
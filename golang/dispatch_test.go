@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/command"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+func handlerFailureCount(op string) int64 {
+	if v, ok := handlerFailures.Get(op).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestSendFailures(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	errSend := errors.New("network unreachable")
+	fake.Fail("MessageSend", errSend)
+
+	_, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+
+	var fe *flowError
+	if !errors.As(err, &fe) || fe.stage != stageSend || !errors.Is(err, errSend) {
+		t.Fatalf("sendCredentialRequest = %v, want a failed send", err)
+	}
+	if fe.reply == "" {
+		t.Error("a failed request has no reply for the peer")
+	}
+	if n := len(s.requests.List()); n != 0 {
+		t.Errorf("%d requests are pending after a failed send", n)
+	}
+
+	err = s.verificationCompleted(peer, &verification.Result{
+		PeerAddress: peer.String(),
+		RequestID:   "request",
+		Definition:  "email",
+		Decision:    verification.DecisionRejected,
+		VerifiedAt:  time.Now(),
+	})
+	if !errors.As(err, &fe) || fe.stage != stageSend {
+		t.Errorf("verificationCompleted = %v, want a failed send", err)
+	}
+
+	// the send is retried once the account recovers
+	fake.Fail("MessageSend", nil)
+
+	req, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.requests.Get(req.ID); err != nil {
+		t.Errorf("request after recovering = %v", err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	const op = "testDispatch"
+	before := handlerFailureCount(op)
+	fake.Fail("MessageSend", errors.New("network unreachable"))
+
+	s.dispatch(peer, op, func() error {
+		_, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+		return err
+	})
+	if n := handlerFailureCount(op) - before; n != 1 {
+		t.Errorf("%d failures counted after a failed send, want 1", n)
+	}
+
+	s.dispatch(peer, op, func() error {
+		panic("handler bug")
+	})
+	if n := handlerFailureCount(op) - before; n != 2 {
+		t.Errorf("%d failures counted after a panic, want 2", n)
+	}
+
+	// the peer is told about failures once sends work again
+	fake.Fail("MessageSend", nil)
+	sent := len(fake.Sent())
+
+	s.dispatch(peer, op, func() error {
+		return newFlowError(op, stageUpload, "document", "Sorry, try again.", errors.New("storage unavailable"))
+	})
+	if n := len(fake.Sent()); n != sent+1 {
+		t.Errorf("sent %d replies to a failed flow, want 1", n-sent)
+	}
+
+	// handlers that succeed are not counted
+	s.dispatch(peer, op, func() error {
+		return nil
+	})
+	if n := handlerFailureCount(op) - before; n != 3 {
+		t.Errorf("%d failures counted, want 3", n)
+	}
+}
+
+func TestUnknownCommandReply(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.Chat.Commands.Help = "MENU"
+
+	reply, ok := s.commandErrorReply(fmt.Errorf("route: %w", command.ErrUnknownCommand))
+	if !ok || !strings.Contains(reply, "Send MENU") {
+		t.Errorf("reply = %q, want it to name the help command", reply)
+	}
+}
+
+func TestStartupQueue(t *testing.T) {
+	q := &startupQueue{}
+
+	var ran []int
+
+	// callbacks before setup are queued, not blocked
+	q.do(func() { ran = append(ran, 1) })
+	q.do(func() {
+		ran = append(ran, 2)
+		// callbacks arriving while the queue drains run after it
+		q.do(func() { ran = append(ran, 4) })
+	})
+	q.do(func() { ran = append(ran, 3) })
+
+	if len(ran) != 0 {
+		t.Fatalf("ran %v before setup", ran)
+	}
+
+	q.release()
+	q.do(func() { ran = append(ran, 5) })
+
+	if fmt.Sprint(ran) != "[1 2 3 4 5]" {
+		t.Errorf("ran %v, want 1 to 5 in order", ran)
+	}
+}
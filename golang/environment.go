@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joinself/self-go-sdk/account"
+	"github.com/joinself/self-go-sdk/event"
+)
+
+const (
+	environmentMarkerFile = "environment"
+	environmentSandbox    = environment("sandbox")
+	environmentProduction = environment("production")
+)
+
+// environment is the Self network the server talks to. It decides both the
+// account target and the flags of every anonymous message we hand out, so
+// the two always agree.
+type environment string
+
+func parseEnvironment(name string) (environment, error) {
+	switch env := environment(strings.ToLower(strings.TrimSpace(name))); env {
+	case environmentSandbox, environmentProduction:
+		return env, nil
+	default:
+		return "", fmt.Errorf("unknown environment %q, expected %q or %q", name, environmentSandbox, environmentProduction)
+	}
+}
+
+// target returns the account target for the environment.
+func (e environment) target() *account.Target {
+	if e == environmentProduction {
+		return account.TargetProduction
+	}
+	return account.TargetSandbox
+}
+
+// flagMessage marks an anonymous message for the environment, so the mobile
+// app resolves it against the same network as the server.
+func (e environment) flagMessage(msg *event.AnonymousMessage) {
+	if e == environmentSandbox {
+		msg.SetFlags(event.MessageFlagTargetSandbox)
+	}
+}
+
+// checkStoreEnvironment refuses to use a store that was created for a
+// different environment. Stores created before the environment was recorded
+// were always sandbox stores.
+func checkStoreEnvironment(storagePath string, env environment) error {
+	recorded, err := os.ReadFile(filepath.Join(storagePath, environmentMarkerFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		empty, err := isEmptyDir(storagePath)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return nil
+		}
+		recorded = []byte(environmentSandbox)
+	} else if err != nil {
+		return fmt.Errorf("failed to read store environment: %w", err)
+	}
+
+	storeEnv, err := parseEnvironment(string(recorded))
+	if err != nil {
+		return fmt.Errorf("store %s has an invalid environment: %w", storagePath, err)
+	}
+
+	if storeEnv != env {
+		return fmt.Errorf("store %s was created for %s, refusing to start in %s", storagePath, storeEnv, env)
+	}
+
+	return nil
+}
+
+// recordStoreEnvironment writes the environment into the store so later
+// starts can be checked against it.
+func recordStoreEnvironment(storagePath string, env environment) error {
+	err := os.WriteFile(filepath.Join(storagePath, environmentMarkerFile), []byte(env+"\n"), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to record store environment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want environment
+	}{
+		{"sandbox", environmentSandbox},
+		{" Sandbox\n", environmentSandbox},
+		{"PRODUCTION", environmentProduction},
+		{"staging", ""},
+		{"", ""},
+	} {
+		env, err := parseEnvironment(tc.name)
+		if env != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("parseEnvironment(%q) = %q, %v, want %q", tc.name, env, err, tc.want)
+		}
+	}
+}
+
+func TestCheckStoreEnvironment(t *testing.T) {
+	newStore := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+
+		dir := filepath.Join(t.TempDir(), "self-store")
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		env   environment
+		ok    bool
+	}{
+		{"empty store in production", nil, environmentProduction, true},
+		{"empty store in sandbox", nil, environmentSandbox, true},
+		{"store without a marker in sandbox", map[string]string{"account.db": "data"}, environmentSandbox, true},
+		{"store without a marker in production", map[string]string{"account.db": "data"}, environmentProduction, false},
+		{"production store in production", map[string]string{environmentMarkerFile: "production\n"}, environmentProduction, true},
+		{"production store in sandbox", map[string]string{environmentMarkerFile: "production\n"}, environmentSandbox, false},
+		{"sandbox store in production", map[string]string{environmentMarkerFile: "sandbox\n"}, environmentProduction, false},
+		{"store with an invalid marker", map[string]string{environmentMarkerFile: "staging\n"}, environmentSandbox, false},
+	} {
+		err := checkStoreEnvironment(newStore(t, tc.files), tc.env)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s = %v, want ok %t", tc.name, err, tc.ok)
+		}
+	}
+
+	// stores that do not exist yet are created for any environment
+	if err := checkStoreEnvironment(filepath.Join(t.TempDir(), "missing"), environmentProduction); err != nil {
+		t.Errorf("missing store = %v", err)
+	}
+}
+
+func TestRecordStoreEnvironment(t *testing.T) {
+	dir := t.TempDir()
+
+	err := recordStoreEnvironment(dir, environmentProduction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkStoreEnvironment(dir, environmentProduction); err != nil {
+		t.Errorf("recorded environment = %v", err)
+	}
+	if err := checkStoreEnvironment(dir, environmentSandbox); err == nil {
+		t.Error("a store recorded for production was opened in sandbox")
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/contacts"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/rpc"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcEventBuffer is how many events a streaming RPC buffers for a slow
+// client before dropping them
+const grpcEventBuffer = 64
+
+// grpcService implements the gRPC service on top of the same flows the chat
+// commands and the HTTP API start
+type grpcService struct {
+	rpc.UnimplementedConnectionServer
+	s *server
+}
+
+// newGRPCServer returns a gRPC server with the service registered, ready to
+// serve on any listener. It serves TLS when a certificate is configured.
+func (s *server) newGRPCServer() (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+
+	if s.config.GRPC.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.config.GRPC.CertFile, s.config.GRPC.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	opts = append(opts,
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			err := s.authorizeGRPC(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := s.authorizeGRPC(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+
+	srv := grpc.NewServer(opts...)
+	rpc.RegisterConnectionServer(srv, &grpcService{s: s})
+
+	return srv, nil
+}
+
+// startGRPCServer serves the gRPC service in the background. A failure to
+// serve is sent to errs.
+func (s *server) startGRPCServer(addr string, errs chan<- error) (*grpc.Server, error) {
+	srv, err := s.newGRPCServer()
+	if err != nil {
+		return nil, fmt.Errorf("gRPC server failed to start: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC server failed to listen: %w", err)
+	}
+
+	go func() {
+		if s.config.GRPC.CertFile != "" {
+			log.Printf("gRPC server listening on %s with TLS", addr)
+		} else {
+			log.Printf("gRPC server listening on %s", addr)
+		}
+		err := srv.Serve(lis)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			errs <- fmt.Errorf("gRPC server failed: %w", err)
+		}
+	}()
+
+	return srv, nil
+}
+
+// stopGRPCServer waits a short time for in-flight RPCs before closing; event
+// streams never finish on their own
+func stopGRPCServer(srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		srv.Stop()
+	}
+}
+
+// authorizeGRPC checks the bearer token in the authorization metadata
+func (s *server) authorizeGRPC(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.GRPC.Token)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+func (g *grpcService) CreateInvitation(ctx context.Context, req *rpc.CreateInvitationRequest) (*rpc.Invitation, error) {
+	opts := invitation.Options{
+		Creator:  req.GetCreator(),
+		Metadata: req.GetMetadata(),
+	}
+	if opts.Creator == "" {
+		opts.Creator = "grpc"
+	}
+
+	request, err := g.s.newConnectionRequest(opts)
+	if errors.Is(err, invitation.ErrMetadataTooLarge) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		log.Printf("CreateInvitation: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create connection request")
+	}
+
+	payload, err := request.message.Encode()
+	if err != nil {
+		log.Printf("CreateInvitation: Failed to encode connection request: %v", err)
+		return nil, status.Error(codes.Internal, "failed to encode connection request")
+	}
+
+	link, err := request.link(g.s.config.Links.BaseURL)
+	if err != nil {
+		log.Printf("CreateInvitation: Failed to build link: %v", err)
+		return nil, status.Error(codes.Internal, "failed to encode connection request")
+	}
+
+	return &rpc.Invitation{
+		InvitationId: request.invitation.ID,
+		Payload:      payload,
+		Link:         link,
+		ExpiresAt:    request.expiresAt.Unix(),
+	}, nil
+}
+
+func (g *grpcService) RequestCredentials(req *rpc.CredentialRequest, stream rpc.Connection_RequestCredentialsServer) error {
+	peer, err := g.s.contactPeer(stream.Context(), req.GetPeerAddress())
+	if err != nil {
+		return grpcError("RequestCredentials", err)
+	}
+
+	if _, ok := g.s.definitions[req.GetDefinition()]; !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown credential definition %q", req.GetDefinition())
+	}
+
+	return g.s.followRequest(stream, peer, func() (pending.Request, error) {
+		return g.s.sendCredentialRequest(peer, req.GetDefinition(), pending.OriginGRPC)
+	})
+}
+
+func (g *grpcService) IssueCredential(ctx context.Context, req *rpc.IssueCredentialRequest) (*rpc.IssueCredentialResponse, error) {
+	peer, err := g.s.contactPeer(ctx, req.GetPeerAddress())
+	if err != nil {
+		return nil, grpcError("IssueCredential", err)
+	}
+
+	credentialType := req.GetType()
+	if credentialType == "" {
+		credentialType = g.s.config.Credentials.CustomType
+	}
+
+	claims := g.s.config.Credentials.CustomClaims
+	if req.GetClaims() != nil {
+		claims = req.GetClaims().AsMap()
+	}
+
+	id, err := g.s.issueCredential(peer, credentialType, claims)
+	if err != nil {
+		return nil, grpcError("IssueCredential", err)
+	}
+
+	return &rpc.IssueCredentialResponse{MessageId: id}, nil
+}
+
+func (g *grpcService) RequestAgreement(req *rpc.AgreementRequest, stream rpc.Connection_RequestAgreementServer) error {
+	peer, err := g.s.contactPeer(stream.Context(), req.GetPeerAddress())
+	if err != nil {
+		return grpcError("RequestAgreement", err)
+	}
+
+	document := req.GetDocument()
+	if len(document) == 0 {
+		document = nil
+	} else if !bytes.HasPrefix(document, []byte("%PDF-")) {
+		return status.Error(codes.InvalidArgument, "document must be a PDF")
+	}
+
+	return g.s.followRequest(stream, peer, func() (pending.Request, error) {
+		return g.s.sendDocumentSigningRequest(peer, req.GetReference(), document, pending.OriginGRPC)
+	})
+}
+
+func (g *grpcService) Events(req *rpc.EventsRequest, stream rpc.Connection_EventsServer) error {
+	var types []events.Type
+	for _, name := range req.GetTypes() {
+		t := events.Type(name)
+		if !slices.Contains(events.Types, t) {
+			return status.Errorf(codes.InvalidArgument, "unknown event type %q", name)
+		}
+		types = append(types, t)
+	}
+
+	updates := make(chan events.Event, grpcEventBuffer)
+
+	unsubscribe := g.s.events.Subscribe(func(e events.Event) {
+		if len(types) > 0 && !slices.Contains(types, e.Type) {
+			return
+		}
+
+		select {
+		case updates <- e:
+		default:
+			log.Printf("Events: Dropping %s event %s for a slow client", e.Type, e.ID)
+		}
+	})
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-updates:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				log.Printf("Events: Failed to encode %s event %s: %v", e.Type, e.ID, err)
+				continue
+			}
+
+			err = stream.Send(&rpc.Event{
+				Id:   e.ID,
+				Type: string(e.Type),
+				Time: e.Time.UnixNano(),
+				Data: data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// followRequest starts a request with start and streams it to the client,
+// first as sent and then once it is answered or expires. It listens for the
+// outcome before the request is sent, as the response can arrive at once.
+// Publishing must not wait on a slow client, so if the peer's outcomes
+// overflow the buffer the stream ends with an error rather than possibly
+// missing the one it waits for.
+func (s *server) followRequest(stream grpc.ServerStreamingServer[rpc.RequestUpdate], peer *signing.PublicKey, start func() (pending.Request, error)) error {
+	outcomes := make(chan events.Event, grpcEventBuffer)
+	overflowed := make(chan struct{})
+	var overflow sync.Once
+
+	unsubscribe := s.events.Subscribe(func(e events.Event) {
+		if _, address, ok := eventRequest(e); ok && address == peer.String() {
+			select {
+			case outcomes <- e:
+			default:
+				overflow.Do(func() { close(overflowed) })
+			}
+		}
+	})
+	defer unsubscribe()
+
+	req, err := start()
+	if err != nil {
+		return grpcError("followRequest", err)
+	}
+
+	err = stream.Send(&rpc.RequestUpdate{
+		RequestId: req.ID,
+		State:     rpc.RequestState_REQUEST_STATE_PENDING,
+		ExpiresAt: req.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	send := func(e events.Event) error {
+		result, err := json.Marshal(e.Data)
+		if err != nil {
+			return status.Error(codes.Internal, "failed to encode result")
+		}
+
+		return stream.Send(&rpc.RequestUpdate{
+			RequestId: req.ID,
+			State:     requestState(e),
+			ExpiresAt: req.ExpiresAt.Unix(),
+			Result:    result,
+		})
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-outcomes:
+			if id, _, _ := eventRequest(e); id == req.ID {
+				return send(e)
+			}
+		case <-overflowed:
+			// the outcome may still be among the buffered ones
+			for {
+				select {
+				case e := <-outcomes:
+					if id, _, _ := eventRequest(e); id == req.ID {
+						return send(e)
+					}
+				default:
+					log.Printf("followRequest: Too many outcomes for %s to follow request %s", peer, req.ID)
+					return status.Error(codes.ResourceExhausted, "too many concurrent requests to the peer to follow this one, poll it instead")
+				}
+			}
+		}
+	}
+}
+
+// eventRequest returns the request an event concludes, and the peer it was
+// sent to
+func eventRequest(e events.Event) (id string, peerAddress string, ok bool) {
+	switch data := e.Data.(type) {
+	case *verification.Result:
+		return data.RequestID, data.PeerAddress, true
+	case events.AgreementData:
+		return data.RequestID, data.PeerAddress, true
+	case pending.Request:
+		return data.ID, data.PeerAddress, e.Type == events.RequestExpired
+	default:
+		return "", "", false
+	}
+}
+
+func requestState(e events.Event) rpc.RequestState {
+	switch e.Type {
+	case events.CredentialsVerified:
+		switch e.Data.(*verification.Result).Decision {
+		case verification.DecisionAccepted:
+			return rpc.RequestState_REQUEST_STATE_ACCEPTED
+		case verification.DecisionRejected:
+			return rpc.RequestState_REQUEST_STATE_REJECTED
+		default:
+			return rpc.RequestState_REQUEST_STATE_FAILED
+		}
+	case events.AgreementSigned:
+		return rpc.RequestState_REQUEST_STATE_SIGNED
+	case events.AgreementDeclined:
+		return rpc.RequestState_REQUEST_STATE_DECLINED
+	case events.RequestExpired:
+		return rpc.RequestState_REQUEST_STATE_EXPIRED
+	default:
+		return rpc.RequestState_REQUEST_STATE_UNKNOWN
+	}
+}
+
+// grpcError logs a failed RPC and converts the error to a status
+func grpcError(op string, err error) error {
+	switch {
+	case errors.Is(err, errInvalidAddress):
+		return status.Error(codes.InvalidArgument, "invalid peer address")
+	case errors.Is(err, contacts.ErrNotFound):
+		return status.Error(codes.NotFound, "peer is not a contact")
+	case errors.Is(err, errUntrustedDefinition):
+		return status.Error(codes.FailedPrecondition, "no issuer is trusted for that credential, configure credentials.trust")
+	}
+
+	log.Printf("%s: %v", op, err)
+
+	var fe *flowError
+	if errors.As(err, &fe) && (fe.stage == stageSend || fe.stage == stageUpload) {
+		return status.Errorf(codes.Unavailable, "failed to %s %s", fe.stage, fe.what)
+	}
+
+	return status.Error(codes.Internal, "failed to start flow")
+}
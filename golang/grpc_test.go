@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/rpc"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testGRPCToken = "grpc-secret"
+
+// newTestGRPCClient serves the gRPC service over an in-memory listener and
+// returns a client for it, dialled with creds
+func newTestGRPCClient(t *testing.T, s *server, creds credentials.TransportCredentials) rpc.ConnectionClient {
+	t.Helper()
+
+	srv, err := s.newGRPCServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return rpc.NewConnectionClient(conn)
+}
+
+// grpcContext returns a context carrying the bearer token
+func grpcContext(t *testing.T, token string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// waitForRequest waits for the request the stream follows to be sent
+func waitForRequest(t *testing.T, s *server, stream rpc.Connection_RequestCredentialsClient) pending.Request {
+	t.Helper()
+
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetState() != rpc.RequestState_REQUEST_STATE_PENDING {
+		t.Fatalf("first update = %v", update)
+	}
+
+	req, err := s.requests.Get(update.GetRequestId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestGRPCRequiresToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.GRPC.Token = testGRPCToken
+	client := newTestGRPCClient(t, s, insecure.NewCredentials())
+
+	for _, token := range []string{"", "wrong"} {
+		_, err := client.CreateInvitation(grpcContext(t, token), &rpc.CreateInvitationRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("CreateInvitation with token %q = %v, want Unauthenticated", token, err)
+		}
+	}
+
+	invitation, err := client.CreateInvitation(grpcContext(t, testGRPCToken), &rpc.CreateInvitationRequest{Metadata: map[string]string{"campaign": "spring"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := s.invitations.Get(invitation.GetInvitationId())
+	if err != nil || inv.Creator != "grpc" || inv.Metadata["campaign"] != "spring" {
+		t.Errorf("invitation = %+v, %v", inv, err)
+	}
+}
+
+func TestGRPCRequestCredentials(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.GRPC.Token = testGRPCToken
+	client := newTestGRPCClient(t, s, insecure.NewCredentials())
+	peer := connectTestPeer(t, s)
+
+	// the error of a stream arrives with its first message
+	stream, err := client.RequestCredentials(grpcContext(t, testGRPCToken), &rpc.CredentialRequest{PeerAddress: newTestAddress(t).String(), Definition: "email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("request to a stranger = %v, want NotFound", err)
+	}
+
+	stream, err = client.RequestCredentials(grpcContext(t, testGRPCToken), &rpc.CredentialRequest{PeerAddress: peer.String(), Definition: "email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := waitForRequest(t, s, stream)
+	answerTestRequest(t, s, peer, req, verification.DecisionAccepted)
+
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetRequestId() != req.ID || update.GetState() != rpc.RequestState_REQUEST_STATE_ACCEPTED || len(update.GetResult()) == 0 {
+		t.Errorf("outcome = %v", update)
+	}
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("stream after the outcome = %v, want its end", err)
+	}
+}
+
+// testRequestStream records the updates sent on a request stream
+type testRequestStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	updates []*rpc.RequestUpdate
+}
+
+func (st *testRequestStream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *testRequestStream) Send(update *rpc.RequestUpdate) error {
+	st.updates = append(st.updates, update)
+	return nil
+}
+
+func TestFollowRequestOverflow(t *testing.T) {
+	s, _ := newTestServer(t)
+	peer := newTestAddress(t)
+
+	// outcomes of other requests to the peer arrive while the request is
+	// sent, overflowing the buffer
+	flood := func(outcomes ...pending.Request) func() (pending.Request, error) {
+		return func() (pending.Request, error) {
+			for _, outcome := range outcomes {
+				s.events.Publish(events.RequestExpired, outcome)
+			}
+			return pending.Request{ID: "request", PeerAddress: peer.String(), ExpiresAt: time.Now().Add(time.Minute)}, nil
+		}
+	}
+
+	other := pending.Request{ID: "other", PeerAddress: peer.String()}
+	followed := pending.Request{ID: "request", PeerAddress: peer.String()}
+
+	stream := &testRequestStream{ctx: grpcContext(t, "")}
+	err := s.followRequest(stream, peer, flood(slices.Repeat([]pending.Request{other}, grpcEventBuffer+1)...))
+	if status.Code(err) != codes.ResourceExhausted || len(stream.updates) != 1 {
+		t.Errorf("followRequest = %v after %v, want ResourceExhausted", err, stream.updates)
+	}
+
+	// the outcome is still found among the buffered ones
+	stream = &testRequestStream{ctx: grpcContext(t, "")}
+	err = s.followRequest(stream, peer, flood(append([]pending.Request{followed}, slices.Repeat([]pending.Request{other}, grpcEventBuffer)...)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.updates) != 2 || stream.updates[1].GetState() != rpc.RequestState_REQUEST_STATE_EXPIRED {
+		t.Errorf("updates = %v", stream.updates)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for localhost and
+// its key, and returns their paths and a pool trusting it
+func writeTestCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "grpc.crt")
+	keyFile := filepath.Join(dir, "grpc.key")
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
+
+func TestGRPCTLS(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.GRPC.Token = testGRPCToken
+
+	s.config.GRPC.CertFile = filepath.Join(t.TempDir(), "missing.crt")
+	s.config.GRPC.KeyFile = filepath.Join(t.TempDir(), "missing.key")
+	if _, err := s.newGRPCServer(); err == nil {
+		t.Error("started without the certificate")
+	}
+
+	certFile, keyFile, pool := writeTestCertificate(t)
+	s.config.GRPC.CertFile = certFile
+	s.config.GRPC.KeyFile = keyFile
+
+	client := newTestGRPCClient(t, s, credentials.NewClientTLSFromCert(pool, "localhost"))
+	if _, err := client.CreateInvitation(grpcContext(t, testGRPCToken), &rpc.CreateInvitationRequest{}); err != nil {
+		t.Errorf("CreateInvitation over TLS = %v", err)
+	}
+
+	// plaintext clients cannot talk to it
+	plaintext := newTestGRPCClient(t, s, insecure.NewCredentials())
+	ctx, cancel := context.WithTimeout(grpcContext(t, testGRPCToken), time.Second)
+	defer cancel()
+	if _, err := plaintext.CreateInvitation(ctx, &rpc.CreateInvitationRequest{}); err == nil {
+		t.Error("a plaintext client was served")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+)
+
+// newHTTPHandler returns the HTTP endpoints, ready to serve on any listener
+func (s *server) newHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /connect/qr", s.rateLimited(s.handleConnectQR))
+	mux.Handle("POST /sessions", s.rateLimited(s.handleCreateSession))
+	mux.HandleFunc("GET /sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("GET /sessions/{id}/events", s.handleSessionEvents)
+
+	if s.config.HTTP.APIToken != "" {
+		s.registerAPI(mux)
+	}
+
+	if s.oidc != nil {
+		s.oidc.Register(mux, s.rateLimited)
+	}
+
+	return mux
+}
+
+// startHTTPServer serves the HTTP endpoints in the background. A failure to
+// serve is sent to errs.
+func (s *server) startHTTPServer(addr string, errs chan<- error) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.newHTTPHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("HTTP server listening on %s", addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("HTTP server failed: %w", err)
+		}
+	}()
+
+	return server
+}
+
+// rateLimited refuses requests from client addresses that have made more
+// than the configured number within the window, for endpoints that open
+// inboxes or send requests on behalf of anonymous clients
+func (s *server) rateLimited(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.httpLimiter.Allow(clientAddress(r), time.Now()) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.httpLimiter.Window().Seconds())))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// clientAddress returns the IP address a request came from. Forwarding
+// headers are ignored, they can be set by anyone.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// stopHTTPServer waits a short time for in-flight requests before closing
+func stopHTTPServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Println("Error shutting down HTTP server:", err)
+	}
+}
+
+// connectionPayload is the JSON form of a connection request
+type connectionPayload struct {
+	InvitationID string    `json:"invitation_id"`
+	Payload      string    `json:"payload"`
+	Link         string    `json:"link,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// handleConnectQR mints a new connection request and returns it as a QR
+// code. The format query parameter selects png (default), svg, unicode,
+// link for the app link as text, or json for the encoded discovery payload
+// and app link. Links are only built when links.base_url is set. The request
+// is recorded as an invitation created by the creator query parameter, with
+// every meta.<key> parameter as metadata.
+func (s *server) handleConnectQR(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "png"
+	}
+
+	switch format {
+	case "png", "svg", "unicode", "link", "json":
+	default:
+		http.Error(w, "format must be png, svg, unicode, link or json", http.StatusBadRequest)
+		return
+	}
+
+	if format == "link" && s.config.Links.BaseURL == "" {
+		http.Error(w, "app links are not configured", http.StatusNotFound)
+		return
+	}
+
+	request, err := s.newConnectionRequest(invitationOptions(r))
+	if errors.Is(err, invitation.ErrMetadataTooLarge) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("handleConnectQR: %v", err)
+		http.Error(w, "failed to create connection request", http.StatusInternalServerError)
+		return
+	}
+
+	var body []byte
+	var contentType string
+
+	switch format {
+	case "png":
+		contentType = "image/png"
+		body, err = encodeQRPNG(request.message)
+	case "svg":
+		contentType = "image/svg+xml"
+		body, err = request.message.EncodeToQR(event.QREncodingSVG)
+	case "unicode":
+		contentType = "text/plain; charset=utf-8"
+		body, err = request.message.EncodeToQR(event.QREncodingUnicode)
+	case "link":
+		var link string
+		contentType = "text/plain; charset=utf-8"
+		link, err = request.link(s.config.Links.BaseURL)
+		body = []byte(link)
+	case "json":
+		contentType = "application/json"
+		body, err = encodeConnectionPayload(request, s.config.Links.BaseURL)
+	}
+
+	if err != nil {
+		log.Printf("handleConnectQR: Failed to encode %s connection request: %v", format, err)
+		http.Error(w, "failed to encode connection request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Expires", request.expiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Connection-Expires-At", request.expiresAt.UTC().Format(time.RFC3339))
+	w.Header().Set("X-Invitation-ID", request.invitation.ID)
+	w.Write(body)
+}
+
+func encodeConnectionPayload(request *connectionRequest, baseURL string) ([]byte, error) {
+	encoded, err := request.message.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := request.link(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(connectionPayload{
+		InvitationID: request.invitation.ID,
+		Payload:      base64.RawURLEncoding.EncodeToString(encoded),
+		Link:         link,
+		ExpiresAt:    request.expiresAt.UTC(),
+	})
+}
+
+func invitationOptions(r *http.Request) invitation.Options {
+	query := r.URL.Query()
+
+	opts := invitation.Options{
+		Creator:  query.Get("creator"),
+		Metadata: make(map[string]string),
+	}
+
+	if opts.Creator == "" {
+		opts.Creator = "http"
+	}
+
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "meta.")
+		if ok && name != "" && len(values) > 0 {
+			opts.Metadata[name] = values[0]
+		}
+	}
+
+	return opts
+}
+
+func (s *server) handleListInvitations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.invitations.List())
+}
+
+func (s *server) handleGetInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, err := s.invitations.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invitation not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inv)
+}
+
+func (s *server) handleListInboxes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.inboxes.live())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("writeJSON: Failed to encode response: %v", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/oidc"
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+	"github.com/skip2/go-qrcode"
+)
+
+// serveTestRequest serves a request through the server's HTTP endpoints,
+// with the bearer token if it is not empty
+func serveTestRequest(s *server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.newHTTPHandler().ServeHTTP(w, req)
+	return w
+}
+
+func TestConnectQRRateLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.httpLimiter = ratelimit.New(2, time.Minute)
+
+	for i := range 2 {
+		w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=unicode", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, w.Code)
+		}
+	}
+
+	w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=unicode", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("request over the limit = %d (Retry-After %q), want 429", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if n := len(s.invitations.List()); n != 2 {
+		t.Errorf("issued %d invitations, want 2", n)
+	}
+}
+
+func TestConnectQRMetadataLimit(t *testing.T) {
+	s, fake := newTestServer(t)
+
+	query := url.Values{"format": {"unicode"}, "meta.note": {strings.Repeat("x", 1024)}}
+	w := serveTestRequest(s, http.MethodGet, "/connect/qr?"+query.Encode(), "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("oversized metadata = %d, want 400", w.Code)
+	}
+
+	open, _ := fake.InboxList()
+	if len(open) != 1 {
+		t.Errorf("opened %d inboxes for a refused request", len(open)-1)
+	}
+}
+
+func TestConnectQRWithoutLinks(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.Links.BaseURL = ""
+
+	if w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=link", ""); w.Code != http.StatusNotFound {
+		t.Errorf("link without a base URL = %d, want 404", w.Code)
+	}
+
+	w := serveTestRequest(s, http.MethodGet, "/connect/qr?format=json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("json without a base URL = %d, want 200", w.Code)
+	}
+
+	var payload map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload["link"]; ok || payload["payload"] == "" {
+		t.Errorf("json without a base URL = %v, want the payload and no link", payload)
+	}
+}
+
+func TestInvitationRoutesRequireToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	connectTestPeer(t, s)
+	id := s.invitations.List()[0].ID
+
+	for _, path := range []string{"/invitations", "/invitations/" + id, "/inboxes", "/debug/vars"} {
+		if w := serveTestRequest(s, http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s without an API = %d, want 404", path, w.Code)
+		}
+	}
+
+	s.config.HTTP.APIToken = "secret"
+
+	for _, path := range []string{"/invitations", "/invitations/" + id, "/inboxes", "/debug/vars"} {
+		if w := serveTestRequest(s, http.MethodGet, path, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s without a token = %d, want 401", path, w.Code)
+		}
+		if w := serveTestRequest(s, http.MethodGet, path, "secret"); w.Code != http.StatusOK {
+			t.Errorf("GET %s with the token = %d, want 200", path, w.Code)
+		}
+	}
+}
+
+func TestMetricsOmitProcessVars(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.config.HTTP.APIToken = "secret"
+	connectTestPeer(t, s)
+
+	w := serveTestRequest(s, http.MethodGet, "/debug/vars", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /debug/vars = %d, want 200", w.Code)
+	}
+
+	var vars map[string]json.RawMessage
+	err := json.Unmarshal(w.Body.Bytes(), &vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := vars["connections_established"]; !ok {
+		t.Error("connections_established is not published")
+	}
+	for _, name := range []string{"cmdline", "memstats"} {
+		if _, ok := vars[name]; ok {
+			t.Errorf("%s is published", name)
+		}
+	}
+}
+
+func TestOIDCAuthorizeRateLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.httpLimiter = ratelimit.New(1, time.Minute)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := oidc.NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := &oidcAuthenticator{s: s}
+	s.oidc = oidc.New(config.OIDC{
+		Issuer:      "https://self.example.com",
+		Clients:     []config.OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}},
+		Scopes:      map[string][]string{"openid": {"liveness"}},
+		LoginExpiry: time.Minute,
+	}, signer, authenticator)
+	authenticator.provider = s.oidc
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"scope":                 {"openid"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+
+	if w := serveTestRequest(s, http.MethodGet, "/oidc/authorize?"+query.Encode(), ""); w.Code == http.StatusTooManyRequests {
+		t.Fatal("the first login was refused")
+	}
+	if w := serveTestRequest(s, http.MethodGet, "/oidc/authorize?"+query.Encode(), ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("authorize over the limit = %d, want 429", w.Code)
+	}
+}
+
+func TestEncodeQRPNG(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	request, err := s.newConnectionRequest(invitation.Options{Creator: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encodeQRPNG(request.message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image draws the encoded message, module by module, with its
+	// quiet zone
+	encoded, err := request.message.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	qr, err := qrcode.New(string(encoded), qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := qr.Bitmap()
+
+	size := len(modules)
+	if img.Bounds().Dx() != size*qrModuleSize || img.Bounds().Dy() != size*qrModuleSize {
+		t.Fatalf("image is %v, want %d modules of %dpx", img.Bounds(), size, qrModuleSize)
+	}
+
+	for y, row := range modules {
+		for x, dark := range row {
+			r, _, _, _ := img.At(x*qrModuleSize+qrModuleSize/2, y*qrModuleSize+qrModuleSize/2).RGBA()
+			if (r == 0) != dark {
+				t.Fatalf("module %d,%d dark = %t, want %t", x, y, r == 0, dark)
+			}
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+)
+
+// inboxPurpose describes why an inbox was opened
+type inboxPurpose string
+
+const (
+	// inboxPurposePrimary is the server's long lived messaging inbox
+	inboxPurposePrimary inboxPurpose = "primary"
+	// inboxPurposeInvitation inboxes are opened for a single connection QR code
+	inboxPurposeInvitation inboxPurpose = "invitation"
+	// inboxPurposeUnknown inboxes were opened by an earlier run of the server
+	// that did not record why
+	inboxPurposeUnknown inboxPurpose = "unknown"
+)
+
+// inboxesFile records the open inboxes in the storage directory, so their
+// purpose and expiry survive restarts
+const inboxesFile = "inboxes.json"
+
+// unreadableSuffix is added to an inboxes file that could not be read
+const unreadableSuffix = ".unreadable"
+
+// liveInbox describes an open inbox
+type liveInbox struct {
+	Address   string       `json:"address"`
+	Purpose   inboxPurpose `json:"purpose"`
+	OpenedAt  time.Time    `json:"opened_at"`
+	ExpiresAt time.Time    `json:"expires_at,omitzero"`
+	InUse     bool         `json:"in_use"`
+}
+
+type trackedInbox struct {
+	address *signing.PublicKey
+	info    liveInbox
+}
+
+// inboxManager tracks the inboxes the server opens and closes the ones that
+// expired without ever carrying a connection. Inboxes are recorded in a file
+// at path, unless it is empty.
+type inboxManager struct {
+	mu      sync.Mutex
+	account selfaccount.Account
+	path    string
+	inboxes map[string]*trackedInbox
+}
+
+func newInboxManager(acc selfaccount.Account, path string) *inboxManager {
+	return &inboxManager{
+		account: acc,
+		path:    path,
+		inboxes: make(map[string]*trackedInbox),
+	}
+}
+
+// primary returns the open inbox recorded as the server's primary inbox.
+// Stores without a record, new ones or ones from before inboxes were
+// recorded, take the first open inbox, as only the primary inbox was open
+// when the store was created.
+func (m *inboxManager) primary(open []*signing.PublicKey) (*signing.PublicKey, error) {
+	if len(open) == 0 {
+		return nil, errors.New("no inboxes are open")
+	}
+
+	recorded, err := m.load()
+	if err != nil && len(open) > 1 {
+		return nil, fmt.Errorf("cannot tell which of %d open inboxes is the primary one: %w", len(open), err)
+	}
+
+	var address string
+	for _, info := range recorded {
+		if info.Purpose == inboxPurposePrimary {
+			address = info.Address
+			break
+		}
+	}
+
+	if address == "" {
+		return open[0], nil
+	}
+
+	for _, inbox := range open {
+		if inbox.String() == address {
+			return inbox, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the primary inbox %s recorded in %s is not open", address, m.path)
+}
+
+// restore tracks the inboxes that are open at startup with the purpose and
+// expiry recorded for them. Inboxes without a record expire at
+// unknownExpiry, so they are closed unless a connection is made through
+// them. If the record cannot be read, they are tracked without an expiry
+// instead, as they may carry connections, and the unreadable record is
+// moved aside rather than written over.
+func (m *inboxManager) restore(primary *signing.PublicKey, open []*signing.PublicKey, unknownExpiry time.Time) error {
+	recorded, err := m.load()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		unknownExpiry = time.Time{}
+		m.setAside()
+	}
+
+	m.inboxes[primary.String()] = newTrackedInbox(primary, inboxPurposePrimary, time.Time{})
+
+	for _, address := range open {
+		if address.Matches(primary) {
+			continue
+		}
+
+		info, ok := recorded[address.String()]
+		if !ok {
+			m.inboxes[address.String()] = newTrackedInbox(address, inboxPurposeUnknown, unknownExpiry)
+			continue
+		}
+
+		m.inboxes[address.String()] = &trackedInbox{address: address, info: info}
+	}
+
+	m.save()
+
+	return err
+}
+
+// setAside moves an unreadable record to unreadableSuffix next to it. If it
+// cannot be moved, inboxes are no longer recorded for this run. The caller
+// must hold m.mu.
+func (m *inboxManager) setAside() {
+	if m.path == "" {
+		return
+	}
+
+	err := os.Rename(m.path, m.path+unreadableSuffix)
+	if err != nil {
+		log.Printf("Failed to move aside %s, inboxes will not be recorded: %v", m.path, err)
+		m.path = ""
+	}
+}
+
+// load returns the inboxes recorded by an earlier run
+func (m *inboxManager) load() (map[string]liveInbox, error) {
+	recorded := make(map[string]liveInbox)
+	if m.path == "" {
+		return recorded, nil
+	}
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return recorded, nil
+	}
+	if err != nil {
+		return recorded, fmt.Errorf("failed to read %s: %w", m.path, err)
+	}
+
+	var inboxes []liveInbox
+	err = json.Unmarshal(data, &inboxes)
+	if err != nil {
+		return recorded, fmt.Errorf("failed to decode %s: %w", m.path, err)
+	}
+
+	for _, info := range inboxes {
+		recorded[info.Address] = info
+	}
+
+	return recorded, nil
+}
+
+// save records the tracked inboxes. The caller must hold m.mu.
+func (m *inboxManager) save() {
+	if m.path == "" {
+		return
+	}
+
+	inboxes := make([]liveInbox, 0, len(m.inboxes))
+	for _, inbox := range m.inboxes {
+		inboxes = append(inboxes, inbox.info)
+	}
+
+	data, err := json.Marshal(inboxes)
+	if err != nil {
+		log.Printf("Failed to encode inboxes: %v", err)
+		return
+	}
+
+	// write a new file and move it over the old one, so a crash never
+	// leaves a partial record
+	tmp := m.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err == nil {
+		err = os.Rename(tmp, m.path)
+	}
+	if err != nil {
+		log.Printf("Failed to record inboxes in %s: %v", m.path, err)
+	}
+}
+
+// open opens a new inbox. Inboxes with an expiry are closed once it has
+// passed, unless a connection has been established through them.
+func (m *inboxManager) open(purpose inboxPurpose, expiresAt time.Time) (*signing.PublicKey, error) {
+	address, err := m.account.InboxOpen()
+	if err != nil {
+		return nil, err
+	}
+
+	m.track(address, purpose, expiresAt)
+
+	return address, nil
+}
+
+// track starts tracking an inbox that is already open
+func (m *inboxManager) track(address *signing.PublicKey, purpose inboxPurpose, expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inboxes[address.String()] = newTrackedInbox(address, purpose, expiresAt)
+	m.save()
+}
+
+func newTrackedInbox(address *signing.PublicKey, purpose inboxPurpose, expiresAt time.Time) *trackedInbox {
+	return &trackedInbox{
+		address: address,
+		info: liveInbox{
+			Address:   address.String(),
+			Purpose:   purpose,
+			OpenedAt:  time.Now(),
+			ExpiresAt: expiresAt,
+		},
+	}
+}
+
+// markInUse keeps an inbox open for good, as a connection now uses it
+func (m *inboxManager) markInUse(address *signing.PublicKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inbox, ok := m.inboxes[address.String()]
+	if ok && !inbox.info.InUse {
+		inbox.info.InUse = true
+		m.save()
+	}
+}
+
+// reap closes the inboxes that expired without being used
+func (m *inboxManager) reap(now time.Time) {
+	m.mu.Lock()
+
+	var expired []*trackedInbox
+	for _, inbox := range m.inboxes {
+		if inbox.info.InUse || inbox.info.ExpiresAt.IsZero() || now.Before(inbox.info.ExpiresAt) {
+			continue
+		}
+		expired = append(expired, inbox)
+	}
+
+	m.mu.Unlock()
+
+	for _, inbox := range expired {
+		err := m.account.InboxClose(inbox.address)
+		if err != nil {
+			log.Printf("Failed to close expired inbox %s: %v", inbox.info.Address, err)
+			continue
+		}
+
+		m.mu.Lock()
+		delete(m.inboxes, inbox.info.Address)
+		m.save()
+		m.mu.Unlock()
+
+		log.Printf("Closed expired %s inbox %s", inbox.info.Purpose, inbox.info.Address)
+	}
+}
+
+// live returns the inboxes that are currently open
+func (m *inboxManager) live() []liveInbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inboxes := make([]liveInbox, 0, len(m.inboxes))
+	for _, inbox := range m.inboxes {
+		inboxes = append(inboxes, inbox.info)
+	}
+
+	slices.SortFunc(inboxes, func(a, b liveInbox) int {
+		return a.OpenedAt.Compare(b.OpenedAt)
+	})
+
+	return inboxes
+}
+
+// run reaps expired inboxes on a schedule
+func (m *inboxManager) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		m.reap(now)
+	}
+}
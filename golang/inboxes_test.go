@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+)
+
+func TestInboxRestore(t *testing.T) {
+	fake, err := selfaccount.NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), inboxesFile)
+	now := time.Now()
+
+	open, _ := fake.InboxList()
+	primary := open[0]
+
+	// an earlier run opened an invitation inbox and one that carried a
+	// connection
+	before := newInboxManager(fake, path)
+	before.track(primary, inboxPurposePrimary, time.Time{})
+
+	invitationInbox, err := before.open(inboxPurposeInvitation, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usedInbox, err := before.open(inboxPurposeInvitation, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before.markInUse(usedInbox)
+
+	// and one without a record
+	unknownInbox, err := fake.InboxOpen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, _ = fake.InboxList()
+
+	after := newInboxManager(fake, path)
+	err = after.restore(primary, open[1:], now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := make(map[string]liveInbox)
+	for _, info := range after.live() {
+		restored[info.Address] = info
+	}
+
+	if info := restored[invitationInbox.String()]; info.Purpose != inboxPurposeInvitation || !info.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("invitation inbox = %+v", info)
+	}
+	if info := restored[usedInbox.String()]; !info.InUse {
+		t.Errorf("used inbox = %+v", info)
+	}
+	if info := restored[unknownInbox.String()]; info.Purpose != inboxPurposeUnknown || !info.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("unknown inbox = %+v", info)
+	}
+
+	// both unused inboxes are closed once they expire
+	after.reap(now.Add(2 * time.Hour))
+
+	open, _ = fake.InboxList()
+	if len(open) != 2 || !open[0].Matches(primary) || !open[1].Matches(usedInbox) {
+		t.Errorf("open inboxes after reaping = %v, want %s and %s", open, primary, usedInbox)
+	}
+
+	// and forgotten by the next run
+	recorded, err := newInboxManager(fake, path).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Errorf("recorded %d inboxes, want 2", len(recorded))
+	}
+}
+
+func TestInboxRestoreCorruptRecord(t *testing.T) {
+	fake, err := selfaccount.NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), inboxesFile)
+	now := time.Now()
+
+	open, _ := fake.InboxList()
+	primary := open[0]
+
+	connectionInbox, err := fake.InboxOpen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := []byte("[{not json")
+	err = os.WriteFile(path, corrupt, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := newInboxManager(fake, path)
+	err = after.restore(primary, []*signing.PublicKey{connectionInbox}, now.Add(time.Hour))
+	if err == nil {
+		t.Error("a corrupt record was restored")
+	}
+
+	// inboxes that may carry connections are never reaped
+	after.reap(now.Add(2 * time.Hour))
+
+	open, _ = fake.InboxList()
+	if len(open) != 2 || !open[1].Matches(connectionInbox) {
+		t.Errorf("open inboxes after reaping = %v, want %s kept", open, connectionInbox)
+	}
+
+	// and the corrupt record is kept for inspection
+	kept, err := os.ReadFile(path + unreadableSuffix)
+	if err != nil || string(kept) != string(corrupt) {
+		t.Errorf("corrupt record = %q, %v, want it moved aside", kept, err)
+	}
+}
+
+func TestInboxPrimary(t *testing.T) {
+	fake, err := selfaccount.NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), inboxesFile)
+
+	open, _ := fake.InboxList()
+
+	// a new store takes the only open inbox
+	primary, err := newInboxManager(fake, path).primary(open)
+	if err != nil || !primary.Matches(open[0]) {
+		t.Fatalf("primary of a new store = %v, %v, want %s", primary, err, open[0])
+	}
+
+	// later runs take the recorded primary, wherever the SDK lists it
+	recorded, err := fake.InboxOpen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newInboxManager(fake, path).track(recorded, inboxPurposePrimary, time.Time{})
+
+	open, _ = fake.InboxList()
+
+	primary, err = newInboxManager(fake, path).primary(open)
+	if err != nil || !primary.Matches(recorded) {
+		t.Errorf("primary = %v, %v, want the recorded %s", primary, err, recorded)
+	}
+
+	// a recorded primary that is no longer open is refused
+	_, err = newInboxManager(fake, path).primary(open[:1])
+	if err == nil {
+		t.Error("a primary inbox that is not open was accepted")
+	}
+
+	// and so is guessing between open inboxes without a readable record
+	err = os.WriteFile(path, []byte("[{not json"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newInboxManager(fake, path).primary(open)
+	if err == nil {
+		t.Error("a primary inbox was guessed from an unreadable record")
+	}
+}
@@ -0,0 +1,237 @@
+// Package command routes chat messages to the handlers registered for them.
+// Commands match by name or by pattern, ignoring case and extra whitespace,
+// and the words following a command name are passed to its handler as
+// arguments.
+package command
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+)
+
+var (
+	// ErrUnknownCommand is returned when no command matches a message.
+	ErrUnknownCommand = errors.New("unknown command")
+	// ErrUsage is returned by handlers when they are given the wrong
+	// arguments. Route wraps it in a UsageError.
+	ErrUsage = errors.New("invalid arguments")
+	// ErrUnauthorized is returned when a peer may not run a command.
+	ErrUnauthorized = errors.New("not authorized")
+	// ErrRateLimited is returned when a peer sends too many commands.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// UsageError reports how a command should have been called.
+type UsageError struct {
+	Command *Command
+}
+
+func (e *UsageError) Error() string {
+	return "usage: " + e.Command.Usage()
+}
+
+func (e *UsageError) Unwrap() error {
+	return ErrUsage
+}
+
+// Request is a chat message being routed to a command.
+type Request struct {
+	// Peer sent the message.
+	Peer *signing.PublicKey
+	// Text is the message with case preserved and whitespace collapsed.
+	Text string
+	// Args are the words after the command name, or the submatches of the
+	// command's pattern.
+	Args []string
+	// Command is the command the message matched, or nil if it matched
+	// none. It is set before any middleware runs.
+	Command *Command
+	// Reply sends a chat message back to the peer.
+	Reply func(text string) error
+}
+
+// Arg returns the i'th argument, or an empty string if there are fewer.
+func (r *Request) Arg(i int) string {
+	if i < len(r.Args) {
+		return r.Args[i]
+	}
+	return ""
+}
+
+// Handler runs a command.
+type Handler func(req *Request) error
+
+// Middleware wraps the handler of every command.
+type Middleware func(next Handler) Handler
+
+// Command is a chat command. It matches either by Name or by Pattern.
+type Command struct {
+	// Name matches messages starting with it.
+	Name string
+	// Pattern matches whole messages. It is compiled case insensitive.
+	Pattern string
+	// Args describes the arguments for the help text, e.g. "<document-id>".
+	Args string
+	// Description is shown in the help text.
+	Description string
+	// Restricted commands are only run for peers the Authorize middleware
+	// accepts.
+	Restricted bool
+	// Handler runs the command.
+	Handler Handler
+
+	pattern *regexp.Regexp
+}
+
+// Usage returns how the command is called.
+func (c *Command) Usage() string {
+	name := c.Name
+	if name == "" {
+		name = c.Pattern
+	}
+
+	if c.Args == "" {
+		return name
+	}
+
+	return name + " " + c.Args
+}
+
+// Router routes messages to commands.
+type Router struct {
+	commands   []*Command
+	middleware []Middleware
+}
+
+// NewRouter returns a router without any commands.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use adds middleware. Middleware runs in the order it was added, around
+// every command registered before or after, and around messages that match
+// none, for which req.Command is nil.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Register adds a command. Commands are tried in the order they are
+// registered.
+func (r *Router) Register(cmd Command) error {
+	if cmd.Handler == nil {
+		return errors.New("command: handler must be set")
+	}
+
+	if (cmd.Name == "") == (cmd.Pattern == "") {
+		return errors.New("command: exactly one of name or pattern must be set")
+	}
+
+	if cmd.Name != "" {
+		cmd.Name = normalize(cmd.Name)
+
+		for _, existing := range r.commands {
+			if strings.EqualFold(existing.Name, cmd.Name) {
+				return fmt.Errorf("command: %s is already registered", cmd.Name)
+			}
+		}
+	}
+
+	if cmd.Pattern != "" {
+		pattern, err := regexp.Compile("(?i)^(?:" + cmd.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("command: invalid pattern %q: %w", cmd.Pattern, err)
+		}
+		cmd.pattern = pattern
+	}
+
+	r.commands = append(r.commands, &cmd)
+
+	return nil
+}
+
+// Route runs the command matching req.Text, or returns ErrUnknownCommand
+// once the middleware has seen it. Errors from the command are returned as
+// they are, except ErrUsage which is turned into a UsageError.
+func (r *Router) Route(req *Request) error {
+	req.Text = normalize(req.Text)
+
+	cmd, args := r.match(req.Text)
+
+	var handler Handler
+	if cmd != nil {
+		req.Command = cmd
+		req.Args = args
+		handler = cmd.Handler
+	} else {
+		handler = func(req *Request) error {
+			return fmt.Errorf("%w: %q", ErrUnknownCommand, req.Text)
+		}
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+
+	err := handler(req)
+	if cmd != nil && errors.Is(err, ErrUsage) {
+		var usage *UsageError
+		if !errors.As(err, &usage) {
+			err = &UsageError{Command: cmd}
+		}
+	}
+
+	return err
+}
+
+func (r *Router) match(text string) (*Command, []string) {
+	for _, cmd := range r.commands {
+		if cmd.pattern != nil {
+			matches := cmd.pattern.FindStringSubmatch(text)
+			if matches != nil {
+				return cmd, matches[1:]
+			}
+			continue
+		}
+
+		if len(text) < len(cmd.Name) || !strings.EqualFold(text[:len(cmd.Name)], cmd.Name) {
+			continue
+		}
+
+		rest := text[len(cmd.Name):]
+		if rest == "" {
+			return cmd, nil
+		}
+
+		if rest[0] == ' ' {
+			return cmd, strings.Fields(rest)
+		}
+	}
+
+	return nil, nil
+}
+
+// Help lists the registered commands, one per line.
+func (r *Router) Help() string {
+	var b strings.Builder
+
+	b.WriteString("Available commands:")
+	for _, cmd := range r.commands {
+		b.WriteString("\n")
+		b.WriteString(cmd.Usage())
+		if cmd.Description != "" {
+			b.WriteString(" - ")
+			b.WriteString(cmd.Description)
+		}
+	}
+
+	return b.String()
+}
+
+// normalize trims text and collapses runs of whitespace to a single space.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// routeTestMessage routes text and returns the command it ran, if any, and
+// the arguments it was given
+func routeTestMessage(t *testing.T, router *Router, text string) (string, []string, error) {
+	t.Helper()
+
+	var ran string
+	var args []string
+
+	req := &Request{Text: text}
+	err := router.Route(req)
+	if req.Command != nil {
+		ran = req.Command.Usage()
+		args = req.Args
+	}
+
+	return ran, args, err
+}
+
+func newTestRouter(t *testing.T) *Router {
+	t.Helper()
+
+	router := NewRouter()
+	for _, cmd := range []Command{
+		{Name: "HELP", Description: "list the commands"},
+		{Name: "SIGN", Args: "[reference]", Description: "sign an agreement"},
+		{Name: "SIGN UP"},
+		{Pattern: `share (\w+) with (\w+)`, Description: "share a credential"},
+	} {
+		cmd.Handler = func(req *Request) error { return nil }
+
+		err := router.Register(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return router
+}
+
+func TestRoute(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		text    string
+		command string
+		args    []string
+	}{
+		{"HELP", "HELP", nil},
+		{"help", "HELP", nil},
+		{"  Help \t", "HELP", nil},
+		{"sign", "SIGN [reference]", nil},
+		{"sign contract-42", "SIGN [reference]", []string{"contract-42"}},
+		{"sign   contract-42\n  draft", "SIGN [reference]", []string{"contract-42", "draft"}},
+		// commands are tried in order, so SIGN takes SIGN UP's messages
+		{"sign up", "SIGN [reference]", []string{"up"}},
+		{"share email with Bob", `share (\w+) with (\w+)`, []string{"email", "Bob"}},
+		{"SHARE  email WITH bob", `share (\w+) with (\w+)`, []string{"email", "bob"}},
+	}
+
+	for _, test := range tests {
+		ran, args, err := routeTestMessage(t, router, test.text)
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if ran != test.command || !slices.Equal(args, test.args) {
+			t.Errorf("%q ran %q with %q, want %q with %q", test.text, ran, args, test.command, test.args)
+		}
+	}
+}
+
+func TestRouteUnknown(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, text := range []string{"", "helpme", "share email", "share email with Bob now", "please help"} {
+		ran, _, err := routeTestMessage(t, router, text)
+		if !errors.Is(err, ErrUnknownCommand) || ran != "" {
+			t.Errorf("%q ran %q: %v, want %v", text, ran, err, ErrUnknownCommand)
+		}
+	}
+}
+
+func TestRouteUsage(t *testing.T) {
+	router := NewRouter()
+	err := router.Register(Command{Name: "SIGN", Args: "[reference]", Handler: func(req *Request) error {
+		return ErrUsage
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = router.Route(&Request{Text: "sign a b"})
+
+	var usage *UsageError
+	if !errors.As(err, &usage) || !errors.Is(err, ErrUsage) || err.Error() != "usage: SIGN [reference]" {
+		t.Errorf("usage error = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	router := newTestRouter(t)
+	handler := func(req *Request) error { return nil }
+
+	for _, cmd := range []Command{
+		{Name: "nothing"},
+		{Handler: handler},
+		{Name: "both", Pattern: "both", Handler: handler},
+		{Name: " help ", Handler: handler},
+		{Pattern: "(", Handler: handler},
+	} {
+		if err := router.Register(cmd); err == nil {
+			t.Errorf("registered %+v", cmd)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	router := newTestRouter(t)
+
+	want := strings.Join([]string{
+		"Available commands:",
+		"HELP - list the commands",
+		"SIGN [reference] - sign an agreement",
+		"SIGN UP",
+		`share (\w+) with (\w+) - share a credential`,
+	}, "\n")
+
+	if help := router.Help(); help != want {
+		t.Errorf("Help() = %q, want %q", help, want)
+	}
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+)
+
+// Logging logs every command and how long it took.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) error {
+			start := time.Now()
+			err := next(req)
+
+			if err != nil {
+				log.Printf("command: %q from %s failed after %s: %v", req.Text, req.Peer, time.Since(start), err)
+			} else {
+				log.Printf("command: %q from %s took %s", req.Text, req.Peer, time.Since(start))
+			}
+
+			return err
+		}
+	}
+}
+
+// Authorize runs restricted commands only when allow returns nil. The error
+// from allow is wrapped in ErrUnauthorized.
+func Authorize(allow func(req *Request) error) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) error {
+			if req.Command == nil || !req.Command.Restricted {
+				return next(req)
+			}
+
+			err := allow(req)
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrUnauthorized, req.Command.Usage(), err)
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// RateLimit allows each peer as many messages as limiter allows. Messages
+// that match no command count too.
+func RateLimit(limiter *ratelimit.Limiter) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) error {
+			if !limiter.Allow(req.Peer.String(), time.Now()) {
+				return fmt.Errorf("%w: more than %d commands in %s", ErrRateLimited, limiter.Limit(), limiter.Window())
+			}
+
+			return next(req)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+)
+
+func TestRateLimitUnknownCommands(t *testing.T) {
+	peer, err := selfaccount.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := 0
+	router := NewRouter()
+	router.Use(
+		RateLimit(ratelimit.New(2, time.Minute)),
+		Authorize(func(req *Request) error { return errors.New("not a contact") }),
+	)
+
+	err = router.Register(Command{Name: "HELP", Handler: func(req *Request) error {
+		ran++
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		text string
+		want error
+	}{
+		{"nonsense", ErrUnknownCommand},
+		{"more nonsense", ErrUnknownCommand},
+		{"HELP", ErrRateLimited},
+		{"nonsense", ErrRateLimited},
+	} {
+		err := router.Route(&Request{Peer: peer, Text: tc.text})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("message %d %q = %v, want %v", i+1, tc.text, err, tc.want)
+		}
+	}
+
+	if ran != 0 {
+		t.Errorf("the command ran %d times over the limit", ran)
+	}
+}
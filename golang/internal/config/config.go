@@ -0,0 +1,971 @@
+// Package config loads the server configuration. Values are layered in the
+// order defaults, configuration file, environment variables and command line
+// flags, each overriding the one before.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration.
+type Config struct {
+	Environment string      `yaml:"environment"`
+	LogLevel    string      `yaml:"log_level"`
+	Store       Store       `yaml:"store"`
+	Contacts    Contacts    `yaml:"contacts"`
+	HTTP        HTTP        `yaml:"http"`
+	GRPC        GRPC        `yaml:"grpc"`
+	Links       Links       `yaml:"links"`
+	Connection  Connection  `yaml:"connection"`
+	Signing     Signing     `yaml:"signing"`
+	Credentials Credentials `yaml:"credentials"`
+	Chat        Chat        `yaml:"chat"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	OIDC        OIDC        `yaml:"oidc"`
+}
+
+// Store configures where account state is kept.
+type Store struct {
+	Path      string `yaml:"path"`
+	KeyFile   string `yaml:"key_file"`
+	Ephemeral bool   `yaml:"ephemeral"`
+}
+
+// Contacts configures the database of connected peers. It is kept in
+// memory when the store is ephemeral.
+type Contacts struct {
+	Path string `yaml:"path"`
+}
+
+// HTTP configures the HTTP server. It is disabled when Addr is empty. The
+// API that lets backend systems manage contacts, start flows, look up
+// invitations and inboxes and read metrics is served only when APIToken is
+// set, and requires it as a bearer token.
+type HTTP struct {
+	Addr      string        `yaml:"addr"`
+	APIToken  string        `yaml:"api_token"`
+	RateLimit HTTPRateLimit `yaml:"rate_limit"`
+}
+
+// HTTPRateLimit limits how many connection requests, sessions and logins
+// each client address can start within a window.
+type HTTPRateLimit struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+}
+
+// GRPC configures the gRPC service. It is disabled when Addr is empty, and
+// requires Token as a bearer token. It serves TLS with the PEM certificate
+// and key in CertFile and KeyFile when they are set.
+type GRPC struct {
+	Addr     string `yaml:"addr"`
+	Token    string `yaml:"token"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Links configures the app links handed out next to QR codes. The base URL
+// must be one the Self app is registered to open, so it has no default, and
+// no links are handed out until it is set.
+type Links struct {
+	BaseURL string `yaml:"base_url"`
+}
+
+// Connection configures the connection QR codes handed out to users.
+type Connection struct {
+	QRExpiry          time.Duration `yaml:"qr_expiry"`
+	InboxReapInterval time.Duration `yaml:"inbox_reap_interval"`
+	EstablishRetries  int           `yaml:"establish_retries"`
+	RetryBackoff      time.Duration `yaml:"retry_backoff"`
+}
+
+// Signing configures document signing requests.
+type Signing struct {
+	RequestExpiry  time.Duration `yaml:"request_expiry"`
+	CredentialType string        `yaml:"credential_type"`
+}
+
+// Credentials configures the credentials the server requests and issues.
+type Credentials struct {
+	RequestExpiry    time.Duration                   `yaml:"request_expiry"`
+	PresentationType string                          `yaml:"presentation_type"`
+	CustomType       string                          `yaml:"custom_type"`
+	CustomClaims     map[string]any                  `yaml:"custom_claims"`
+	Definitions      map[string]CredentialDefinition `yaml:"definitions"`
+	Trust            Trust                           `yaml:"trust"`
+	// ClockSkew is tolerated when checking the validity of credentials.
+	ClockSkew time.Duration `yaml:"clock_skew"`
+}
+
+// Trust configures whose credentials are accepted. Issuers are the addresses
+// credentials name as their issuer, either a signing key or an identity
+// document address; "self" stands for this server.
+type Trust struct {
+	// Issuers lists the issuers allowed for each credential type.
+	// Credentials of other types are only trusted from the issuers of the
+	// definition they answer, or from Allow.
+	Issuers map[string][]string `yaml:"issuers"`
+	// AnyIssuer lists the credential types accepted from any issuer.
+	AnyIssuer []string `yaml:"any_issuer"`
+	// Allow lists issuers trusted for every type.
+	Allow []string `yaml:"allow"`
+	// Deny lists issuers never trusted.
+	Deny []string `yaml:"deny"`
+	// AllowSelfIssued accepts credentials issued by the peer presenting them.
+	AllowSelfIssued bool `yaml:"allow_self_issued"`
+}
+
+// CredentialDefinition describes the credential a request asks for: a
+// credential of Type, with every Require field set, satisfying Where.
+// Issuers, if set, replaces the issuers allowed for the type by
+// credentials.trust.issuers, and DenyIssuers adds to credentials.trust.deny.
+// MaxAge, if set, rejects credentials that became valid longer ago.
+type CredentialDefinition struct {
+	Description string        `yaml:"description"`
+	Type        string        `yaml:"type"`
+	Require     []string      `yaml:"require"`
+	Where       *Condition    `yaml:"where"`
+	Issuers     []string      `yaml:"issuers"`
+	DenyIssuers []string      `yaml:"deny_issuers"`
+	MaxAge      time.Duration `yaml:"max_age"`
+}
+
+// Condition is a boolean expression over credential fields. It is either a
+// combination of conditions (And, Or or Not) or a test of Field with exactly
+// one of Present, Equals or Contains.
+type Condition struct {
+	And []Condition `yaml:"and"`
+	Or  []Condition `yaml:"or"`
+	Not *Condition  `yaml:"not"`
+
+	Field    string  `yaml:"field"`
+	Present  *bool   `yaml:"present"`
+	Equals   *string `yaml:"equals"`
+	Contains *string `yaml:"contains"`
+}
+
+// Chat configures the chat commands the server responds to.
+type Chat struct {
+	Commands            Commands      `yaml:"commands"`
+	RateLimit           RateLimit     `yaml:"rate_limit"`
+	ConversationTimeout time.Duration `yaml:"conversation_timeout"`
+}
+
+// RateLimit limits how many chat commands a peer can send within a window.
+// Messages that match no command count as well.
+type RateLimit struct {
+	Commands int           `yaml:"commands"`
+	Window   time.Duration `yaml:"window"`
+}
+
+// Webhooks configures the delivery of server events to HTTP endpoints.
+// Deliveries are queued in a SQLite database at QueuePath, kept in the
+// temporary store when the store is ephemeral, and retried with a backoff
+// that starts at InitialBackoff and doubles up to MaxBackoff. Deliveries
+// that fail MaxAttempts times are kept as dead letters until replayed.
+type Webhooks struct {
+	Endpoints      []WebhookEndpoint `yaml:"endpoints"`
+	QueuePath      string            `yaml:"queue_path"`
+	MaxAttempts    int               `yaml:"max_attempts"`
+	InitialBackoff time.Duration     `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration     `yaml:"max_backoff"`
+	Timeout        time.Duration     `yaml:"timeout"`
+}
+
+// WebhookEndpoint is a URL that receives the events listed in Events, or
+// every event if Events is empty. Payloads are signed with Secret.
+type WebhookEndpoint struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// OIDC configures the OpenID Connect provider that lets web apps log users
+// in with Self. It is served by the HTTP server when Clients is not empty.
+// Scopes maps each supported scope to the credential definitions a login
+// with that scope asks for. ID tokens are signed with the ES256 key in
+// KeyFile, created on first run and kept in the temporary store when the
+// store is ephemeral.
+type OIDC struct {
+	Issuer      string              `yaml:"issuer"`
+	KeyFile     string              `yaml:"key_file"`
+	Clients     []OIDCClient        `yaml:"clients"`
+	Scopes      map[string][]string `yaml:"scopes"`
+	LoginExpiry time.Duration       `yaml:"login_expiry"`
+	CodeExpiry  time.Duration       `yaml:"code_expiry"`
+	TokenExpiry time.Duration       `yaml:"token_expiry"`
+}
+
+// OIDCClient is a web app allowed to log users in. Clients without a Secret
+// are public, and rely on PKCE alone.
+type OIDCClient struct {
+	ID           string   `yaml:"id"`
+	Secret       string   `yaml:"secret"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
+// Commands holds the chat text that triggers each flow. Commands match
+// ignoring case and whitespace.
+type Commands struct {
+	Help                  string `yaml:"help"`
+	ShareCredential       string `yaml:"share_credential"`
+	RequestCredential     string `yaml:"request_credential"`
+	RequestAuth           string `yaml:"request_auth"`
+	RequestEmail          string `yaml:"request_email"`
+	RequestDocument       string `yaml:"request_document"`
+	RequestCustom         string `yaml:"request_custom"`
+	IssueCustomCredential string `yaml:"issue_custom_credential"`
+	RequestSigning        string `yaml:"request_signing"`
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		Environment: "sandbox",
+		LogLevel:    "warn",
+		Store: Store{
+			Path:    "./self-store",
+			KeyFile: "./self-store.key",
+		},
+		Contacts: Contacts{
+			Path: "./contacts.db",
+		},
+		HTTP: HTTP{
+			Addr: ":8080",
+			RateLimit: HTTPRateLimit{
+				Requests: 20,
+				Window:   time.Minute,
+			},
+		},
+		Connection: Connection{
+			QRExpiry:          30 * time.Minute,
+			InboxReapInterval: time.Minute,
+			EstablishRetries:  3,
+			RetryBackoff:      2 * time.Second,
+		},
+		Signing: Signing{
+			RequestExpiry:  24 * time.Hour,
+			CredentialType: "AgreementCredential",
+		},
+		Credentials: Credentials{
+			RequestExpiry:    15 * time.Minute,
+			ClockSkew:        time.Minute,
+			PresentationType: "CustomPresentation",
+			CustomType:       "CustomerCredential",
+			CustomClaims: map[string]any{
+				"name": "Test Name",
+			},
+			Definitions: map[string]CredentialDefinition{
+				"liveness": {
+					Description: "liveness check",
+					Type:        "liveness",
+					Require:     []string{"liveness_source_image_hash"},
+				},
+				"email": {
+					Description: "email address",
+					Type:        "email",
+					Require:     []string{"email_address"},
+				},
+				"document": {
+					Description: "passport",
+					Type:        "passport",
+					Require:     []string{"passport_document_number"},
+				},
+				"custom": {
+					Description: "custom credential",
+					Type:        "CustomerCredential",
+					Require:     []string{"name"},
+					Issuers:     []string{"self"},
+				},
+			},
+		},
+		Chat: Chat{
+			Commands: Commands{
+				Help:                  "HELP",
+				ShareCredential:       "SHARE_CREDENTIAL",
+				RequestCredential:     "REQUEST_CREDENTIAL",
+				RequestAuth:           "REQUEST_CREDENTIAL_AUTH",
+				RequestEmail:          "PROVIDE_CREDENTIAL_EMAIL",
+				RequestDocument:       "PROVIDE_CREDENTIAL_DOCUMENT",
+				RequestCustom:         "PROVIDE_CREDENTIAL_CUSTOM",
+				IssueCustomCredential: "REQUEST_GET_CUSTOM_CREDENTIAL",
+				RequestSigning:        "REQUEST_DOCUMENT_SIGNING",
+			},
+			RateLimit: RateLimit{
+				Commands: 10,
+				Window:   time.Minute,
+			},
+			ConversationTimeout: 5 * time.Minute,
+		},
+		Webhooks: Webhooks{
+			QueuePath:      "./webhooks.db",
+			MaxAttempts:    8,
+			InitialBackoff: 5 * time.Second,
+			MaxBackoff:     time.Hour,
+			Timeout:        10 * time.Second,
+		},
+		OIDC: OIDC{
+			KeyFile: "./oidc-key.pem",
+			Scopes: map[string][]string{
+				"openid": {"liveness"},
+				"email":  {"email"},
+			},
+			LoginExpiry: 10 * time.Minute,
+			CodeExpiry:  time.Minute,
+			TokenExpiry: time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path (if
+// path is not empty), the SELF_* environment variables and any flags set on
+// fs. The flags must have been registered with RegisterFlags and parsed.
+// Maps in the file are merged with the defaults: each key replaces the
+// default entry whole, and a key set to null removes it.
+func Load(path string, fs *flag.FlagSet) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := cfg.applyEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.applyFlags(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.applySandboxTrust()
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// SandboxAnyIssuer lists the credential types accepted from any issuer in the
+// sandbox when no issuers are configured, so the built-in flows work with
+// the test credentials of the sandbox app. Passports are left out: a peer
+// could sign one with any key of its own, so their issuers must be listed.
+var SandboxAnyIssuer = []string{"liveness", "email"}
+
+// applySandboxTrust accepts the SandboxAnyIssuer types from any issuer when
+// the server runs in the sandbox and credentials.trust names no issuers at
+// all. Production never trusts an issuer it was not told about.
+func (c *Config) applySandboxTrust() {
+	t := &c.Credentials.Trust
+	if len(t.Issuers) > 0 || len(t.AnyIssuer) > 0 || len(t.Allow) > 0 {
+		return
+	}
+
+	if strings.ToLower(strings.TrimSpace(c.Environment)) == "sandbox" {
+		t.AnyIssuer = slices.Clone(SandboxAnyIssuer)
+	}
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+
+	// maps are merged with the defaults key by key, so a file removes a
+	// default entry by setting it to null
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+
+	for _, key := range nullKeys(&doc, "credentials", "custom_claims") {
+		delete(c.Credentials.CustomClaims, key)
+	}
+	for _, key := range nullKeys(&doc, "credentials", "definitions") {
+		delete(c.Credentials.Definitions, key)
+	}
+	for _, key := range nullKeys(&doc, "credentials", "trust", "issuers") {
+		delete(c.Credentials.Trust.Issuers, key)
+	}
+	for _, key := range nullKeys(&doc, "oidc", "scopes") {
+		delete(c.OIDC.Scopes, key)
+	}
+
+	return nil
+}
+
+// nullKeys returns the keys set to null in the mapping found by following
+// path from the root of doc
+func nullKeys(doc *yaml.Node, path ...string) []string {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for _, name := range path {
+		node = mappingValue(node, name)
+		if node == nil {
+			return nil
+		}
+	}
+
+	var keys []string
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag == "!!null" {
+				keys = append(keys, node.Content[i].Value)
+			}
+		}
+	}
+
+	return keys
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// setting is a single value that can be overridden from the environment or
+// the command line.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"SELF_ENVIRONMENT", "environment", "Self network to use: sandbox or production", setString(func(c *Config) *string { return &c.Environment })},
+	{"SELF_LOG_LEVEL", "log-level", "SDK log level: error, warn, info, debug or trace", setString(func(c *Config) *string { return &c.LogLevel })},
+	{"SELF_STORE_PATH", "store", "path to the self-store directory", setString(func(c *Config) *string { return &c.Store.Path })},
+	{"SELF_STORAGE_KEY_FILE", "storage-key-file", "path to the hex encoded storage key file", setString(func(c *Config) *string { return &c.Store.KeyFile })},
+	{"SELF_EPHEMERAL", "ephemeral", "start from a clean, temporary self-store with a throwaway storage key", setBool(func(c *Config) *bool { return &c.Store.Ephemeral })},
+	{"SELF_CONTACTS_PATH", "contacts", "path to the SQLite contacts database", setString(func(c *Config) *string { return &c.Contacts.Path })},
+	{"SELF_HTTP_ADDR", "http-addr", "address the HTTP server listens on, empty to disable it", setString(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"SELF_API_TOKEN", "api-token", "bearer token required by the HTTP API, empty to disable it", setString(func(c *Config) *string { return &c.HTTP.APIToken })},
+	{"SELF_GRPC_ADDR", "grpc-addr", "address the gRPC service listens on, empty to disable it", setString(func(c *Config) *string { return &c.GRPC.Addr })},
+	{"SELF_GRPC_TOKEN", "grpc-token", "bearer token required by the gRPC service", setString(func(c *Config) *string { return &c.GRPC.Token })},
+	{"SELF_GRPC_CERT_FILE", "grpc-cert-file", "PEM certificate the gRPC service serves TLS with, empty for plaintext", setString(func(c *Config) *string { return &c.GRPC.CertFile })},
+	{"SELF_GRPC_KEY_FILE", "grpc-key-file", "PEM private key of the gRPC certificate", setString(func(c *Config) *string { return &c.GRPC.KeyFile })},
+	{"SELF_LINK_BASE_URL", "link-base-url", "base URL of the app links handed out with QR codes, empty to hand out none", setString(func(c *Config) *string { return &c.Links.BaseURL })},
+	{"SELF_QR_EXPIRY", "qr-expiry", "how long a connection QR code stays valid", setDuration(func(c *Config) *time.Duration { return &c.Connection.QRExpiry })},
+	{"SELF_INBOX_REAP_INTERVAL", "inbox-reap-interval", "how often expired, unused inboxes are closed", setDuration(func(c *Config) *time.Duration { return &c.Connection.InboxReapInterval })},
+	{"SELF_SIGNING_REQUEST_EXPIRY", "signing-request-expiry", "how long a document signing request stays valid", setDuration(func(c *Config) *time.Duration { return &c.Signing.RequestExpiry })},
+	{"SELF_CREDENTIAL_REQUEST_EXPIRY", "credential-request-expiry", "how long a credential request stays valid", setDuration(func(c *Config) *time.Duration { return &c.Credentials.RequestExpiry })},
+	{"SELF_OIDC_ISSUER", "oidc-issuer", "public URL of the OpenID Connect provider, as its clients reach it", setString(func(c *Config) *string { return &c.OIDC.Issuer })},
+	{"SELF_WEBHOOK_QUEUE_PATH", "webhook-queue", "path to the SQLite webhook delivery queue", setString(func(c *Config) *string { return &c.Webhooks.QueuePath })},
+}
+
+// RegisterFlags registers a flag for every setting that can be overridden on
+// the command line. Flags left unset do not override the other sources.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		if s.flag == "ephemeral" {
+			fs.Bool(s.flag, false, s.usage)
+			continue
+		}
+		fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, s := range settings {
+		value, ok := lookup(s.env)
+		if !ok {
+			continue
+		}
+
+		err := s.set(c, value)
+		if err != nil {
+			return fmt.Errorf("config: invalid %s: %w", s.env, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) applyFlags(fs *flag.FlagSet) error {
+	if fs == nil {
+		return nil
+	}
+
+	var err error
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name || err != nil {
+				continue
+			}
+
+			setErr := s.set(c, f.Value.String())
+			if setErr != nil {
+				err = fmt.Errorf("config: invalid -%s: %w", s.flag, setErr)
+			}
+		}
+	})
+
+	return err
+}
+
+func setString(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// Validate checks the configuration and reports every problem it finds.
+func (c *Config) Validate() error {
+	var errs []error
+
+	c.Environment = strings.ToLower(strings.TrimSpace(c.Environment))
+	if c.Environment != "sandbox" && c.Environment != "production" {
+		errs = append(errs, fmt.Errorf("environment: must be sandbox or production, got %q", c.Environment))
+	}
+
+	c.LogLevel = strings.ToLower(strings.TrimSpace(c.LogLevel))
+	switch c.LogLevel {
+	case "error", "warn", "info", "debug", "trace":
+	default:
+		errs = append(errs, fmt.Errorf("log_level: must be error, warn, info, debug or trace, got %q", c.LogLevel))
+	}
+
+	if c.Store.Path == "" && !c.Store.Ephemeral {
+		errs = append(errs, errors.New("store.path: must be set"))
+	}
+
+	if c.Store.KeyFile == "" && !c.Store.Ephemeral {
+		errs = append(errs, errors.New("store.key_file: must be set"))
+	}
+
+	if c.Links.BaseURL != "" {
+		if u, err := url.Parse(c.Links.BaseURL); err != nil || u.Scheme == "" {
+			errs = append(errs, fmt.Errorf("links.base_url: must be an absolute URL, got %q", c.Links.BaseURL))
+		}
+	}
+
+	if c.HTTP.RateLimit.Requests <= 0 {
+		errs = append(errs, fmt.Errorf("http.rate_limit.requests: must be positive, got %d", c.HTTP.RateLimit.Requests))
+	}
+
+	if c.HTTP.RateLimit.Window <= 0 {
+		errs = append(errs, fmt.Errorf("http.rate_limit.window: must be positive, got %s", c.HTTP.RateLimit.Window))
+	}
+
+	if c.GRPC.Addr != "" && c.GRPC.Token == "" {
+		errs = append(errs, errors.New("grpc.token: must be set when grpc.addr is set"))
+	}
+
+	if (c.GRPC.CertFile == "") != (c.GRPC.KeyFile == "") {
+		errs = append(errs, errors.New("grpc.cert_file, grpc.key_file: must be set together"))
+	}
+
+	if c.Contacts.Path == "" && !c.Store.Ephemeral {
+		errs = append(errs, errors.New("contacts.path: must be set"))
+	}
+
+	if c.Connection.QRExpiry <= 0 {
+		errs = append(errs, fmt.Errorf("connection.qr_expiry: must be positive, got %s", c.Connection.QRExpiry))
+	}
+
+	if c.Connection.InboxReapInterval <= 0 {
+		errs = append(errs, fmt.Errorf("connection.inbox_reap_interval: must be positive, got %s", c.Connection.InboxReapInterval))
+	}
+
+	if c.Connection.EstablishRetries < 0 {
+		errs = append(errs, fmt.Errorf("connection.establish_retries: must not be negative, got %d", c.Connection.EstablishRetries))
+	}
+
+	if c.Connection.RetryBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("connection.retry_backoff: must be positive, got %s", c.Connection.RetryBackoff))
+	}
+
+	if c.Signing.RequestExpiry <= 0 {
+		errs = append(errs, fmt.Errorf("signing.request_expiry: must be positive, got %s", c.Signing.RequestExpiry))
+	}
+
+	if c.Signing.CredentialType == "" {
+		errs = append(errs, errors.New("signing.credential_type: must be set"))
+	}
+
+	if c.Credentials.RequestExpiry <= 0 {
+		errs = append(errs, fmt.Errorf("credentials.request_expiry: must be positive, got %s", c.Credentials.RequestExpiry))
+	}
+
+	if c.Credentials.PresentationType == "" {
+		errs = append(errs, errors.New("credentials.presentation_type: must be set"))
+	}
+
+	if c.Credentials.CustomType == "" {
+		errs = append(errs, errors.New("credentials.custom_type: must be set"))
+	}
+
+	if len(c.Credentials.Definitions) == 0 {
+		errs = append(errs, errors.New("credentials.definitions: must not be empty"))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Credentials.Definitions)) {
+		if !definitionName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("credentials.definitions: name %q must be lower case letters, digits, - and _", name))
+		}
+		errs = append(errs, c.Credentials.Definitions[name].validate("credentials.definitions."+name)...)
+	}
+
+	errs = append(errs, c.Credentials.Trust.validate()...)
+
+	if c.Credentials.ClockSkew < 0 {
+		errs = append(errs, fmt.Errorf("credentials.clock_skew: must not be negative, got %s", c.Credentials.ClockSkew))
+	}
+
+	errs = append(errs, c.Chat.Commands.validate()...)
+
+	if c.Chat.RateLimit.Commands <= 0 {
+		errs = append(errs, fmt.Errorf("chat.rate_limit.commands: must be positive, got %d", c.Chat.RateLimit.Commands))
+	}
+
+	if c.Chat.RateLimit.Window <= 0 {
+		errs = append(errs, fmt.Errorf("chat.rate_limit.window: must be positive, got %s", c.Chat.RateLimit.Window))
+	}
+
+	if c.Chat.ConversationTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("chat.conversation_timeout: must be positive, got %s", c.Chat.ConversationTimeout))
+	}
+
+	errs = append(errs, c.Webhooks.validate(c.Store.Ephemeral)...)
+
+	if len(c.OIDC.Clients) > 0 {
+		if c.HTTP.Addr == "" {
+			errs = append(errs, errors.New("oidc.clients: need the HTTP server, set http.addr"))
+		}
+		errs = append(errs, c.OIDC.validate(c.Store.Ephemeral, c.Credentials.Definitions)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o OIDC) validate(ephemeral bool, definitions map[string]CredentialDefinition) []error {
+	var errs []error
+
+	if u, err := url.Parse(o.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		errs = append(errs, fmt.Errorf("oidc.issuer: must be an http or https URL without a path, got %q", o.Issuer))
+	}
+
+	if o.KeyFile == "" && !ephemeral {
+		errs = append(errs, errors.New("oidc.key_file: must be set"))
+	}
+
+	seen := make(map[string]bool)
+	for i, client := range o.Clients {
+		path := fmt.Sprintf("oidc.clients[%d]", i)
+
+		if client.ID == "" {
+			errs = append(errs, fmt.Errorf("%s.id: must be set", path))
+		} else if seen[client.ID] {
+			errs = append(errs, fmt.Errorf("%s.id: %q is used by another client", path, client.ID))
+		}
+		seen[client.ID] = true
+
+		if len(client.RedirectURIs) == 0 {
+			errs = append(errs, fmt.Errorf("%s.redirect_uris: must not be empty", path))
+		}
+
+		for j, uri := range client.RedirectURIs {
+			if u, err := url.Parse(uri); err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+				errs = append(errs, fmt.Errorf("%s.redirect_uris[%d]: must be an absolute URL without a fragment, got %q", path, j, uri))
+			}
+		}
+	}
+
+	if len(o.Scopes["openid"]) == 0 {
+		errs = append(errs, errors.New("oidc.scopes.openid: must request at least one credential definition"))
+	}
+
+	for _, scope := range slices.Sorted(maps.Keys(o.Scopes)) {
+		for i, name := range o.Scopes[scope] {
+			if _, ok := definitions[name]; !ok {
+				errs = append(errs, fmt.Errorf("oidc.scopes.%s[%d]: %q is not in credentials.definitions", scope, i, name))
+			}
+		}
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"login_expiry", o.LoginExpiry},
+		{"code_expiry", o.CodeExpiry},
+		{"token_expiry", o.TokenExpiry},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("oidc.%s: must be positive, got %s", d.name, d.value))
+		}
+	}
+
+	return errs
+}
+
+func (w Webhooks) validate(ephemeral bool) []error {
+	var errs []error
+
+	for i, endpoint := range w.Endpoints {
+		path := fmt.Sprintf("webhooks.endpoints[%d]", i)
+
+		if u, err := url.Parse(endpoint.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s.url: must be an absolute http or https URL, got %q", path, endpoint.URL))
+		}
+
+		if endpoint.Secret == "" {
+			errs = append(errs, fmt.Errorf("%s.secret: must be set", path))
+		}
+
+		for j, event := range endpoint.Events {
+			if strings.TrimSpace(event) == "" {
+				errs = append(errs, fmt.Errorf("%s.events[%d]: must not be empty", path, j))
+			}
+		}
+	}
+
+	if w.QueuePath == "" && !ephemeral {
+		errs = append(errs, errors.New("webhooks.queue_path: must be set"))
+	}
+
+	if w.MaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts: must be positive, got %d", w.MaxAttempts))
+	}
+
+	if w.InitialBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.initial_backoff: must be positive, got %s", w.InitialBackoff))
+	}
+
+	if w.MaxBackoff < w.InitialBackoff {
+		errs = append(errs, fmt.Errorf("webhooks.max_backoff: must be at least webhooks.initial_backoff, got %s", w.MaxBackoff))
+	}
+
+	if w.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout: must be positive, got %s", w.Timeout))
+	}
+
+	return errs
+}
+
+func (c Commands) validate() []error {
+	var errs []error
+
+	seen := make(map[string]string)
+	for _, command := range []struct{ name, value string }{
+		{"help", c.Help},
+		{"share_credential", c.ShareCredential},
+		{"request_credential", c.RequestCredential},
+		{"request_auth", c.RequestAuth},
+		{"request_email", c.RequestEmail},
+		{"request_document", c.RequestDocument},
+		{"request_custom", c.RequestCustom},
+		{"issue_custom_credential", c.IssueCustomCredential},
+		{"request_signing", c.RequestSigning},
+	} {
+		// commands match ignoring case and whitespace
+		key := strings.ToUpper(strings.Join(strings.Fields(command.value), " "))
+
+		if key == "" {
+			errs = append(errs, fmt.Errorf("chat.commands.%s: must be set", command.name))
+			continue
+		}
+
+		if other, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("chat.commands.%s: %q is already used by chat.commands.%s", command.name, command.value, other))
+			continue
+		}
+
+		seen[key] = command.name
+	}
+
+	return errs
+}
+
+func (t Trust) validate() []error {
+	var errs []error
+
+	for _, credentialType := range slices.Sorted(maps.Keys(t.Issuers)) {
+		path := "credentials.trust.issuers." + credentialType
+		if len(t.Issuers[credentialType]) == 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be empty, list the type under credentials.trust.any_issuer to accept any issuer", path))
+		}
+		errs = append(errs, validateIssuers(path, t.Issuers[credentialType])...)
+	}
+
+	for i, credentialType := range t.AnyIssuer {
+		path := fmt.Sprintf("credentials.trust.any_issuer[%d]", i)
+		if strings.TrimSpace(credentialType) == "" {
+			errs = append(errs, fmt.Errorf("%s: must not be empty", path))
+		}
+		if _, ok := t.Issuers[credentialType]; ok {
+			errs = append(errs, fmt.Errorf("%s: %s also has issuers listed, remove one of them", path, credentialType))
+		}
+	}
+
+	errs = append(errs, validateIssuers("credentials.trust.allow", t.Allow)...)
+	errs = append(errs, validateIssuers("credentials.trust.deny", t.Deny)...)
+
+	return errs
+}
+
+func validateIssuers(path string, issuers []string) []error {
+	var errs []error
+
+	for i, issuer := range issuers {
+		if strings.TrimSpace(issuer) == "" {
+			errs = append(errs, fmt.Errorf("%s[%d]: must not be empty", path, i))
+		}
+	}
+
+	return errs
+}
+
+var definitionName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func (d CredentialDefinition) validate(path string) []error {
+	var errs []error
+
+	if d.Type == "" {
+		errs = append(errs, fmt.Errorf("%s.type: must be set", path))
+	}
+
+	for i, field := range d.Require {
+		if field == "" {
+			errs = append(errs, fmt.Errorf("%s.require[%d]: must not be empty", path, i))
+		}
+	}
+
+	errs = append(errs, validateIssuers(path+".issuers", d.Issuers)...)
+	errs = append(errs, validateIssuers(path+".deny_issuers", d.DenyIssuers)...)
+
+	if d.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_age: must not be negative, got %s", path, d.MaxAge))
+	}
+
+	if d.Where != nil {
+		errs = append(errs, d.Where.validate(path+".where")...)
+	}
+
+	return errs
+}
+
+func (c Condition) validate(path string) []error {
+	var errs []error
+
+	kinds := 0
+	if c.And != nil {
+		kinds++
+	}
+	if c.Or != nil {
+		kinds++
+	}
+	if c.Not != nil {
+		kinds++
+	}
+	if c.Field != "" {
+		kinds++
+	}
+
+	if kinds != 1 {
+		return []error{fmt.Errorf("%s: must have exactly one of and, or, not or field", path)}
+	}
+
+	if c.Field == "" && (c.Present != nil || c.Equals != nil || c.Contains != nil) {
+		return []error{fmt.Errorf("%s: present, equals and contains need a field", path)}
+	}
+
+	switch {
+	case c.And != nil:
+		if len(c.And) == 0 {
+			errs = append(errs, fmt.Errorf("%s.and: must not be empty", path))
+		}
+		for i, sub := range c.And {
+			errs = append(errs, sub.validate(fmt.Sprintf("%s.and[%d]", path, i))...)
+		}
+	case c.Or != nil:
+		if len(c.Or) == 0 {
+			errs = append(errs, fmt.Errorf("%s.or: must not be empty", path))
+		}
+		for i, sub := range c.Or {
+			errs = append(errs, sub.validate(fmt.Sprintf("%s.or[%d]", path, i))...)
+		}
+	case c.Not != nil:
+		errs = append(errs, c.Not.validate(path+".not")...)
+	default:
+		operators := 0
+		for _, set := range []bool{c.Present != nil, c.Equals != nil, c.Contains != nil} {
+			if set {
+				operators++
+			}
+		}
+		if operators != 1 {
+			errs = append(errs, fmt.Errorf("%s: field %q must have exactly one of present, equals or contains", path, c.Field))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxTrust(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		environment string
+		trust       Trust
+		want        []string
+	}{
+		{"sandbox without trust", "sandbox", Trust{}, SandboxAnyIssuer},
+		{"sandbox with empty lists", " Sandbox ", Trust{Issuers: map[string][]string{}, AnyIssuer: []string{}}, SandboxAnyIssuer},
+		{"sandbox with issuers", "sandbox", Trust{Issuers: map[string][]string{"email": {"self"}}}, nil},
+		{"sandbox with any issuer", "sandbox", Trust{AnyIssuer: []string{"email"}}, []string{"email"}},
+		{"sandbox with allow", "sandbox", Trust{Allow: []string{"self"}}, nil},
+		{"production without trust", "production", Trust{}, nil},
+	} {
+		cfg := Default()
+		cfg.Environment = tc.environment
+		cfg.Credentials.Trust = tc.trust
+
+		cfg.applySandboxTrust()
+
+		if got := cfg.Credentials.Trust.AnyIssuer; !slices.Equal(got, tc.want) {
+			t.Errorf("%s: any_issuer = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSandboxTrustExcludesPassports(t *testing.T) {
+	// anyone can sign a passport credential with a key they control
+	if slices.Contains(SandboxAnyIssuer, "passport") {
+		t.Errorf("sandbox accepts passports from any issuer: %v", SandboxAnyIssuer)
+	}
+}
+
+// writeTestConfig writes a configuration file and returns its path
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestFlags returns the registered flags parsed from args
+func newTestFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeTestConfig(t, `
+log_level: info
+store:
+  path: /file/store
+contacts:
+  path: /file/contacts.db
+connection:
+  qr_expiry: 10m
+`)
+
+	t.Setenv("SELF_STORE_PATH", "/env/store")
+	t.Setenv("SELF_CONTACTS_PATH", "/env/contacts.db")
+	t.Setenv("SELF_QR_EXPIRY", "20m")
+
+	cfg, err := Load(path, newTestFlags(t, "-contacts", "/flag/contacts.db", "-qr-expiry", "30s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, got, want string
+	}{
+		{"environment (default)", cfg.Environment, "sandbox"},
+		{"log_level (file)", cfg.LogLevel, "info"},
+		{"store.path (env over file)", cfg.Store.Path, "/env/store"},
+		{"contacts.path (flag over env)", cfg.Contacts.Path, "/flag/contacts.db"},
+		{"connection.qr_expiry (flag over env)", cfg.Connection.QRExpiry.String(), "30s"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadEphemeralFlag(t *testing.T) {
+	t.Setenv("SELF_EPHEMERAL", "false")
+
+	cfg, err := Load("", newTestFlags(t, "-ephemeral"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Store.Ephemeral {
+		t.Error("-ephemeral did not override SELF_EPHEMERAL")
+	}
+}
+
+func TestLoadMaps(t *testing.T) {
+	path := writeTestConfig(t, `
+credentials:
+  custom_claims:
+    name: null
+    tier: gold
+  definitions:
+    document: null
+    email:
+      type: email
+      require: [email_address]
+oidc:
+  scopes:
+    email: ~
+    profile: [email]
+`)
+
+	cfg, err := Load(path, newTestFlags(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries are added to the defaults, and null removes a default
+	if claims := cfg.Credentials.CustomClaims; len(claims) != 1 || claims["tier"] != "gold" {
+		t.Errorf("custom_claims = %v, want only tier", claims)
+	}
+
+	scopes := slices.Sorted(maps.Keys(cfg.OIDC.Scopes))
+	if !slices.Equal(scopes, []string{"openid", "profile"}) {
+		t.Errorf("oidc.scopes = %v, want openid and profile", scopes)
+	}
+
+	definitions := cfg.Credentials.Definitions
+	if _, ok := definitions["document"]; ok {
+		t.Error("the document definition set to null was kept")
+	}
+	if _, ok := definitions["liveness"]; !ok {
+		t.Error("the liveness definition was dropped")
+	}
+
+	// a redefined entry replaces the default one whole
+	if email := definitions["email"]; email.Description != "" || email.Type != "email" {
+		t.Errorf("email definition = %+v, want it replaced", email)
+	}
+}
+
+func TestLoadDurations(t *testing.T) {
+	path := writeTestConfig(t, `
+credentials:
+  clock_skew: 90s
+chat:
+  conversation_timeout: 1h30m
+`)
+
+	t.Setenv("SELF_SIGNING_REQUEST_EXPIRY", "48h")
+
+	cfg, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"credentials.clock_skew", cfg.Credentials.ClockSkew, 90 * time.Second},
+		{"chat.conversation_timeout", cfg.Chat.ConversationTimeout, 90 * time.Minute},
+		{"signing.request_expiry", cfg.Signing.RequestExpiry, 48 * time.Hour},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %s, want %s", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "duration without a unit in the file", file: "connection:\n  qr_expiry: 10\n", want: "failed to parse"},
+		{name: "unknown field in the file", file: "connection:\n  qr_expiri: 10m\n", want: "failed to parse"},
+		{name: "duration without a unit in the env", env: map[string]string{"SELF_QR_EXPIRY": "10"}, want: "invalid SELF_QR_EXPIRY"},
+		{name: "bool in the env", env: map[string]string{"SELF_EPHEMERAL": "maybe"}, want: "invalid SELF_EPHEMERAL"},
+		{name: "duration in a flag", args: []string{"-credential-request-expiry", "soon"}, want: "invalid -credential-request-expiry"},
+		{name: "invalid value after loading", env: map[string]string{"SELF_QR_EXPIRY": "-1m"}, want: "connection.qr_expiry: must be positive"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			if tc.file != "" {
+				path = writeTestConfig(t, tc.file)
+			}
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load(path, newTestFlags(t, tc.args...))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("the defaults do not validate: %v", err)
+	}
+
+	empty := ""
+	yes := true
+
+	for _, tc := range []struct {
+		want   string
+		change func(c *Config)
+	}{
+		{"environment: must be sandbox or production", func(c *Config) { c.Environment = "staging" }},
+		{"log_level: must be", func(c *Config) { c.LogLevel = "verbose" }},
+		{"store.path: must be set", func(c *Config) { c.Store.Path = "" }},
+		{"store.key_file: must be set", func(c *Config) { c.Store.KeyFile = "" }},
+		{"links.base_url: must be an absolute URL", func(c *Config) { c.Links.BaseURL = "links.example.com" }},
+		{"http.rate_limit.requests: must be positive", func(c *Config) { c.HTTP.RateLimit.Requests = 0 }},
+		{"http.rate_limit.window: must be positive", func(c *Config) { c.HTTP.RateLimit.Window = 0 }},
+		{"grpc.token: must be set", func(c *Config) { c.GRPC.Addr = ":9090" }},
+		{"grpc.cert_file, grpc.key_file: must be set together", func(c *Config) { c.GRPC.CertFile = "cert.pem" }},
+		{"contacts.path: must be set", func(c *Config) { c.Contacts.Path = "" }},
+		{"connection.qr_expiry: must be positive", func(c *Config) { c.Connection.QRExpiry = 0 }},
+		{"connection.inbox_reap_interval: must be positive", func(c *Config) { c.Connection.InboxReapInterval = 0 }},
+		{"connection.establish_retries: must not be negative", func(c *Config) { c.Connection.EstablishRetries = -1 }},
+		{"connection.retry_backoff: must be positive", func(c *Config) { c.Connection.RetryBackoff = 0 }},
+		{"signing.request_expiry: must be positive", func(c *Config) { c.Signing.RequestExpiry = 0 }},
+		{"signing.credential_type: must be set", func(c *Config) { c.Signing.CredentialType = "" }},
+		{"credentials.request_expiry: must be positive", func(c *Config) { c.Credentials.RequestExpiry = 0 }},
+		{"credentials.presentation_type: must be set", func(c *Config) { c.Credentials.PresentationType = "" }},
+		{"credentials.custom_type: must be set", func(c *Config) { c.Credentials.CustomType = "" }},
+		{"credentials.definitions: must not be empty", func(c *Config) { c.Credentials.Definitions = nil }},
+		{`credentials.definitions: name "Email"`, func(c *Config) { c.Credentials.Definitions["Email"] = CredentialDefinition{Type: "email"} }},
+		{"credentials.definitions.email.type: must be set", func(c *Config) { c.Credentials.Definitions["email"] = CredentialDefinition{} }},
+		{"credentials.definitions.email.require[0]: must not be empty", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Require: []string{""}}
+		}},
+		{"credentials.definitions.email.issuers[0]: must not be empty", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Issuers: []string{" "}}
+		}},
+		{"credentials.definitions.email.deny_issuers[0]: must not be empty", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", DenyIssuers: []string{""}}
+		}},
+		{"credentials.definitions.email.max_age: must not be negative", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", MaxAge: -time.Hour}
+		}},
+		{"credentials.definitions.email.where: must have exactly one of", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Where: &Condition{}}
+		}},
+		{"credentials.definitions.email.where.and: must not be empty", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Where: &Condition{And: []Condition{}}}
+		}},
+		{"credentials.definitions.email.where.not: present, equals and contains need a field", func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Where: &Condition{Not: &Condition{Or: []Condition{}, Present: &yes}}}
+		}},
+		{`credentials.definitions.email.where.or[0]: field "email_address" must have exactly one of`, func(c *Config) {
+			c.Credentials.Definitions["email"] = CredentialDefinition{Type: "email", Where: &Condition{Or: []Condition{{Field: "email_address", Present: &yes, Equals: &empty}}}}
+		}},
+		{"credentials.trust.issuers.email: must not be empty", func(c *Config) { c.Credentials.Trust.Issuers = map[string][]string{"email": nil} }},
+		{"credentials.trust.any_issuer[0]: must not be empty", func(c *Config) { c.Credentials.Trust.AnyIssuer = []string{" "} }},
+		{"credentials.trust.any_issuer[0]: email also has issuers listed", func(c *Config) {
+			c.Credentials.Trust.Issuers = map[string][]string{"email": {"self"}}
+			c.Credentials.Trust.AnyIssuer = []string{"email"}
+		}},
+		{"credentials.trust.allow[0]: must not be empty", func(c *Config) { c.Credentials.Trust.Allow = []string{""} }},
+		{"credentials.trust.deny[0]: must not be empty", func(c *Config) { c.Credentials.Trust.Deny = []string{""} }},
+		{"credentials.clock_skew: must not be negative", func(c *Config) { c.Credentials.ClockSkew = -time.Second }},
+		{"chat.commands.help: must be set", func(c *Config) { c.Chat.Commands.Help = " " }},
+		{"chat.commands.request_email: \"help\" is already used by chat.commands.help", func(c *Config) { c.Chat.Commands.RequestEmail = "help" }},
+		{"chat.rate_limit.commands: must be positive", func(c *Config) { c.Chat.RateLimit.Commands = 0 }},
+		{"chat.rate_limit.window: must be positive", func(c *Config) { c.Chat.RateLimit.Window = 0 }},
+		{"chat.conversation_timeout: must be positive", func(c *Config) { c.Chat.ConversationTimeout = 0 }},
+		{"webhooks.endpoints[0].url: must be an absolute http or https URL", func(c *Config) {
+			c.Webhooks.Endpoints = []WebhookEndpoint{{URL: "ftp://example.com", Secret: "secret"}}
+		}},
+		{"webhooks.endpoints[0].secret: must be set", func(c *Config) {
+			c.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://example.com"}}
+		}},
+		{"webhooks.endpoints[0].events[0]: must not be empty", func(c *Config) {
+			c.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://example.com", Secret: "secret", Events: []string{""}}}
+		}},
+		{"webhooks.queue_path: must be set", func(c *Config) { c.Webhooks.QueuePath = "" }},
+		{"webhooks.max_attempts: must be positive", func(c *Config) { c.Webhooks.MaxAttempts = 0 }},
+		{"webhooks.initial_backoff: must be positive", func(c *Config) { c.Webhooks.InitialBackoff = 0 }},
+		{"webhooks.max_backoff: must be at least webhooks.initial_backoff", func(c *Config) { c.Webhooks.MaxBackoff = time.Second }},
+		{"webhooks.timeout: must be positive", func(c *Config) { c.Webhooks.Timeout = 0 }},
+		{"oidc.clients: need the HTTP server", func(c *Config) {
+			c.HTTP.Addr = ""
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+		}},
+		{"oidc.issuer: must be an http or https URL without a path", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com/oidc"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+		}},
+		{"oidc.key_file: must be set", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.KeyFile = ""
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+		}},
+		{"oidc.clients[0].id: must be set", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{RedirectURIs: []string{"https://app.example.com/callback"}}}
+		}},
+		{`oidc.clients[1].id: "app" is used by another client`, func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{
+				{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}},
+				{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}},
+			}
+		}},
+		{"oidc.clients[0].redirect_uris: must not be empty", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app"}}
+		}},
+		{"oidc.clients[0].redirect_uris[0]: must be an absolute URL without a fragment", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback#done"}}}
+		}},
+		{"oidc.scopes.openid: must request at least one credential definition", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+			c.OIDC.Scopes = map[string][]string{"openid": nil}
+		}},
+		{`oidc.scopes.email[0]: "phone" is not in credentials.definitions`, func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+			c.OIDC.Scopes["email"] = []string{"phone"}
+		}},
+		{"oidc.token_expiry: must be positive", func(c *Config) {
+			c.OIDC.Issuer = "https://self.example.com"
+			c.OIDC.Clients = []OIDCClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}}}
+			c.OIDC.TokenExpiry = 0
+		}},
+	} {
+		cfg := Default()
+		tc.change(cfg)
+
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Validate = %v, want an error containing %q", err, tc.want)
+		}
+	}
+}
+
+func TestValidateEphemeral(t *testing.T) {
+	cfg := Default()
+	cfg.Store = Store{Ephemeral: true}
+	cfg.Contacts.Path = ""
+	cfg.Webhooks.QueuePath = ""
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("ephemeral store without paths = %v, want no error", err)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Environment = "staging"
+	cfg.Connection.QRExpiry = 0
+	cfg.Chat.RateLimit.Commands = 0
+
+	err := cfg.Validate()
+	for _, want := range []string{"environment:", "connection.qr_expiry:", "chat.rate_limit.commands:"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to report %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package contacts records the peers the server is connected to and the
+// facts they have verified.
+package contacts
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a contact does not exist.
+var ErrNotFound = errors.New("contacts: not found")
+
+// Contact is a peer the server has an established connection with.
+type Contact struct {
+	PeerAddress       string          `json:"peer_address"`
+	ConnectionAddress string          `json:"connection_address"`
+	InvitationID      string          `json:"invitation_id,omitempty"`
+	ConnectedAt       time.Time       `json:"connected_at"`
+	Facts             map[string]Fact `json:"facts,omitempty"`
+}
+
+// Fact is a claim about a contact taken from a verified credential.
+type Fact struct {
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// Store persists contacts.
+type Store interface {
+	// Save creates or replaces the connection details of a contact. Facts
+	// already recorded for the contact are kept.
+	Save(ctx context.Context, contact Contact) error
+	// Get returns the contact for a peer address.
+	Get(ctx context.Context, peerAddress string) (Contact, error)
+	// List returns every contact, oldest connection first.
+	List(ctx context.Context) ([]Contact, error)
+	// Delete removes a contact and its facts.
+	Delete(ctx context.Context, peerAddress string) error
+	// SetFacts records verified facts for an existing contact, replacing
+	// facts with the same name.
+	SetFacts(ctx context.Context, peerAddress string, facts ...Fact) error
+	// Close releases the store.
+	Close() error
+}
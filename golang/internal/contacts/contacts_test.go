@@ -0,0 +1,174 @@
+package contacts
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour every Store shares
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	connectedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err := store.Get(ctx, "alice")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing contact = %v, want %v", err, ErrNotFound)
+	}
+
+	for _, contact := range []Contact{
+		{PeerAddress: "bob", ConnectionAddress: "group-b", ConnectedAt: connectedAt.Add(time.Hour)},
+		{PeerAddress: "alice", ConnectionAddress: "group-a", InvitationID: "inv-1", ConnectedAt: connectedAt},
+	} {
+		err = store.Save(ctx, contact)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verifiedAt := connectedAt.Add(time.Minute)
+	err = store.SetFacts(ctx, "alice",
+		Fact{Name: "email_address", Value: "alice@example.com", VerifiedAt: verifiedAt},
+		Fact{Name: "name", Value: "Alice", VerifiedAt: verifiedAt},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// facts with the same name are replaced
+	err = store.SetFacts(ctx, "alice", Fact{Name: "email_address", Value: "alice@example.org", VerifiedAt: verifiedAt.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SetFacts(ctx, "carol", Fact{Name: "name", Value: "Carol", VerifiedAt: verifiedAt})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetFacts for a missing contact = %v, want %v", err, ErrNotFound)
+	}
+
+	// saving again updates the connection and keeps the facts
+	err = store.Save(ctx, Contact{PeerAddress: "alice", ConnectionAddress: "group-a2", InvitationID: "inv-2", ConnectedAt: connectedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := store.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alice.ConnectionAddress != "group-a2" || alice.InvitationID != "inv-2" || !alice.ConnectedAt.Equal(connectedAt) {
+		t.Errorf("alice = %+v", alice)
+	}
+
+	wantFacts := map[string]Fact{
+		"email_address": {Name: "email_address", Value: "alice@example.org", VerifiedAt: verifiedAt.Add(time.Minute)},
+		"name":          {Name: "name", Value: "Alice", VerifiedAt: verifiedAt},
+	}
+	if len(alice.Facts) != len(wantFacts) {
+		t.Errorf("alice's facts = %+v, want %+v", alice.Facts, wantFacts)
+	}
+	for name, want := range wantFacts {
+		got := alice.Facts[name]
+		if got.Name != want.Name || got.Value != want.Value || !got.VerifiedAt.Equal(want.VerifiedAt) {
+			t.Errorf("alice's %s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].PeerAddress != "alice" || list[1].PeerAddress != "bob" {
+		t.Fatalf("List = %+v, want alice then bob", list)
+	}
+	if len(list[0].Facts) != 2 || len(list[1].Facts) != 0 {
+		t.Errorf("listed facts = %+v and %+v", list[0].Facts, list[1].Facts)
+	}
+
+	err = store.Delete(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of a missing contact = %v, want %v", err, ErrNotFound)
+	}
+
+	// a contact saved again after being deleted starts without facts
+	err = store.Save(ctx, Contact{PeerAddress: "alice", ConnectionAddress: "group-a3", ConnectedAt: connectedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err = store.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alice.Facts) != 0 {
+		t.Errorf("facts survived deleting the contact: %+v", alice.Facts)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	testStore(t, store)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "contacts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	testStore(t, store)
+}
+
+func TestSQLiteStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "contacts.db")
+
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Save(ctx, Contact{PeerAddress: "alice", ConnectionAddress: "group-a", ConnectedAt: time.Now()})
+	if err == nil {
+		err = store.SetFacts(ctx, "alice", Fact{Name: "name", Value: "Alice", VerifiedAt: time.Now()})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// opening an existing database keeps its contents
+	store, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	alice, err := store.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Facts["name"].Value != "Alice" {
+		t.Errorf("alice after reopening = %+v", alice)
+	}
+}
+
+func TestOpenSQLiteInvalidPath(t *testing.T) {
+	_, err := OpenSQLite(filepath.Join(t.TempDir(), "missing", "contacts.db"))
+	if err == nil {
+		t.Error("opened a database in a directory that does not exist")
+	}
+}
@@ -0,0 +1,108 @@
+package contacts
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps contacts in memory, for servers that run
+// with an ephemeral self-store.
+type MemoryStore struct {
+	mu       sync.Mutex
+	contacts map[string]Contact
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{contacts: make(map[string]Contact)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, contact Contact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contact.Facts = maps.Clone(s.contacts[contact.PeerAddress].Facts)
+	s.contacts[contact.PeerAddress] = contact
+
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, peerAddress string) (Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contact, ok := s.contacts[peerAddress]
+	if !ok {
+		return Contact{}, ErrNotFound
+	}
+
+	contact.Facts = maps.Clone(contact.Facts)
+
+	return contact, nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context) ([]Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contacts := make([]Contact, 0, len(s.contacts))
+	for _, contact := range s.contacts {
+		contact.Facts = maps.Clone(contact.Facts)
+		contacts = append(contacts, contact)
+	}
+
+	slices.SortFunc(contacts, func(a, b Contact) int {
+		return a.ConnectedAt.Compare(b.ConnectedAt)
+	})
+
+	return contacts, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, peerAddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.contacts[peerAddress]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(s.contacts, peerAddress)
+
+	return nil
+}
+
+// SetFacts implements Store.
+func (s *MemoryStore) SetFacts(ctx context.Context, peerAddress string, facts ...Fact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contact, ok := s.contacts[peerAddress]
+	if !ok {
+		return ErrNotFound
+	}
+
+	contact.Facts = maps.Clone(contact.Facts)
+	if contact.Facts == nil {
+		contact.Facts = make(map[string]Fact)
+	}
+
+	for _, fact := range facts {
+		contact.Facts[fact.Name] = fact
+	}
+
+	s.contacts[peerAddress] = contact
+
+	return nil
+}
+
+// Close implements Store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
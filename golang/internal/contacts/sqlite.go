@@ -0,0 +1,254 @@
+package contacts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	// registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS contacts (
+	peer_address       TEXT PRIMARY KEY,
+	connection_address TEXT NOT NULL,
+	invitation_id      TEXT NOT NULL DEFAULT '',
+	connected_at       INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS facts (
+	peer_address TEXT NOT NULL REFERENCES contacts (peer_address) ON DELETE CASCADE,
+	name         TEXT NOT NULL,
+	value        TEXT NOT NULL,
+	verified_at  INTEGER NOT NULL,
+	PRIMARY KEY (peer_address, name)
+);
+`
+
+// SQLiteStore is a Store backed by a SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens, and if needed creates, the SQLite contacts database at
+// path.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("contacts: failed to open %s: %w", path, err)
+	}
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("contacts: failed to create schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Save implements Store.
+func (s *SQLiteStore) Save(ctx context.Context, contact Contact) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO contacts (peer_address, connection_address, invitation_id, connected_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (peer_address) DO UPDATE SET
+			connection_address = excluded.connection_address,
+			invitation_id = excluded.invitation_id,
+			connected_at = excluded.connected_at`,
+		contact.PeerAddress,
+		contact.ConnectionAddress,
+		contact.InvitationID,
+		contact.ConnectedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("contacts: failed to save %s: %w", contact.PeerAddress, err)
+	}
+
+	return nil
+}
+
+// Get implements Store.
+func (s *SQLiteStore) Get(ctx context.Context, peerAddress string) (Contact, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT peer_address, connection_address, invitation_id, connected_at
+		FROM contacts WHERE peer_address = ?`,
+		peerAddress,
+	)
+
+	contact, err := scanContact(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Contact{}, ErrNotFound
+	}
+	if err != nil {
+		return Contact{}, fmt.Errorf("contacts: failed to get %s: %w", peerAddress, err)
+	}
+
+	facts, err := s.facts(ctx, "WHERE peer_address = ?", peerAddress)
+	if err != nil {
+		return Contact{}, err
+	}
+
+	contact.Facts = facts[peerAddress]
+
+	return contact, nil
+}
+
+// List implements Store.
+func (s *SQLiteStore) List(ctx context.Context) ([]Contact, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT peer_address, connection_address, invitation_id, connected_at
+		FROM contacts ORDER BY connected_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("contacts: failed to list: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []Contact
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("contacts: failed to list: %w", err)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("contacts: failed to list: %w", err)
+	}
+
+	facts, err := s.facts(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range contacts {
+		contacts[i].Facts = facts[contacts[i].PeerAddress]
+	}
+
+	return contacts, nil
+}
+
+// Delete implements Store.
+func (s *SQLiteStore) Delete(ctx context.Context, peerAddress string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM contacts WHERE peer_address = ?`, peerAddress)
+	if err != nil {
+		return fmt.Errorf("contacts: failed to delete %s: %w", peerAddress, err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("contacts: failed to delete %s: %w", peerAddress, err)
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetFacts implements Store.
+func (s *SQLiteStore) SetFacts(ctx context.Context, peerAddress string, facts ...Fact) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("contacts: failed to set facts for %s: %w", peerAddress, err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM contacts WHERE peer_address = ?`, peerAddress).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("contacts: failed to set facts for %s: %w", peerAddress, err)
+	}
+
+	for _, fact := range facts {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO facts (peer_address, name, value, verified_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (peer_address, name) DO UPDATE SET
+				value = excluded.value,
+				verified_at = excluded.verified_at`,
+			peerAddress,
+			fact.Name,
+			fact.Value,
+			fact.VerifiedAt.UnixNano(),
+		)
+		if err != nil {
+			return fmt.Errorf("contacts: failed to set fact %s for %s: %w", fact.Name, peerAddress, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("contacts: failed to set facts for %s: %w", peerAddress, err)
+	}
+
+	return nil
+}
+
+// Close implements Store.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// facts returns the facts matching a where clause, grouped by peer address
+func (s *SQLiteStore) facts(ctx context.Context, where string, args ...any) (map[string]map[string]Fact, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT peer_address, name, value, verified_at FROM facts `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("contacts: failed to get facts: %w", err)
+	}
+	defer rows.Close()
+
+	facts := make(map[string]map[string]Fact)
+	for rows.Next() {
+		var peerAddress string
+		var fact Fact
+		var verifiedAt int64
+
+		err = rows.Scan(&peerAddress, &fact.Name, &fact.Value, &verifiedAt)
+		if err != nil {
+			return nil, fmt.Errorf("contacts: failed to get facts: %w", err)
+		}
+
+		fact.VerifiedAt = time.Unix(0, verifiedAt).UTC()
+
+		if facts[peerAddress] == nil {
+			facts[peerAddress] = make(map[string]Fact)
+		}
+		facts[peerAddress][fact.Name] = fact
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("contacts: failed to get facts: %w", err)
+	}
+
+	return facts, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContact(row scanner) (Contact, error) {
+	var contact Contact
+	var connectedAt int64
+
+	err := row.Scan(&contact.PeerAddress, &contact.ConnectionAddress, &contact.InvitationID, &connectedAt)
+	if err != nil {
+		return Contact{}, err
+	}
+
+	contact.ConnectedAt = time.Unix(0, connectedAt).UTC()
+
+	return contact, nil
+}
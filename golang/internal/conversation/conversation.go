@@ -0,0 +1,196 @@
+// Package conversation keeps track of multi-step chat flows with peers. A
+// flow is a tree of menus; each answer either opens the next menu or runs an
+// action and ends the conversation.
+package conversation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Menu is a question with numbered options.
+type Menu struct {
+	Prompt  string
+	Options []Option
+}
+
+// Option is an answer to a menu. Choosing it opens Next or, if Next is nil,
+// runs Action, which returns the text to send back.
+type Option struct {
+	Label  string
+	Next   *Menu
+	Action func() (string, error)
+}
+
+// Text renders the menu as a chat message.
+func (m *Menu) Text() string {
+	var b strings.Builder
+
+	b.WriteString(m.Prompt)
+	for i, option := range m.Options {
+		fmt.Fprintf(&b, "\n%d) %s", i+1, option.Label)
+	}
+	b.WriteString("\nReply with a number, or CANCEL to stop.")
+
+	return b.String()
+}
+
+// choose finds the option answer refers to, by number or by label.
+func (m *Menu) choose(answer string) (*Option, bool) {
+	n, err := strconv.Atoi(answer)
+	if err == nil {
+		if n < 1 || n > len(m.Options) {
+			return nil, false
+		}
+		return &m.Options[n-1], true
+	}
+
+	for i := range m.Options {
+		if strings.EqualFold(m.Options[i].Label, answer) {
+			return &m.Options[i], true
+		}
+	}
+
+	return nil, false
+}
+
+type state struct {
+	menu      *Menu
+	updatedAt time.Time
+}
+
+// Manager holds the conversation each peer is in. A conversation left
+// unanswered for longer than the timeout is forgotten.
+type Manager struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	now     func() time.Time
+	states  map[string]*state
+}
+
+// NewManager returns a manager that forgets conversations after timeout.
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{
+		timeout: timeout,
+		now:     time.Now,
+		states:  make(map[string]*state),
+	}
+}
+
+// Start puts peer at menu, replacing any conversation it was in, and returns
+// the text to send.
+func (m *Manager) Start(peer string, menu *Menu) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[peer] = &state{menu: menu, updatedAt: m.now()}
+
+	return menu.Text()
+}
+
+// Active reports whether peer is in a conversation.
+func (m *Manager) Active(peer string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current(peer) != nil
+}
+
+// Prompt returns the text of the menu peer is at, or an empty string if it
+// is not in a conversation.
+func (m *Manager) Prompt(peer string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.current(peer)
+	if st == nil {
+		return ""
+	}
+
+	return st.menu.Text()
+}
+
+// Answer advances peer's conversation with answer and returns the text to
+// send. ok is false if peer is not in a conversation or answer does not
+// pick one of the options; the conversation is left as it was. An action's
+// error ends the conversation and is returned.
+func (m *Manager) Answer(peer, answer string) (reply string, ok bool, err error) {
+	answer = strings.Join(strings.Fields(answer), " ")
+
+	m.mu.Lock()
+
+	st := m.current(peer)
+	if st == nil {
+		m.mu.Unlock()
+		return "", false, nil
+	}
+
+	if strings.EqualFold(answer, "cancel") {
+		delete(m.states, peer)
+		m.mu.Unlock()
+		return "Cancelled.", true, nil
+	}
+
+	option, ok := st.menu.choose(answer)
+	if !ok {
+		m.mu.Unlock()
+		return "", false, nil
+	}
+
+	if option.Next != nil {
+		st.menu = option.Next
+		st.updatedAt = m.now()
+		m.mu.Unlock()
+		return option.Next.Text(), true, nil
+	}
+
+	delete(m.states, peer)
+	m.mu.Unlock()
+
+	if option.Action == nil {
+		return "", true, nil
+	}
+
+	reply, err = option.Action()
+
+	return reply, true, err
+}
+
+// current returns peer's conversation, forgetting it if it has timed out.
+// m.mu must be held.
+func (m *Manager) current(peer string) *state {
+	st, ok := m.states[peer]
+	if !ok {
+		return nil
+	}
+
+	if m.now().Sub(st.updatedAt) > m.timeout {
+		delete(m.states, peer)
+		return nil
+	}
+
+	return st
+}
+
+// Expire forgets every conversation that has timed out.
+func (m *Manager) Expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for peer := range m.states {
+		m.current(peer)
+	}
+}
+
+// Run expires conversations on a schedule.
+func (m *Manager) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.Expire()
+	}
+}
@@ -0,0 +1,160 @@
+package conversation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a manager whose clock is moved by advancing the
+// returned time
+func newTestManager(timeout time.Duration) (*Manager, *time.Time) {
+	now := time.Now()
+	m := NewManager(timeout)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestStart(t *testing.T) {
+	m, _ := newTestManager(time.Minute)
+
+	text := m.Start("peer", &Menu{Prompt: "Pick one", Options: []Option{{Label: "Email"}, {Label: "Passport"}}})
+
+	want := "Pick one\n1) Email\n2) Passport\nReply with a number, or CANCEL to stop."
+	if text != want || m.Prompt("peer") != want {
+		t.Errorf("Start = %q, want %q", text, want)
+	}
+	if !m.Active("peer") || m.Active("other") || m.Prompt("other") != "" {
+		t.Error("conversations are not kept per peer")
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	m, _ := newTestManager(time.Minute)
+
+	var ran []string
+	action := func(name string) func() (string, error) {
+		return func() (string, error) {
+			ran = append(ran, name)
+			return "Sent " + name, nil
+		}
+	}
+
+	next := &Menu{Prompt: "Which document?", Options: []Option{{Label: "Passport", Action: action("passport")}}}
+	menu := &Menu{Prompt: "Pick one", Options: []Option{
+		{Label: "Email", Action: action("email")},
+		{Label: "Identity document", Next: next},
+	}}
+
+	tests := []struct {
+		answer string
+		reply  string
+		ok     bool
+	}{
+		{"3", "", false},
+		{"0", "", false},
+		{"phone", "", false},
+		{"  identity   DOCUMENT ", next.Text(), true},
+		{"email", "", false},
+		{"1", "Sent passport", true},
+		{"1", "", false},
+	}
+
+	m.Start("peer", menu)
+	for _, test := range tests {
+		reply, ok, err := m.Answer("peer", test.answer)
+		if err != nil || reply != test.reply || ok != test.ok {
+			t.Errorf("Answer(%q) = %q, %t, %v, want %q, %t", test.answer, reply, ok, err, test.reply, test.ok)
+		}
+	}
+
+	if len(ran) != 1 || ran[0] != "passport" {
+		t.Errorf("ran %q, want passport", ran)
+	}
+	if m.Active("peer") {
+		t.Error("the conversation did not end with its action")
+	}
+}
+
+func TestAnswerCancel(t *testing.T) {
+	m, _ := newTestManager(time.Minute)
+	m.Start("peer", &Menu{Prompt: "Pick one", Options: []Option{{Label: "Cancel"}}})
+
+	reply, ok, err := m.Answer("peer", "CANCEL")
+	if reply != "Cancelled." || !ok || err != nil || m.Active("peer") {
+		t.Errorf("cancel = %q, %t, %v", reply, ok, err)
+	}
+}
+
+func TestAnswerActionError(t *testing.T) {
+	m, _ := newTestManager(time.Minute)
+
+	failed := errors.New("failed")
+	m.Start("peer", &Menu{Options: []Option{{Label: "Email", Action: func() (string, error) { return "", failed }}}})
+
+	_, ok, err := m.Answer("peer", "1")
+	if !ok || !errors.Is(err, failed) || m.Active("peer") {
+		t.Errorf("failed action = %t, %v, want the error and the conversation ended", ok, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	m, now := newTestManager(time.Minute)
+
+	next := &Menu{Prompt: "Next", Options: []Option{{Label: "Done"}}}
+	m.Start("peer", &Menu{Prompt: "First", Options: []Option{{Label: "More", Next: next}}})
+
+	// answering moves the timeout on
+	*now = now.Add(50 * time.Second)
+	if _, ok, _ := m.Answer("peer", "1"); !ok {
+		t.Fatal("answer within the timeout was refused")
+	}
+
+	*now = now.Add(50 * time.Second)
+	if !m.Active("peer") {
+		t.Fatal("the conversation timed out from when it started")
+	}
+
+	*now = now.Add(11 * time.Second)
+	if _, ok, _ := m.Answer("peer", "1"); ok || m.Active("peer") {
+		t.Error("answer after the timeout was accepted")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	m, now := newTestManager(time.Minute)
+
+	m.Start("old", &Menu{})
+	*now = now.Add(30 * time.Second)
+	m.Start("new", &Menu{})
+	*now = now.Add(31 * time.Second)
+
+	m.Expire()
+
+	m.mu.Lock()
+	_, old := m.states["old"]
+	_, recent := m.states["new"]
+	m.mu.Unlock()
+
+	if old || !recent {
+		t.Errorf("after expiry old = %t, new = %t, want only new", old, recent)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	m, _ := newTestManager(time.Minute)
+
+	m.Start("peer", &Menu{Prompt: "First", Options: []Option{{Label: "A"}, {Label: "B"}}})
+	m.Start("peer", &Menu{Prompt: "Second", Options: []Option{{Label: "C"}}})
+
+	if !strings.HasPrefix(m.Prompt("peer"), "Second") {
+		t.Errorf("prompt = %q, want the second menu", m.Prompt("peer"))
+	}
+	if _, ok, _ := m.Answer("peer", "2"); ok {
+		t.Error("an option of the replaced menu was accepted")
+	}
+	if _, ok, _ := m.Answer("peer", "C"); !ok {
+		t.Error("an option of the new menu was refused")
+	}
+}
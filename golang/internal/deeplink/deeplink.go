@@ -0,0 +1,58 @@
+// Package deeplink carries encoded Self messages in app links, so users on a
+// mobile browser can open a connection request without scanning a QR code.
+package deeplink
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PayloadParam is the query parameter that holds the encoded message.
+const PayloadParam = "payload"
+
+// Encode returns a link to base that carries payload. Any query parameters
+// already on base are kept.
+func Encode(base string, payload []byte) (string, error) {
+	if len(payload) == 0 {
+		return "", errors.New("deeplink: empty payload")
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("deeplink: invalid base url: %w", err)
+	}
+
+	if u.Scheme == "" {
+		return "", fmt.Errorf("deeplink: base url %q has no scheme", base)
+	}
+
+	query := u.Query()
+	query.Set(PayloadParam, base64.RawURLEncoding.EncodeToString(payload))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Decode returns the payload carried by a link created with Encode.
+func Decode(link string) ([]byte, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, fmt.Errorf("deeplink: invalid link: %w", err)
+	}
+
+	encoded := u.Query().Get(PayloadParam)
+	if encoded == "" {
+		return nil, fmt.Errorf("deeplink: link has no %s parameter", PayloadParam)
+	}
+
+	// accept padded payloads from encoders that add it
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("deeplink: invalid payload: %w", err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,95 @@
+package deeplink
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	payloads := [][]byte{
+		[]byte("x"),
+		[]byte("anonymous message"),
+		{0x00, 0xff, 0xfe, 0xfb, 0x3e, 0x3f},
+		bytes.Repeat([]byte{0xfb, 0xff}, 512),
+	}
+
+	for _, base := range []string{
+		"https://links.example.com/connect",
+		"https://links.example.com/connect?campaign=spring",
+		"selfid://connect",
+	} {
+		for _, payload := range payloads {
+			link, err := Encode(base, payload)
+			if err != nil {
+				t.Fatalf("Encode(%q) = %v", base, err)
+			}
+
+			decoded, err := Decode(link)
+			if err != nil {
+				t.Fatalf("Decode(%q) = %v", link, err)
+			}
+
+			if !bytes.Equal(decoded, payload) {
+				t.Errorf("Decode(Encode(%q, %x)) = %x", base, payload, decoded)
+			}
+		}
+	}
+}
+
+func TestEncodeKeepsQuery(t *testing.T) {
+	link, err := Encode("https://links.example.com/connect?campaign=spring", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Host != "links.example.com" || u.Path != "/connect" || u.Query().Get("campaign") != "spring" {
+		t.Errorf("link = %s", link)
+	}
+}
+
+func TestDecodePadded(t *testing.T) {
+	payload := []byte("padded")
+	link := "https://links.example.com/connect?" + PayloadParam + "=" + base64.URLEncoding.EncodeToString(payload)
+
+	decoded, err := Decode(" " + link + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded, payload) {
+		t.Errorf("Decode = %q, want %q", decoded, payload)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		base    string
+		payload []byte
+	}{
+		{"https://links.example.com/connect", nil},
+		{"links.example.com/connect", []byte("payload")},
+		{"://links.example.com", []byte("payload")},
+	} {
+		if _, err := Encode(tc.base, tc.payload); err == nil {
+			t.Errorf("Encode(%q, %q) succeeded", tc.base, tc.payload)
+		}
+	}
+
+	for _, link := range []string{
+		"https://links.example.com/connect",
+		"https://links.example.com/connect?" + PayloadParam + "=",
+		"https://links.example.com/connect?" + PayloadParam + "=not*base64",
+		"%zz",
+	} {
+		if _, err := Decode(link); err == nil {
+			t.Errorf("Decode(%q) succeeded", link)
+		}
+	}
+}
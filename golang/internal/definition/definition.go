@@ -0,0 +1,385 @@
+// Package definition compiles the credential request definitions from the
+// configuration into predicate trees for credential presentation requests.
+//
+// Definitions are first turned into a tree of nodes with every negation
+// pushed down to the fields it tests, as predicate trees have no "not":
+// not(a and b) becomes not(a) or not(b), and a negated field test becomes
+// its opposite operator.
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joinself/self-go-sdk/credential"
+	"github.com/joinself/self-go-sdk/credential/predicate"
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+)
+
+// Op is the operation a node performs.
+type Op string
+
+// The operations of nodes. Every field test has an opposite, so negations
+// can be pushed down to the fields.
+const (
+	OpAnd         Op = "and"
+	OpOr          Op = "or"
+	OpContains    Op = "contains"
+	OpNotContains Op = "not_contains"
+	OpEquals      Op = "equals"
+	OpNotEquals   Op = "not_equals"
+	OpNotEmpty    Op = "not_empty"
+	OpEmpty       Op = "empty"
+)
+
+// negated maps each field test to its opposite.
+var negated = map[Op]Op{
+	OpContains:    OpNotContains,
+	OpNotContains: OpContains,
+	OpEquals:      OpNotEquals,
+	OpNotEquals:   OpEquals,
+	OpNotEmpty:    OpEmpty,
+	OpEmpty:       OpNotEmpty,
+}
+
+// FieldAliases are the short names accepted for credential fields. Other
+// names starting with "/" are used as they are, and any other name refers to
+// a claim of the credential subject.
+var FieldAliases = map[string]string{
+	"type":                       credential.FieldType,
+	"email_address":              credential.FieldSubjectEmailAddress,
+	"passport_document_number":   credential.FieldSubjectPassportDocumentNumber,
+	"liveness_source_image_hash": credential.FieldSubjectLivenessAndFacialComparisonSourceImageHash,
+}
+
+// TypeAliases are the short names accepted for credential types. Other
+// names are used as they are.
+var TypeAliases = map[string]string{
+	"email":    credential.CredentialTypeEmail,
+	"passport": credential.CredentialTypePassport,
+	"liveness": credential.CredentialTypeLivenessAndFacialComparison,
+}
+
+// Node is a compiled condition. And and Or nodes have children; the others
+// test Field, against Value where the operation takes one.
+type Node struct {
+	Op       Op
+	Field    string
+	Value    string
+	Children []*Node
+}
+
+// Definition is a compiled credential request definition.
+type Definition struct {
+	Name           string
+	Description    string
+	CredentialType string
+	Root           *Node
+}
+
+// Compile compiles every definition in defs, keyed by name.
+func Compile(defs map[string]config.CredentialDefinition) (map[string]*Definition, error) {
+	compiled := make(map[string]*Definition, len(defs))
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		d, err := CompileOne(name, defs[name])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		compiled[name] = d
+	}
+
+	return compiled, errors.Join(errs...)
+}
+
+// CompileOne compiles a single definition.
+func CompileOne(name string, def config.CredentialDefinition) (*Definition, error) {
+	credentialType := ResolveType(def.Type)
+	if credentialType == "" {
+		return nil, fmt.Errorf("definition %s: no credential type", name)
+	}
+
+	root := &Node{Op: OpAnd}
+	root.Children = append(root.Children, &Node{Op: OpContains, Field: credential.FieldType, Value: credentialType})
+
+	for _, field := range def.Require {
+		root.Children = append(root.Children, &Node{Op: OpNotEmpty, Field: resolveField(field)})
+	}
+
+	if def.Where != nil {
+		where, err := compile(*def.Where, false)
+		if err != nil {
+			return nil, fmt.Errorf("definition %s: %w", name, err)
+		}
+		root.Children = append(root.Children, where)
+	}
+
+	description := def.Description
+	if description == "" {
+		description = name
+	}
+
+	return &Definition{
+		Name:           name,
+		Description:    description,
+		CredentialType: credentialType,
+		Root:           root,
+	}, nil
+}
+
+// compile turns a condition into a node, negated if negate is set.
+func compile(c config.Condition, negate bool) (*Node, error) {
+	switch {
+	case c.Not != nil:
+		return compile(*c.Not, !negate)
+	case c.And != nil, c.Or != nil:
+		op, conditions := OpAnd, c.And
+		if c.Or != nil {
+			op, conditions = OpOr, c.Or
+		}
+
+		// De Morgan: a negated and is an or of negations, and vice versa
+		if negate {
+			op = map[Op]Op{OpAnd: OpOr, OpOr: OpAnd}[op]
+		}
+
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("empty %s", op)
+		}
+
+		node := &Node{Op: op}
+		for _, sub := range conditions {
+			child, err := compile(sub, negate)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+
+		return node, nil
+	case c.Field != "":
+		node := &Node{Field: resolveField(c.Field)}
+
+		switch {
+		case c.Present != nil:
+			node.Op = OpNotEmpty
+			if !*c.Present {
+				node.Op = OpEmpty
+			}
+		case c.Equals != nil:
+			node.Op, node.Value = OpEquals, *c.Equals
+		case c.Contains != nil:
+			node.Op, node.Value = OpContains, *c.Contains
+		default:
+			return nil, fmt.Errorf("field %q has no test", c.Field)
+		}
+
+		if negate {
+			node.Op = negated[node.Op]
+		}
+
+		return node, nil
+	default:
+		return nil, errors.New("empty condition")
+	}
+}
+
+// Predicates returns the predicate tree of the definition.
+func (d *Definition) Predicates() *predicate.Tree {
+	return predicate.NewTree(build(d.Root, fieldPredicate, joinPredicates))
+}
+
+// build folds a node into a tree of T: field tests become leaf, and the
+// children of and and or nodes are joined left to right with join.
+func build[T any](n *Node, leaf func(op Op, field, value string) T, join func(op Op, a, b T) T) T {
+	if n.Op != OpAnd && n.Op != OpOr {
+		return leaf(n.Op, n.Field, n.Value)
+	}
+
+	t := build(n.Children[0], leaf, join)
+	for _, child := range n.Children[1:] {
+		t = join(n.Op, t, build(child, leaf, join))
+	}
+	return t
+}
+
+func fieldPredicate(op Op, field, value string) *predicate.Predicate {
+	switch op {
+	case OpContains:
+		return predicate.Contains(field, value)
+	case OpNotContains:
+		return predicate.NotContains(field, value)
+	case OpEquals:
+		return predicate.Equals(field, value)
+	case OpNotEquals:
+		return predicate.NotEquals(field, value)
+	case OpEmpty:
+		return predicate.Empty(field)
+	default:
+		return predicate.NotEmpty(field)
+	}
+}
+
+func joinPredicates(op Op, a, b *predicate.Predicate) *predicate.Predicate {
+	if op == OpAnd {
+		return a.And(b)
+	}
+	return a.Or(b)
+}
+
+// String renders the node as an expression, for logs.
+func (n *Node) String() string {
+	switch n.Op {
+	case OpAnd, OpOr:
+		parts := make([]string, 0, len(n.Children))
+		for _, child := range n.Children {
+			parts = append(parts, child.String())
+		}
+		return "(" + strings.Join(parts, " "+string(n.Op)+" ") + ")"
+	case OpEmpty, OpNotEmpty:
+		return fmt.Sprintf("%s %s", n.Field, n.Op)
+	default:
+		return fmt.Sprintf("%s %s %q", n.Field, n.Op, n.Value)
+	}
+}
+
+func resolveField(field string) string {
+	if alias, ok := FieldAliases[field]; ok {
+		return alias
+	}
+
+	if strings.HasPrefix(field, "/") {
+		return field
+	}
+
+	return "/credentialSubject/" + field
+}
+
+// ResolveType returns the credential type an alias stands for, or the type
+// itself if it is not an alias.
+func ResolveType(credentialType string) string {
+	if alias, ok := TypeAliases[credentialType]; ok {
+		return alias
+	}
+
+	return credentialType
+}
+
+// Evaluate reports whether a credential satisfies the node. The credential
+// is given as its JSON document, which fields are JSON pointers into. Missing
+// fields are empty, equal nothing and contain nothing, and only lists contain
+// anything.
+func (n *Node) Evaluate(doc map[string]any) bool {
+	switch n.Op {
+	case OpAnd:
+		for _, child := range n.Children {
+			if !child.Evaluate(doc) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, child := range n.Children {
+			if child.Evaluate(doc) {
+				return true
+			}
+		}
+		return false
+	}
+
+	value, found := lookup(doc, n.Field)
+
+	switch n.Op {
+	case OpContains:
+		return found && contains(value, n.Value)
+	case OpNotContains:
+		return !found || !contains(value, n.Value)
+	case OpEquals:
+		return found && equals(value, n.Value)
+	case OpNotEquals:
+		return !found || !equals(value, n.Value)
+	case OpEmpty:
+		return !found || empty(value)
+	default:
+		return found && !empty(value)
+	}
+}
+
+// lookup resolves a JSON pointer in doc.
+func lookup(doc map[string]any, pointer string) (any, bool) {
+	var value any = doc
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// contains reports whether a list has an element equal to want. Like the
+// SDK's contains predicate it matches whole elements only, so a string
+// contains nothing, not even its substrings.
+func contains(value any, want string) bool {
+	switch v := value.(type) {
+	case []any:
+		for _, element := range v {
+			if equals(element, want) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		return slices.Contains(v, want)
+	default:
+		return false
+	}
+}
+
+func equals(value any, want string) bool {
+	switch value.(type) {
+	case nil, map[string]any, []any, []string:
+		return false
+	default:
+		return fmt.Sprint(value) == want
+	}
+}
+
+func empty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,388 @@
+package definition
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCompileOne(t *testing.T) {
+	d, err := CompileOne("email", config.CredentialDefinition{
+		Type:    "email",
+		Require: []string{"email_address", "name", "/credentialSubject/address/country"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Name != "email" || d.Description != "email" || d.CredentialType != TypeAliases["email"] {
+		t.Errorf("definition = %+v", d)
+	}
+
+	want := &Node{Op: OpAnd, Children: []*Node{
+		{Op: OpContains, Field: FieldAliases["type"], Value: TypeAliases["email"]},
+		{Op: OpNotEmpty, Field: FieldAliases["email_address"]},
+		{Op: OpNotEmpty, Field: "/credentialSubject/name"},
+		{Op: OpNotEmpty, Field: "/credentialSubject/address/country"},
+	}}
+	if !reflect.DeepEqual(d.Root, want) {
+		t.Errorf("root = %s, want %s", d.Root, want)
+	}
+}
+
+func TestCompileNegation(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		where config.Condition
+		want  *Node
+	}{
+		{
+			"negated field tests",
+			config.Condition{Not: &config.Condition{Field: "country", Equals: ptr("USA")}},
+			&Node{Op: OpNotEquals, Field: "/credentialSubject/country", Value: "USA"},
+		},
+		{
+			"double negation",
+			config.Condition{Not: &config.Condition{Not: &config.Condition{Field: "tags", Contains: ptr("vip")}}},
+			&Node{Op: OpContains, Field: "/credentialSubject/tags", Value: "vip"},
+		},
+		{
+			"negated absence",
+			config.Condition{Not: &config.Condition{Field: "name", Present: ptr(false)}},
+			&Node{Op: OpNotEmpty, Field: "/credentialSubject/name"},
+		},
+		{
+			"De Morgan",
+			config.Condition{Not: &config.Condition{And: []config.Condition{
+				{Field: "country", Equals: ptr("USA")},
+				{Field: "name", Present: ptr(true)},
+			}}},
+			&Node{Op: OpOr, Children: []*Node{
+				{Op: OpNotEquals, Field: "/credentialSubject/country", Value: "USA"},
+				{Op: OpEmpty, Field: "/credentialSubject/name"},
+			}},
+		},
+	} {
+		d, err := CompileOne("test", config.CredentialDefinition{Type: "CustomerCredential", Where: &tc.where})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		if where := d.Root.Children[len(d.Root.Children)-1]; !reflect.DeepEqual(where, tc.want) {
+			t.Errorf("%s: where = %s, want %s", tc.name, where, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	compiled, err := Compile(map[string]config.CredentialDefinition{
+		"email":    {Type: "email", Require: []string{"email_address"}},
+		"no_type":  {Require: []string{"name"}},
+		"empty_or": {Type: "CustomerCredential", Where: &config.Condition{Or: []config.Condition{}}},
+		"no_test":  {Type: "CustomerCredential", Where: &config.Condition{Field: "name"}},
+	})
+	if err == nil {
+		t.Fatal("Compile succeeded")
+	}
+
+	for _, name := range []string{"no_type", "empty_or", "no_test"} {
+		if !strings.Contains(err.Error(), "definition "+name+":") {
+			t.Errorf("error %q does not name %s", err, name)
+		}
+	}
+
+	if len(compiled) != 1 || compiled["email"] == nil {
+		t.Errorf("compiled = %v, want only email", compiled)
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	for alias, want := range TypeAliases {
+		if got := ResolveType(alias); got != want {
+			t.Errorf("ResolveType(%q) = %q, want %q", alias, got, want)
+		}
+	}
+
+	if got := ResolveType("CustomerCredential"); got != "CustomerCredential" {
+		t.Errorf("ResolveType(CustomerCredential) = %q", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	doc := map[string]any{
+		"type": []any{"VerifiableCredential", "EmailCredential"},
+		"credentialSubject": map[string]any{
+			"address": map[string]any{"country": "GBR"},
+			"a/b":     "slash",
+			"a~b":     "tilde",
+			"name":    "",
+		},
+	}
+
+	for _, tc := range []struct {
+		pointer string
+		want    any
+		found   bool
+	}{
+		{"/credentialSubject/address/country", "GBR", true},
+		{"/type/1", "EmailCredential", true},
+		{"/type/2", nil, false},
+		{"/type/-1", nil, false},
+		{"/type/first", nil, false},
+		{"/credentialSubject/a~1b", "slash", true},
+		{"/credentialSubject/a~0b", "tilde", true},
+		{"/credentialSubject/name", "", true},
+		{"/credentialSubject/missing", nil, false},
+		{"/credentialSubject/address/country/code", nil, false},
+	} {
+		got, found := lookup(doc, tc.pointer)
+		if found != tc.found || got != tc.want {
+			t.Errorf("lookup(%q) = %v, %t, want %v, %t", tc.pointer, got, found, tc.want, tc.found)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	doc := map[string]any{
+		"type": []any{"VerifiableCredential", "EmailCredential"},
+		"credentialSubject": map[string]any{
+			"emailAddress": "ada@example.com",
+			"age":          float64(36),
+			"verified":     true,
+			"tags":         []any{"vip", "beta"},
+			"empty":        "",
+			"none":         []any{},
+		},
+	}
+
+	for _, tc := range []struct {
+		node *Node
+		want bool
+	}{
+		{&Node{Op: OpContains, Field: "/type", Value: "EmailCredential"}, true},
+		{&Node{Op: OpContains, Field: "/type", Value: "Email"}, false},
+		{&Node{Op: OpContains, Field: "/credentialSubject/tags", Value: "vip"}, true},
+		// a string contains nothing, not even a substring
+		{&Node{Op: OpContains, Field: "/credentialSubject/emailAddress", Value: "example.com"}, false},
+		{&Node{Op: OpContains, Field: "/credentialSubject/emailAddress", Value: "ada@example.com"}, false},
+		{&Node{Op: OpContains, Field: "/credentialSubject/missing", Value: "vip"}, false},
+		{&Node{Op: OpNotContains, Field: "/credentialSubject/tags", Value: "admin"}, true},
+		{&Node{Op: OpNotContains, Field: "/credentialSubject/missing", Value: "vip"}, true},
+		{&Node{Op: OpEquals, Field: "/credentialSubject/emailAddress", Value: "ada@example.com"}, true},
+		{&Node{Op: OpEquals, Field: "/credentialSubject/age", Value: "36"}, true},
+		{&Node{Op: OpEquals, Field: "/credentialSubject/verified", Value: "true"}, true},
+		{&Node{Op: OpEquals, Field: "/credentialSubject/tags", Value: "vip"}, false},
+		{&Node{Op: OpEquals, Field: "/credentialSubject/missing", Value: ""}, false},
+		{&Node{Op: OpNotEquals, Field: "/credentialSubject/age", Value: "18"}, true},
+		{&Node{Op: OpNotEquals, Field: "/credentialSubject/missing", Value: "18"}, true},
+		{&Node{Op: OpNotEmpty, Field: "/credentialSubject/emailAddress"}, true},
+		{&Node{Op: OpNotEmpty, Field: "/credentialSubject/empty"}, false},
+		{&Node{Op: OpNotEmpty, Field: "/credentialSubject/none"}, false},
+		{&Node{Op: OpNotEmpty, Field: "/credentialSubject/verified"}, true},
+		{&Node{Op: OpEmpty, Field: "/credentialSubject/missing"}, true},
+		{&Node{Op: OpEmpty, Field: "/credentialSubject/empty"}, true},
+		{&Node{Op: OpEmpty, Field: "/credentialSubject/tags"}, false},
+	} {
+		if got := tc.node.Evaluate(doc); got != tc.want {
+			t.Errorf("%s = %t, want %t", tc.node, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateDefinition(t *testing.T) {
+	d, err := CompileOne("email", config.CredentialDefinition{Type: "email", Require: []string{"emailAddress"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		doc  map[string]any
+		want bool
+	}{
+		{"matching", map[string]any{
+			"type":              []any{TypeAliases["email"]},
+			"credentialSubject": map[string]any{"emailAddress": "ada@example.com"},
+		}, true},
+		{"other type", map[string]any{
+			"type":              []any{TypeAliases["passport"]},
+			"credentialSubject": map[string]any{"emailAddress": "ada@example.com"},
+		}, false},
+		{"missing field", map[string]any{
+			"type":              []any{TypeAliases["email"]},
+			"credentialSubject": map[string]any{},
+		}, false},
+	} {
+		if got := d.Root.Evaluate(tc.doc); got != tc.want {
+			t.Errorf("%s: Evaluate = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+// describe renders a node the way build hands it to the SDK, one predicate
+// constructor call per field test
+func describe(n *Node) string {
+	return build(n, func(op Op, field, value string) string {
+		if op == OpEmpty || op == OpNotEmpty {
+			return string(op) + "(" + field + ")"
+		}
+		return string(op) + "(" + field + ", " + value + ")"
+	}, func(op Op, a, b string) string {
+		return "(" + a + " " + string(op) + " " + b + ")"
+	})
+}
+
+func TestWhere(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		where      config.Condition
+		predicates string
+		match      []map[string]any
+		mismatch   []map[string]any
+	}{
+		{
+			name:       "present",
+			where:      config.Condition{Field: "name", Present: ptr(true)},
+			predicates: "not_empty(/credentialSubject/name)",
+			match:      []map[string]any{{"name": "Ada"}},
+			mismatch:   []map[string]any{{"name": ""}, {}},
+		},
+		{
+			name:       "absent",
+			where:      config.Condition{Field: "name", Present: ptr(false)},
+			predicates: "empty(/credentialSubject/name)",
+			match:      []map[string]any{{"name": ""}, {}},
+			mismatch:   []map[string]any{{"name": "Ada"}},
+		},
+		{
+			name:       "equals",
+			where:      config.Condition{Field: "country", Equals: ptr("GBR")},
+			predicates: "equals(/credentialSubject/country, GBR)",
+			match:      []map[string]any{{"country": "GBR"}},
+			mismatch:   []map[string]any{{"country": "USA"}, {}},
+		},
+		{
+			name:       "contains",
+			where:      config.Condition{Field: "tags", Contains: ptr("vip")},
+			predicates: "contains(/credentialSubject/tags, vip)",
+			match:      []map[string]any{{"tags": []any{"beta", "vip"}}},
+			mismatch:   []map[string]any{{"tags": []any{"beta"}}, {"tags": "vip"}, {}},
+		},
+		{
+			name: "and",
+			where: config.Condition{And: []config.Condition{
+				{Field: "country", Equals: ptr("GBR")},
+				{Field: "over_18", Equals: ptr("true")},
+				{Field: "name", Present: ptr(true)},
+			}},
+			predicates: "((equals(/credentialSubject/country, GBR) and equals(/credentialSubject/over_18, true)) and not_empty(/credentialSubject/name))",
+			match:      []map[string]any{{"country": "GBR", "over_18": true, "name": "Ada"}},
+			mismatch:   []map[string]any{{"country": "GBR", "over_18": false, "name": "Ada"}, {"country": "GBR", "over_18": true}},
+		},
+		{
+			name: "or",
+			where: config.Condition{Or: []config.Condition{
+				{Field: "country", Equals: ptr("GBR")},
+				{Field: "country", Equals: ptr("IRL")},
+			}},
+			predicates: "(equals(/credentialSubject/country, GBR) or equals(/credentialSubject/country, IRL))",
+			match:      []map[string]any{{"country": "GBR"}, {"country": "IRL"}},
+			mismatch:   []map[string]any{{"country": "USA"}, {}},
+		},
+		{
+			name:       "not present",
+			where:      config.Condition{Not: &config.Condition{Field: "name", Present: ptr(true)}},
+			predicates: "empty(/credentialSubject/name)",
+			match:      []map[string]any{{}},
+			mismatch:   []map[string]any{{"name": "Ada"}},
+		},
+		{
+			name:       "not equals",
+			where:      config.Condition{Not: &config.Condition{Field: "country", Equals: ptr("USA")}},
+			predicates: "not_equals(/credentialSubject/country, USA)",
+			match:      []map[string]any{{"country": "GBR"}, {}},
+			mismatch:   []map[string]any{{"country": "USA"}},
+		},
+		{
+			name:       "not contains",
+			where:      config.Condition{Not: &config.Condition{Field: "tags", Contains: ptr("banned")}},
+			predicates: "not_contains(/credentialSubject/tags, banned)",
+			match:      []map[string]any{{"tags": []any{"vip"}}, {}},
+			mismatch:   []map[string]any{{"tags": []any{"vip", "banned"}}},
+		},
+		{
+			name: "not or",
+			where: config.Condition{Not: &config.Condition{Or: []config.Condition{
+				{Field: "country", Equals: ptr("USA")},
+				{Field: "tags", Contains: ptr("banned")},
+			}}},
+			predicates: "(not_equals(/credentialSubject/country, USA) and not_contains(/credentialSubject/tags, banned))",
+			match:      []map[string]any{{"country": "GBR", "tags": []any{"vip"}}},
+			mismatch:   []map[string]any{{"country": "USA"}, {"country": "GBR", "tags": []any{"banned"}}},
+		},
+		{
+			name: "nested negation",
+			where: config.Condition{Not: &config.Condition{Or: []config.Condition{
+				{And: []config.Condition{
+					{Field: "country", Equals: ptr("GBR")},
+					{Not: &config.Condition{Field: "over_18", Equals: ptr("true")}},
+				}},
+				{Field: "tags", Contains: ptr("vip")},
+			}}},
+			predicates: "((not_equals(/credentialSubject/country, GBR) or equals(/credentialSubject/over_18, true)) and not_contains(/credentialSubject/tags, vip))",
+			match: []map[string]any{
+				{"country": "GBR", "over_18": "true", "tags": []any{"beta"}},
+				{"country": "USA"},
+			},
+			mismatch: []map[string]any{
+				{"country": "GBR", "over_18": "false"},
+				{"country": "USA", "tags": []any{"vip"}},
+			},
+		},
+	} {
+		d, err := CompileOne("test", config.CredentialDefinition{Type: "CustomerCredential", Where: &tc.where})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		where := d.Root.Children[len(d.Root.Children)-1]
+		if got := describe(where); got != tc.predicates {
+			t.Errorf("%s: predicates = %s, want %s", tc.name, got, tc.predicates)
+		}
+
+		// the SDK accepts the whole tree
+		if d.Predicates() == nil {
+			t.Errorf("%s: no predicate tree", tc.name)
+		}
+
+		for _, subject := range tc.match {
+			doc := map[string]any{"type": []any{"CustomerCredential"}, "credentialSubject": subject}
+			if !d.Root.Evaluate(doc) {
+				t.Errorf("%s: %v does not match", tc.name, subject)
+			}
+		}
+
+		for _, subject := range tc.mismatch {
+			doc := map[string]any{"type": []any{"CustomerCredential"}, "credentialSubject": subject}
+			if d.Root.Evaluate(doc) {
+				t.Errorf("%s: %v matches", tc.name, subject)
+			}
+		}
+	}
+}
+
+func TestFieldPredicates(t *testing.T) {
+	for op := range negated {
+		if fieldPredicate(op, "/credentialSubject/name", "Ada") == nil {
+			t.Errorf("%s has no predicate", op)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package events publishes the outcomes of the server's flows, such as new
+// connections and verified credentials, to whoever subscribes to them.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Type names a kind of event.
+type Type string
+
+const (
+	// ConnectionEstablished events carry a ConnectionData.
+	ConnectionEstablished Type = "connection.established"
+	// ConnectionFailed events carry a ConnectionData with the failure.
+	ConnectionFailed Type = "connection.failed"
+	// CredentialsVerified events carry a verification.Result.
+	CredentialsVerified Type = "credentials.verified"
+	// AgreementSigned events carry an AgreementData.
+	AgreementSigned Type = "agreement.signed"
+	// AgreementDeclined events carry an AgreementData.
+	AgreementDeclined Type = "agreement.declined"
+	// DiscoveryResponse events carry a DiscoveryData.
+	DiscoveryResponse Type = "discovery.response"
+	// RequestExpired events carry the pending.Request that expired.
+	RequestExpired Type = "request.expired"
+)
+
+// Types lists every type of event.
+var Types = []Type{
+	ConnectionEstablished,
+	ConnectionFailed,
+	CredentialsVerified,
+	AgreementSigned,
+	AgreementDeclined,
+	DiscoveryResponse,
+	RequestExpired,
+}
+
+// Event is something that happened, with data that depends on its type.
+type Event struct {
+	ID   string    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// ConnectionData describes a connection with a peer.
+type ConnectionData struct {
+	PeerAddress       string `json:"peer_address"`
+	ConnectionAddress string `json:"connection_address,omitempty"`
+	InvitationID      string `json:"invitation_id,omitempty"`
+	Failure           string `json:"failure,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// AgreementData describes a peer's response to an agreement.
+type AgreementData struct {
+	PeerAddress string `json:"peer_address"`
+	RequestID   string `json:"request_id"`
+	Status      string `json:"status"`
+}
+
+// DiscoveryData describes a peer's discovery response.
+type DiscoveryData struct {
+	PeerAddress string `json:"peer_address"`
+	Status      string `json:"status"`
+}
+
+// Bus delivers every published event to its subscribers, in the order they
+// subscribed. Subscribers run on the publisher's goroutine and must not
+// block.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+}
+
+type subscriber struct {
+	fn func(Event)
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls fn with every event published from now on, until the
+// returned function is called.
+func (b *Bus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	sub := &subscriber{fn: fn}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, sub)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		// copy, Publish may be iterating over the current slice
+		b.subscribers = slices.DeleteFunc(slices.Clone(b.subscribers), func(s *subscriber) bool {
+			return s == sub
+		})
+	}
+}
+
+// Publish sends a new event of the given type to every subscriber and
+// returns it.
+func (b *Bus) Publish(eventType Type, data any) Event {
+	event := Event{
+		ID:   newID(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		sub.fn(event)
+	}
+
+	return event
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
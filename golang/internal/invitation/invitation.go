@@ -0,0 +1,348 @@
+// Package invitation tracks the connection invitations handed out as QR codes
+// and links, so a connection can be traced back to whoever created it.
+package invitation
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// State is the position of an invitation in its lifecycle.
+type State string
+
+const (
+	// StateIssued invitations have been handed out but not used.
+	StateIssued State = "issued"
+	// StateScanned invitations have been used by a peer that is connecting.
+	StateScanned State = "scanned"
+	// StateConnected invitations have an established connection.
+	StateConnected State = "connected"
+	// StateExpired invitations can no longer be used.
+	StateExpired State = "expired"
+)
+
+// ErrNotFound is returned when no invitation matches a lookup.
+var ErrNotFound = errors.New("invitation: not found")
+
+// ErrMetadataTooLarge is returned for metadata over the limits below.
+var ErrMetadataTooLarge = errors.New("invitation: metadata too large")
+
+const (
+	// MaxMetadataEntries is the most metadata entries an invitation can have.
+	MaxMetadataEntries = 16
+	// MaxMetadataKey is the longest a metadata key can be, in bytes.
+	MaxMetadataKey = 64
+	// MaxMetadataValue is the longest a metadata value can be, in bytes.
+	MaxMetadataValue = 512
+)
+
+// Invitation is a single connection invitation.
+type Invitation struct {
+	ID                string            `json:"id"`
+	Creator           string            `json:"creator"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	InboxAddress      string            `json:"inbox_address"`
+	State             State             `json:"state"`
+	PeerAddress       string            `json:"peer_address,omitempty"`
+	ConnectionAddress string            `json:"connection_address,omitempty"`
+	LastError         string            `json:"last_error,omitempty"`
+	Failures          int               `json:"failures,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	ExpiresAt         time.Time         `json:"expires_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// Options describes a new invitation.
+type Options struct {
+	Creator      string
+	Metadata     map[string]string
+	InboxAddress string
+	// KeyPackage is the key package handed out with the invitation.
+	KeyPackage []byte
+	ExpiresAt  time.Time
+	// OnChange is called with a copy of the invitation after every state
+	// change, so the flow that created it can pick up the connection.
+	OnChange func(Invitation)
+}
+
+type entry struct {
+	invitation Invitation
+	onChange   func(Invitation)
+	keyPackage string
+}
+
+// Registry holds invitations, keyed by ID, by the inbox address the
+// invitation's key package was negotiated for and by the key package itself.
+// Both welcome and key package events are addressed to that inbox.
+type Registry struct {
+	mu           sync.Mutex
+	byID         map[string]*entry
+	byInbox      map[string]*entry
+	byKeyPackage map[string]*entry
+	retention    time.Duration
+}
+
+// NewRegistry returns an empty registry. Connected and expired invitations
+// are forgotten once they are older than retention.
+func NewRegistry(retention time.Duration) *Registry {
+	return &Registry{
+		byID:         make(map[string]*entry),
+		byInbox:      make(map[string]*entry),
+		byKeyPackage: make(map[string]*entry),
+		retention:    retention,
+	}
+}
+
+// CheckMetadata returns ErrMetadataTooLarge if metadata has too many entries
+// or an entry that is too long.
+func CheckMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrMetadataTooLarge, MaxMetadataEntries)
+	}
+
+	for k, v := range metadata {
+		if len(k) > MaxMetadataKey {
+			return fmt.Errorf("%w: key longer than %d bytes", ErrMetadataTooLarge, MaxMetadataKey)
+		}
+		if len(v) > MaxMetadataValue {
+			return fmt.Errorf("%w: %s is longer than %d bytes", ErrMetadataTooLarge, k, MaxMetadataValue)
+		}
+	}
+
+	return nil
+}
+
+// Issue records a new invitation.
+func (r *Registry) Issue(opts Options) (Invitation, error) {
+	err := CheckMetadata(opts.Metadata)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	now := time.Now()
+
+	e := &entry{
+		invitation: Invitation{
+			ID:           id,
+			Creator:      opts.Creator,
+			Metadata:     maps.Clone(opts.Metadata),
+			InboxAddress: opts.InboxAddress,
+			State:        StateIssued,
+			CreatedAt:    now,
+			ExpiresAt:    opts.ExpiresAt,
+			UpdatedAt:    now,
+		},
+		onChange:   opts.OnChange,
+		keyPackage: keyPackageID(opts.KeyPackage),
+	}
+
+	r.mu.Lock()
+	r.byID[id] = e
+	r.byInbox[opts.InboxAddress] = e
+	if e.keyPackage != "" {
+		r.byKeyPackage[e.keyPackage] = e
+	}
+	r.mu.Unlock()
+
+	return e.invitation.clone(), nil
+}
+
+// Get returns the invitation with the given ID.
+func (r *Registry) Get(id string) (Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.byID[id]
+	if !ok {
+		return Invitation{}, ErrNotFound
+	}
+
+	return e.invitation.clone(), nil
+}
+
+// ByInbox returns the invitation issued for an inbox address.
+func (r *Registry) ByInbox(inboxAddress string) (Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.byInbox[inboxAddress]
+	if !ok {
+		return Invitation{}, ErrNotFound
+	}
+
+	return e.invitation.clone(), nil
+}
+
+// ByKeyPackage returns the invitation a key package was handed out with.
+func (r *Registry) ByKeyPackage(keyPackage []byte) (Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.byKeyPackage[keyPackageID(keyPackage)]
+	if !ok {
+		return Invitation{}, ErrNotFound
+	}
+
+	return e.invitation.clone(), nil
+}
+
+// Scanned records that a peer has started connecting through an invitation.
+func (r *Registry) Scanned(id, peerAddress string) (Invitation, error) {
+	return r.update(id, func(inv *Invitation) bool {
+		if inv.State != StateIssued {
+			return false
+		}
+		inv.State = StateScanned
+		inv.PeerAddress = peerAddress
+		return true
+	})
+}
+
+// Connected records that the connection through an invitation has been
+// established.
+func (r *Registry) Connected(id, peerAddress, connectionAddress string) (Invitation, error) {
+	return r.update(id, func(inv *Invitation) bool {
+		if inv.State == StateConnected {
+			return false
+		}
+		inv.State = StateConnected
+		inv.PeerAddress = peerAddress
+		inv.ConnectionAddress = connectionAddress
+		return true
+	})
+}
+
+// Failed records that establishing the connection through an invitation
+// failed. The invitation keeps its state, so a retry can still complete it.
+func (r *Registry) Failed(id, peerAddress, reason string) (Invitation, error) {
+	return r.update(id, func(inv *Invitation) bool {
+		inv.PeerAddress = peerAddress
+		inv.LastError = reason
+		inv.Failures++
+		return true
+	})
+}
+
+func (r *Registry) update(id string, fn func(inv *Invitation) bool) (Invitation, error) {
+	r.mu.Lock()
+
+	e, ok := r.byID[id]
+	if !ok {
+		r.mu.Unlock()
+		return Invitation{}, ErrNotFound
+	}
+
+	changed := fn(&e.invitation)
+	if changed {
+		e.invitation.UpdatedAt = time.Now()
+	}
+
+	inv := e.invitation.clone()
+	onChange := e.onChange
+
+	r.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(inv.clone())
+	}
+
+	return inv, nil
+}
+
+// Expire marks unused invitations past their expiry as expired, forgets
+// finished invitations older than the retention period and returns the
+// invitations that expired.
+func (r *Registry) Expire(now time.Time) []Invitation {
+	type change struct {
+		invitation Invitation
+		onChange   func(Invitation)
+	}
+
+	var expired []change
+
+	r.mu.Lock()
+
+	for id, e := range r.byID {
+		inv := &e.invitation
+
+		switch inv.State {
+		case StateIssued, StateScanned:
+			if now.After(inv.ExpiresAt) {
+				inv.State = StateExpired
+				inv.UpdatedAt = now
+				expired = append(expired, change{inv.clone(), e.onChange})
+			}
+		case StateConnected, StateExpired:
+			if now.Sub(inv.UpdatedAt) > r.retention {
+				delete(r.byID, id)
+				if r.byInbox[inv.InboxAddress] == e {
+					delete(r.byInbox, inv.InboxAddress)
+				}
+				if r.byKeyPackage[e.keyPackage] == e {
+					delete(r.byKeyPackage, e.keyPackage)
+				}
+			}
+		}
+	}
+
+	r.mu.Unlock()
+
+	invitations := make([]Invitation, 0, len(expired))
+	for _, c := range expired {
+		if c.onChange != nil {
+			c.onChange(c.invitation.clone())
+		}
+		invitations = append(invitations, c.invitation)
+	}
+
+	return invitations
+}
+
+// List returns all invitations the registry knows about.
+func (r *Registry) List() []Invitation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := make([]Invitation, 0, len(r.byID))
+	for _, e := range r.byID {
+		invitations = append(invitations, e.invitation.clone())
+	}
+
+	return invitations
+}
+
+func (i Invitation) clone() Invitation {
+	i.Metadata = maps.Clone(i.Metadata)
+	return i
+}
+
+// keyPackageID returns the key a key package is indexed by, a hash as key
+// packages are large
+func keyPackageID(keyPackage []byte) string {
+	if len(keyPackage) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(keyPackage)
+	return hex.EncodeToString(sum[:])
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
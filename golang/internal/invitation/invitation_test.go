@@ -0,0 +1,131 @@
+package invitation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	r := NewRegistry(time.Hour)
+
+	inv, err := r.Issue(Options{
+		Creator:      "test",
+		InboxAddress: "inbox",
+		KeyPackage:   []byte("key package"),
+		ExpiresAt:    time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byInbox, err := r.ByInbox("inbox")
+	if err != nil || byInbox.ID != inv.ID {
+		t.Errorf("ByInbox = %+v, %v", byInbox, err)
+	}
+
+	byKeyPackage, err := r.ByKeyPackage([]byte("key package"))
+	if err != nil || byKeyPackage.ID != inv.ID {
+		t.Errorf("ByKeyPackage = %+v, %v", byKeyPackage, err)
+	}
+
+	_, err = r.ByKeyPackage([]byte("another key package"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("ByKeyPackage with an unknown key package = %v", err)
+	}
+
+	_, err = r.ByKeyPackage(nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("ByKeyPackage without a key package = %v", err)
+	}
+}
+
+func TestStates(t *testing.T) {
+	r := NewRegistry(time.Hour)
+
+	var changes []State
+	inv, err := r.Issue(Options{
+		InboxAddress: "inbox",
+		ExpiresAt:    time.Now().Add(time.Minute),
+		OnChange:     func(inv Invitation) { changes = append(changes, inv.State) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Scanned(inv.ID, "peer")
+	r.Failed(inv.ID, "peer", "transient")
+	r.Connected(inv.ID, "peer", "connection")
+	r.Connected(inv.ID, "peer", "connection")
+
+	inv, _ = r.Get(inv.ID)
+	if inv.State != StateConnected || inv.Failures != 1 || inv.ConnectionAddress != "connection" {
+		t.Errorf("invitation = %+v", inv)
+	}
+
+	want := []State{StateScanned, StateScanned, StateConnected}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("changes = %v, want %v", changes, want)
+		}
+	}
+
+	_, err = r.Scanned("unknown", "peer")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Scanned with an unknown ID = %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	now := time.Now()
+
+	inv, _ := r.Issue(Options{InboxAddress: "inbox", KeyPackage: []byte("key package"), ExpiresAt: now.Add(time.Minute)})
+
+	if expired := r.Expire(now); len(expired) != 0 {
+		t.Errorf("expired %d invitations early", len(expired))
+	}
+
+	expired := r.Expire(now.Add(2 * time.Minute))
+	if len(expired) != 1 || expired[0].State != StateExpired {
+		t.Fatalf("expired = %+v", expired)
+	}
+
+	r.Expire(now.Add(2 * time.Hour))
+
+	if _, err := r.Get(inv.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("an expired invitation was kept past the retention period")
+	}
+	if _, err := r.ByKeyPackage([]byte("key package")); !errors.Is(err, ErrNotFound) {
+		t.Error("a forgotten invitation can still be found by its key package")
+	}
+}
+
+func TestCheckMetadata(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := range MaxMetadataEntries + 1 {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	for name, metadata := range map[string]map[string]string{
+		"too many entries": tooMany,
+		"long key":         {strings.Repeat("k", MaxMetadataKey+1): "v"},
+		"long value":       {"k": strings.Repeat("v", MaxMetadataValue+1)},
+	} {
+		if err := CheckMetadata(metadata); !errors.Is(err, ErrMetadataTooLarge) {
+			t.Errorf("%s: CheckMetadata = %v", name, err)
+		}
+
+		if _, err := NewRegistry(time.Hour).Issue(Options{Metadata: metadata}); !errors.Is(err, ErrMetadataTooLarge) {
+			t.Errorf("%s: Issue = %v", name, err)
+		}
+	}
+
+	if err := CheckMetadata(map[string]string{"campaign": "spring"}); err != nil {
+		t.Errorf("CheckMetadata = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package oidc
+
+import (
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// Claims maps the subject claims of verified credentials to ID token claims.
+// Only claims with a known mapping are released:
+//
+//   - emailAddress becomes email, with email_verified set
+//   - sourceImageHash, from a liveness check, sets liveness_verified
+func Claims(credentials []verification.Credential) map[string]any {
+	claims := make(map[string]any)
+
+	for _, c := range credentials {
+		for name, value := range c.Claims {
+			switch name {
+			case "emailAddress":
+				claims["email"] = value
+				claims["email_verified"] = true
+			case "sourceImageHash":
+				claims["liveness_verified"] = true
+			}
+		}
+	}
+
+	return claims
+}
+
+// supportedClaims lists the claims Claims can release, for discovery.
+var supportedClaims = []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "liveness_verified"}
@@ -0,0 +1,573 @@
+// Package oidc is an OpenID Connect provider that logs users in to web apps
+// with the credentials they share from the Self app. It implements the
+// authorization code flow with PKCE (S256 only), discovery, JWKS and
+// userinfo. Reaching the user through Self is left to an Authenticator,
+// which reports back through the provider's Connected, Verified and Failed
+// methods.
+//
+// The sub claim is the address the user's app connected from. Every login
+// makes a new connection, and the app is not required to connect from the
+// same address each time, so sub is not guaranteed to be stable across
+// logins. Clients should match returning users on verified claims such as
+// email, not on sub.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// Status is the position of a login in its lifecycle.
+type Status string
+
+const (
+	// StatusPending logins wait for the user to connect.
+	StatusPending Status = "pending"
+	// StatusConnected logins wait for the user to share credentials.
+	StatusConnected Status = "connected"
+	// StatusComplete logins have an authorization code for the client.
+	StatusComplete Status = "complete"
+	// StatusFailed logins have an error for the client.
+	StatusFailed Status = "failed"
+)
+
+// Login is an authorization request a user is completing.
+type Login struct {
+	ID            string
+	ClientID      string
+	RedirectURI   string
+	State         string
+	Nonce         string
+	CodeChallenge string
+	Scopes        []string
+	// Definitions are the credential definitions the scopes ask for.
+	Definitions []string
+	ExpiresAt   time.Time
+}
+
+// Challenge is what the user is shown to log in: a connection QR code and
+// app link. Logins always start by connecting, login hints are ignored, so
+// nobody can make requests pop up in someone else's app.
+type Challenge struct {
+	QRCode []byte // PNG
+	Link   string
+}
+
+// Authenticator starts asking the user behind a login for the credentials
+// of the login's definitions.
+type Authenticator interface {
+	Authenticate(login Login) (Challenge, error)
+}
+
+type loginState struct {
+	Login
+	status   Status
+	redirect string
+}
+
+// grant is what an authorization code or access token stands for.
+type grant struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	subject       string
+	scopes        []string
+	claims        map[string]any
+	authTime      time.Time
+	expiresAt     time.Time
+}
+
+// Provider is the OpenID Connect provider.
+type Provider struct {
+	issuer      string
+	signer      *Signer
+	auth        Authenticator
+	clients     map[string]config.OIDCClient
+	scopes      map[string][]string
+	loginExpiry time.Duration
+	codeExpiry  time.Duration
+	tokenExpiry time.Duration
+
+	mu     sync.Mutex
+	logins map[string]*loginState
+	codes  map[string]*grant
+	tokens map[string]*grant
+}
+
+// New returns a provider for the clients and scopes in cfg, signing ID
+// tokens with signer.
+func New(cfg config.OIDC, signer *Signer, auth Authenticator) *Provider {
+	p := &Provider{
+		issuer:      strings.TrimSuffix(cfg.Issuer, "/"),
+		signer:      signer,
+		auth:        auth,
+		clients:     make(map[string]config.OIDCClient),
+		scopes:      cfg.Scopes,
+		loginExpiry: cfg.LoginExpiry,
+		codeExpiry:  cfg.CodeExpiry,
+		tokenExpiry: cfg.TokenExpiry,
+		logins:      make(map[string]*loginState),
+		codes:       make(map[string]*grant),
+		tokens:      make(map[string]*grant),
+	}
+
+	for _, client := range cfg.Clients {
+		p.clients[client.ID] = client
+	}
+
+	return p
+}
+
+// Register serves the provider's endpoints on mux. The authorization
+// endpoint, which starts a login for anyone who asks, is wrapped in limit.
+func (p *Provider) Register(mux *http.ServeMux, limit func(http.HandlerFunc) http.Handler) {
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("GET /oidc/jwks", p.handleJWKS)
+	mux.Handle("GET /oidc/authorize", limit(p.handleAuthorize))
+	mux.HandleFunc("GET /oidc/logins/{id}", p.handleLoginStatus)
+	mux.HandleFunc("POST /oidc/token", p.handleToken)
+	mux.HandleFunc("GET /oidc/userinfo", p.handleUserinfo)
+	mux.HandleFunc("POST /oidc/userinfo", p.handleUserinfo)
+}
+
+// Connected records that the user behind a login has connected.
+func (p *Provider) Connected(loginID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.logins[loginID]
+	if ok && login.status == StatusPending {
+		login.status = StatusConnected
+	}
+}
+
+// Verified completes a login for subject, with the credentials they shared,
+// and issues the client's authorization code. subject becomes the sub claim
+// and need not be the same for the same user across logins.
+func (p *Provider) Verified(loginID, subject string, credentials []verification.Credential) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.logins[loginID]
+	if !ok || login.status == StatusComplete || login.status == StatusFailed {
+		return
+	}
+
+	now := time.Now()
+	code := newToken()
+
+	p.codes[code] = &grant{
+		clientID:      login.ClientID,
+		redirectURI:   login.RedirectURI,
+		codeChallenge: login.CodeChallenge,
+		nonce:         login.Nonce,
+		subject:       subject,
+		scopes:        login.Scopes,
+		claims:        Claims(credentials),
+		authTime:      now,
+		expiresAt:     now.Add(p.codeExpiry),
+	}
+
+	login.status = StatusComplete
+	login.redirect = redirectURL(login.RedirectURI, url.Values{"code": {code}, "state": {login.State}})
+}
+
+// Failed ends a login with an access_denied error for the client.
+func (p *Provider) Failed(loginID, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.logins[loginID]
+	if ok {
+		p.fail(login, reason)
+	}
+}
+
+func (p *Provider) fail(login *loginState, reason string) {
+	if login.status == StatusComplete || login.status == StatusFailed {
+		return
+	}
+
+	login.status = StatusFailed
+	login.redirect = redirectURL(login.RedirectURI, url.Values{
+		"error":             {"access_denied"},
+		"error_description": {reason},
+		"state":             {login.State},
+	})
+}
+
+// Run forgets expired logins, codes and tokens every interval.
+func (p *Provider) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		p.mu.Lock()
+
+		// finished logins are kept a while for the page to pick up
+		maps.DeleteFunc(p.logins, func(_ string, l *loginState) bool {
+			return now.After(l.ExpiresAt.Add(p.loginExpiry))
+		})
+		maps.DeleteFunc(p.codes, func(_ string, g *grant) bool {
+			return now.After(g.expiresAt)
+		})
+		maps.DeleteFunc(p.tokens, func(_ string, g *grant) bool {
+			return now.After(g.expiresAt)
+		})
+
+		p.mu.Unlock()
+	}
+}
+
+func (p *Provider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/oidc/authorize",
+		"token_endpoint":                        p.issuer + "/oidc/token",
+		"userinfo_endpoint":                     p.issuer + "/oidc/userinfo",
+		"jwks_uri":                              p.issuer + "/oidc/jwks",
+		"scopes_supported":                      slices.Sorted(maps.Keys(p.scopes)),
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      supportedClaims,
+	})
+}
+
+func (p *Provider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []JWK{p.signer.JWK()},
+	})
+}
+
+func (p *Provider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// errors about the client or redirect URI must not be redirected
+	client, ok := p.clients[query.Get("client_id")]
+	if !ok {
+		renderError(w, http.StatusBadRequest, "The application asking you to log in is not registered.")
+		return
+	}
+
+	redirectURI := query.Get("redirect_uri")
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		renderError(w, http.StatusBadRequest, "The application asked to return you to an address it has not registered.")
+		return
+	}
+
+	state := query.Get("state")
+	fail := func(code, description string) {
+		http.Redirect(w, r, redirectURL(redirectURI, url.Values{
+			"error":             {code},
+			"error_description": {description},
+			"state":             {state},
+		}), http.StatusFound)
+	}
+
+	if query.Get("response_type") != "code" {
+		fail("unsupported_response_type", "only the code response type is supported")
+		return
+	}
+
+	requested := strings.Fields(query.Get("scope"))
+	if !slices.Contains(requested, "openid") {
+		fail("invalid_scope", "the openid scope is required")
+		return
+	}
+
+	challenge := query.Get("code_challenge")
+	if query.Get("code_challenge_method") != "S256" || !validChallenge(challenge) {
+		fail("invalid_request", "PKCE with the S256 code challenge method is required")
+		return
+	}
+
+	if slices.Contains(strings.Fields(query.Get("prompt")), "none") {
+		fail("login_required", "logging in with Self needs the user")
+		return
+	}
+
+	// unknown scopes are ignored
+	var scopes, definitions []string
+	for _, scope := range requested {
+		names, ok := p.scopes[scope]
+		if !ok || slices.Contains(scopes, scope) {
+			continue
+		}
+		scopes = append(scopes, scope)
+		for _, name := range names {
+			if !slices.Contains(definitions, name) {
+				definitions = append(definitions, name)
+			}
+		}
+	}
+
+	login := Login{
+		ID:            newToken(),
+		ClientID:      client.ID,
+		RedirectURI:   redirectURI,
+		State:         state,
+		Nonce:         query.Get("nonce"),
+		CodeChallenge: challenge,
+		Scopes:        scopes,
+		Definitions:   definitions,
+		ExpiresAt:     time.Now().Add(p.loginExpiry),
+	}
+
+	// track the login first, the authenticator may report back at once
+	p.mu.Lock()
+	p.logins[login.ID] = &loginState{Login: login, status: StatusPending}
+	p.mu.Unlock()
+
+	loginChallenge, err := p.auth.Authenticate(login)
+	if err != nil {
+		log.Printf("OIDC: Failed to start login %s for %s: %v", login.ID, client.ID, err)
+
+		p.mu.Lock()
+		delete(p.logins, login.ID)
+		p.mu.Unlock()
+
+		fail("server_error", "the login could not be started")
+		return
+	}
+
+	renderLogin(w, login, loginChallenge)
+}
+
+// loginStatus is polled by the login page
+type loginStatus struct {
+	Status   Status `json:"status"`
+	Redirect string `json:"redirect,omitempty"`
+}
+
+func (p *Provider) handleLoginStatus(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.logins[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "login not found", http.StatusNotFound)
+		return
+	}
+
+	if time.Now().After(login.ExpiresAt) {
+		p.fail(login, "the login expired")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, loginStatus{Status: login.status, Redirect: login.redirect})
+}
+
+func (p *Provider) handleToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	err := r.ParseForm()
+	if err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "the request body could not be parsed")
+		return
+	}
+
+	client, ok := p.authenticateClient(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oidc"`)
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only the authorization_code grant is supported")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	// codes are single use, whether or not the exchange succeeds
+	p.mu.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	now := time.Now()
+
+	switch {
+	case !ok || now.After(g.expiresAt):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "the code is invalid or expired")
+		return
+	case g.clientID != client.ID:
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "the code was issued to another client")
+		return
+	case g.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "the redirect_uri does not match the authorization request")
+		return
+	case !verifyChallenge(g.codeChallenge, r.PostForm.Get("code_verifier")):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "the code_verifier does not match the code challenge")
+		return
+	}
+
+	claims := map[string]any{
+		"iss":       p.issuer,
+		"sub":       g.subject,
+		"aud":       client.ID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(p.tokenExpiry).Unix(),
+		"auth_time": g.authTime.Unix(),
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	for name, value := range g.claims {
+		claims[name] = value
+	}
+
+	idToken, err := p.signer.Sign(claims)
+	if err != nil {
+		log.Printf("OIDC: Failed to sign ID token for %s: %v", client.ID, err)
+		tokenError(w, http.StatusInternalServerError, "server_error", "the ID token could not be signed")
+		return
+	}
+
+	accessToken := newToken()
+
+	p.mu.Lock()
+	access := *g
+	access.expiresAt = now.Add(p.tokenExpiry)
+	p.tokens[accessToken] = &access
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(p.tokenExpiry.Seconds()),
+		"id_token":     idToken,
+		"scope":        strings.Join(g.scopes, " "),
+	})
+}
+
+// authenticateClient identifies the client calling the token endpoint, with
+// HTTP basic authentication or form parameters. Clients with a secret must
+// present it.
+func (p *Provider) authenticateClient(r *http.Request) (config.OIDCClient, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// basic credentials are form encoded, RFC 6749 2.3.1
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	client, ok := p.clients[id]
+	if !ok {
+		return config.OIDCClient{}, false
+	}
+
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(client.Secret)) != 1 {
+		return config.OIDCClient{}, false
+	}
+
+	return client, true
+}
+
+func (p *Provider) handleUserinfo(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	p.mu.Lock()
+	g, ok := p.tokens[token]
+	p.mu.Unlock()
+
+	if token == "" || !ok || time.Now().After(g.expiresAt) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid access token", http.StatusUnauthorized)
+		return
+	}
+
+	claims := map[string]any{"sub": g.subject}
+	for name, value := range g.claims {
+		claims[name] = value
+	}
+
+	writeJSON(w, http.StatusOK, claims)
+}
+
+// validChallenge checks an S256 code challenge is a base64url SHA-256 hash
+func validChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+// verifyChallenge checks a PKCE code verifier against its S256 challenge
+func verifyChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func redirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query[key] = values
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func tokenError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("OIDC: Failed to encode response: %v", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
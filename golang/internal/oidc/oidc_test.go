@@ -0,0 +1,467 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// fakeAuthenticator records the logins it is asked to start
+type fakeAuthenticator struct {
+	mu     sync.Mutex
+	logins []Login
+	err    error
+}
+
+func (a *fakeAuthenticator) Authenticate(login Login) (Challenge, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return Challenge{}, a.err
+	}
+
+	a.logins = append(a.logins, login)
+	return Challenge{QRCode: []byte("png"), Link: "https://links.example.com/connect"}, nil
+}
+
+func (a *fakeAuthenticator) last() Login {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.logins[len(a.logins)-1]
+}
+
+type testProvider struct {
+	*Provider
+	auth    *fakeAuthenticator
+	handler http.Handler
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &fakeAuthenticator{}
+	p := New(config.OIDC{
+		Issuer: "https://self.example.com/",
+		Clients: []config.OIDCClient{
+			{ID: "app", Secret: "secret", RedirectURIs: []string{testRedirectURI}},
+			{ID: "public", RedirectURIs: []string{testRedirectURI}},
+		},
+		Scopes: map[string][]string{
+			"openid": {"liveness"},
+			"email":  {"email"},
+		},
+		LoginExpiry: 10 * time.Minute,
+		CodeExpiry:  time.Minute,
+		TokenExpiry: time.Hour,
+	}, signer, auth)
+
+	mux := http.NewServeMux()
+	p.Register(mux, func(next http.HandlerFunc) http.Handler { return next })
+
+	return &testProvider{Provider: p, auth: auth, handler: mux}
+}
+
+func (tp *testProvider) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	tp.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (tp *testProvider) authorize(params url.Values) *httptest.ResponseRecorder {
+	return tp.serve(httptest.NewRequest(http.MethodGet, "/oidc/authorize?"+params.Encode(), nil))
+}
+
+func (tp *testProvider) token(form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oidc/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(clientID, secret)
+	}
+	return tp.serve(req)
+}
+
+func authorizeParams(clientID string) url.Values {
+	hash := sha256.Sum256([]byte(testVerifier))
+
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid email unknown"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(hash[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// login authorizes a client, completes the login for subject and returns
+// the authorization code the client is redirected with
+func (tp *testProvider) login(t *testing.T, clientID, subject string) string {
+	t.Helper()
+
+	w := tp.authorize(authorizeParams(clientID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize = %d: %s", w.Code, w.Body)
+	}
+
+	login := tp.auth.last()
+	tp.Connected(login.ID)
+	tp.Verified(login.ID, subject, []verification.Credential{{
+		Valid:  true,
+		Claims: map[string]any{"emailAddress": "ada@example.com"},
+	}})
+
+	status := tp.loginStatus(t, login.ID)
+	if status.Status != StatusComplete {
+		t.Fatalf("login status = %+v", status)
+	}
+
+	redirect, err := url.Parse(status.Redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redirect.Query().Get("state") != "xyz" {
+		t.Errorf("redirect = %s, want the state", redirect)
+	}
+
+	return redirect.Query().Get("code")
+}
+
+func (tp *testProvider) loginStatus(t *testing.T, id string) loginStatus {
+	t.Helper()
+
+	w := tp.serve(httptest.NewRequest(http.MethodGet, "/oidc/logins/"+id, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d", w.Code)
+	}
+
+	var status loginStatus
+	err := json.Unmarshal(w.Body.Bytes(), &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+
+	var body map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	return body
+}
+
+func TestDiscovery(t *testing.T) {
+	tp := newTestProvider(t)
+
+	w := tp.serve(httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("discovery = %d", w.Code)
+	}
+
+	body := decodeBody(t, w)
+	for name, want := range map[string]string{
+		"issuer":                 "https://self.example.com",
+		"authorization_endpoint": "https://self.example.com/oidc/authorize",
+		"token_endpoint":         "https://self.example.com/oidc/token",
+		"userinfo_endpoint":      "https://self.example.com/oidc/userinfo",
+		"jwks_uri":               "https://self.example.com/oidc/jwks",
+	} {
+		if body[name] != want {
+			t.Errorf("%s = %v, want %s", name, body[name], want)
+		}
+	}
+
+	if methods, _ := body["code_challenge_methods_supported"].([]any); len(methods) != 1 || methods[0] != "S256" {
+		t.Errorf("code_challenge_methods_supported = %v", body["code_challenge_methods_supported"])
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tp := newTestProvider(t)
+
+	w := tp.authorize(authorizeParams("app"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "data:image/png;base64,") {
+		t.Fatalf("authorize = %d: %s", w.Code, w.Body)
+	}
+
+	login := tp.auth.last()
+	if login.ClientID != "app" || !slices.Equal(login.Scopes, []string{"openid", "email"}) || !slices.Equal(login.Definitions, []string{"liveness", "email"}) {
+		t.Errorf("login = %+v", login)
+	}
+
+	if status := tp.loginStatus(t, login.ID); status.Status != StatusPending || status.Redirect != "" {
+		t.Errorf("login status = %+v", status)
+	}
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	tp := newTestProvider(t)
+
+	// errors about the client or redirect URI are shown, not redirected
+	for name, params := range map[string]url.Values{
+		"unknown client":       {"client_id": {"unknown"}, "redirect_uri": {testRedirectURI}},
+		"unknown redirect URI": {"client_id": {"app"}, "redirect_uri": {"https://evil.example.com/"}},
+	} {
+		if w := tp.authorize(params); w.Code != http.StatusBadRequest || w.Header().Get("Location") != "" {
+			t.Errorf("%s: authorize = %d, redirected to %q", name, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		param string
+		value string
+		want  string
+	}{
+		{"token response type", "response_type", "token", "unsupported_response_type"},
+		{"no openid scope", "scope", "email", "invalid_scope"},
+		{"plain PKCE", "code_challenge_method", "plain", "invalid_request"},
+		{"no code challenge", "code_challenge", "", "invalid_request"},
+		{"no prompt", "prompt", "none", "login_required"},
+	} {
+		params := authorizeParams("app")
+		params.Set(tc.param, tc.value)
+
+		w := tp.authorize(params)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		if w.Code != http.StatusFound || location.Query().Get("error") != tc.want || location.Query().Get("state") != "xyz" {
+			t.Errorf("%s: authorize = %d, redirected to %s, want error %s", tc.name, w.Code, location, tc.want)
+		}
+	}
+
+	if len(tp.auth.logins) != 0 {
+		t.Errorf("started %d logins for invalid requests", len(tp.auth.logins))
+	}
+
+	tp.auth.err = errors.New("no inbox")
+
+	w := tp.authorize(authorizeParams("app"))
+	location, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || location.Query().Get("error") != "server_error" {
+		t.Errorf("failed login: authorize = %d, redirected to %s", w.Code, location)
+	}
+}
+
+func TestLoginFailed(t *testing.T) {
+	tp := newTestProvider(t)
+
+	tp.authorize(authorizeParams("app"))
+	login := tp.auth.last()
+	tp.Failed(login.ID, "declined")
+
+	status := tp.loginStatus(t, login.ID)
+	redirect, _ := url.Parse(status.Redirect)
+	if status.Status != StatusFailed || redirect.Query().Get("error") != "access_denied" || redirect.Query().Get("error_description") != "declined" {
+		t.Errorf("login status = %+v", status)
+	}
+
+	// a failed login cannot be completed later
+	tp.Verified(login.ID, "peer", nil)
+	if status := tp.loginStatus(t, login.ID); status.Status != StatusFailed {
+		t.Errorf("login status after verifying = %+v", status)
+	}
+}
+
+func TestToken(t *testing.T) {
+	tp := newTestProvider(t)
+	code := tp.login(t, "app", "peer-address")
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+
+	w := tp.token(form, "app", "secret")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("token = %d: %s", w.Code, w.Body)
+	}
+
+	body := decodeBody(t, w)
+	if body["token_type"] != "Bearer" || body["scope"] != "openid email" {
+		t.Errorf("token response = %v", body)
+	}
+
+	idToken, _ := body["id_token"].(string)
+	claims, err := tp.signer.Verify(idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]any{
+		"iss":            "https://self.example.com",
+		"sub":            "peer-address",
+		"aud":            "app",
+		"nonce":          "n-0S6_WzA2Mj",
+		"email":          "ada@example.com",
+		"email_verified": true,
+	} {
+		if claims[name] != want {
+			t.Errorf("ID token %s = %v, want %v", name, claims[name], want)
+		}
+	}
+
+	// codes are single use
+	w = tp.token(form, "app", "secret")
+	if w.Code != http.StatusBadRequest || decodeBody(t, w)["error"] != "invalid_grant" {
+		t.Errorf("reused code = %d: %s", w.Code, w.Body)
+	}
+
+	// the access token reads the same claims
+	req := httptest.NewRequest(http.MethodGet, "/oidc/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+
+	w = tp.serve(req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("userinfo = %d", w.Code)
+	}
+	if info := decodeBody(t, w); info["sub"] != "peer-address" || info["email"] != "ada@example.com" {
+		t.Errorf("userinfo = %v", info)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	tp := newTestProvider(t)
+
+	for _, tc := range []struct {
+		name           string
+		client, secret string
+		change         func(url.Values)
+		status         int
+		want           string
+	}{
+		{"wrong verifier", "app", "secret", func(f url.Values) { f.Set("code_verifier", strings.Repeat("a", 43)) }, http.StatusBadRequest, "invalid_grant"},
+		{"no verifier", "app", "secret", func(f url.Values) { f.Del("code_verifier") }, http.StatusBadRequest, "invalid_grant"},
+		{"redirect URI mismatch", "app", "secret", func(f url.Values) { f.Set("redirect_uri", testRedirectURI+"/other") }, http.StatusBadRequest, "invalid_grant"},
+		{"unknown code", "app", "secret", func(f url.Values) { f.Set("code", "unknown") }, http.StatusBadRequest, "invalid_grant"},
+		{"another client", "public", "", func(url.Values) {}, http.StatusBadRequest, "invalid_grant"},
+		{"wrong secret", "app", "wrong", func(url.Values) {}, http.StatusUnauthorized, "invalid_client"},
+		{"refresh grant", "app", "secret", func(f url.Values) { f.Set("grant_type", "refresh_token") }, http.StatusBadRequest, "unsupported_grant_type"},
+	} {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {tp.login(t, "app", "peer-address")},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {testVerifier},
+		}
+		tc.change(form)
+
+		w := tp.token(form, tc.client, tc.secret)
+		if w.Code != tc.status || decodeBody(t, w)["error"] != tc.want {
+			t.Errorf("%s: token = %d: %s, want %d %s", tc.name, w.Code, w.Body, tc.status, tc.want)
+		}
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	tp := newTestProvider(t)
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"public"},
+		"code":          {tp.login(t, "public", "peer-address")},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+
+	w := tp.token(form, "", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("token = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	tp := newTestProvider(t)
+
+	w := tp.serve(httptest.NewRequest(http.MethodGet, "/oidc/jwks", nil))
+
+	var body struct {
+		Keys []JWK `json:"keys"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Keys) != 1 || body.Keys[0] != tp.signer.JWK() {
+		t.Fatalf("keys = %+v, want %+v", body.Keys, tp.signer.JWK())
+	}
+	if key := body.Keys[0]; key.Kty != "EC" || key.Crv != "P-256" || key.Alg != "ES256" || key.Kid == "" {
+		t.Errorf("key = %+v", key)
+	}
+
+	token, err := tp.signer.Sign(map[string]any{"sub": "peer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if !strings.Contains(string(header), body.Keys[0].Kid) {
+		t.Errorf("token header %s does not name the key", header)
+	}
+
+	claims, err := tp.signer.Verify(token)
+	if err != nil || claims["sub"] != "peer" {
+		t.Errorf("Verify = %v, %v", claims, err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"someone else"}`)) + "." + parts[2]
+	if _, err := tp.signer.Verify(forged); err == nil {
+		t.Error("Verify accepted a token with a changed payload")
+	}
+}
+
+func TestUserinfoErrors(t *testing.T) {
+	tp := newTestProvider(t)
+
+	for _, header := range []string{"", "Bearer ", "Bearer unknown"} {
+		req := httptest.NewRequest(http.MethodGet, "/oidc/userinfo", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		w := tp.serve(req)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), "invalid_token") {
+			t.Errorf("userinfo with %q = %d", header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// pages holds the login page and the error page shown when a client cannot
+// be redirected to.
+var pages = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Log in with Self</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; text-align: center; color: #222; }
+img { width: 16rem; height: 16rem; image-rendering: pixelated; }
+#status { color: #666; }
+</style>
+</head>
+<body>{{end}}
+
+{{define "login"}}{{template "head"}}
+<h1>Log in with Self</h1>
+<p>Scan the code with the Self app to connect and share your details.</p>
+<img src="{{.QRCode}}" alt="Self connection QR code">
+{{if .Link}}<p><a href="{{.Link}}">Open the Self app on this device</a></p>{{end}}
+<p id="status">Waiting for the Self app&hellip;</p>
+<script>
+(function () {
+  var status = document.getElementById("status");
+  function poll() {
+    fetch({{.StatusURL}}, {cache: "no-store"})
+      .then(function (r) { return r.ok ? r.json() : Promise.reject(r.status); })
+      .then(function (login) {
+        if (login.redirect) {
+          window.location = login.redirect;
+          return;
+        }
+        if (login.status === "connected") {
+          status.textContent = "Connected. Share your details in the Self app to continue.";
+        }
+        setTimeout(poll, 2000);
+      })
+      .catch(function () {
+        status.textContent = "This login is no longer available, please start again.";
+      });
+  }
+  setTimeout(poll, 2000);
+})();
+</script>
+</body>
+</html>{{end}}
+
+{{define "error"}}{{template "head"}}
+<h1>Log in with Self</h1>
+<p>{{.}}</p>
+</body>
+</html>{{end}}
+`))
+
+// loginPage is the data of the login page
+type loginPage struct {
+	QRCode    template.URL
+	Link      template.URL
+	StatusURL string
+}
+
+func renderLogin(w http.ResponseWriter, login Login, challenge Challenge) {
+	page := loginPage{
+		StatusURL: "/oidc/logins/" + login.ID,
+		Link:      template.URL(challenge.Link),
+	}
+	if len(challenge.QRCode) > 0 {
+		page.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(challenge.QRCode))
+	}
+
+	render(w, http.StatusOK, "login", page)
+}
+
+func renderError(w http.ResponseWriter, status int, message string) {
+	render(w, status, "error", message)
+}
+
+func render(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	err := pages.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		log.Printf("OIDC: Failed to render %s page: %v", name, err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,205 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Signer signs ID tokens with an ES256 key.
+type Signer struct {
+	key *ecdsa.PrivateKey
+	kid string
+}
+
+// LoadSigner reads the PEM encoded P-256 key at path, creating the key if
+// the file does not exist.
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return createSigner(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("oidc: %s does not contain a PEM encoded key", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("oidc: %s contains a %s, not a private key", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to parse signing key: %w", err)
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("oidc: %s must contain a P-256 key for ES256", path)
+	}
+
+	return NewSigner(ecKey)
+}
+
+func createSigner(path string) (*Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to encode signing key: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to create signing key: %w", err)
+	}
+
+	err = pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("oidc: failed to write signing key: %w", err)
+	}
+
+	return NewSigner(key)
+}
+
+// NewSigner returns a signer for key, which must be a P-256 key. Its key ID
+// is the key's RFC 7638 thumbprint.
+func NewSigner(key *ecdsa.PrivateKey) (*Signer, error) {
+	s := &Signer{key: key}
+
+	jwk, err := s.publicJWK()
+	if err != nil {
+		return nil, err
+	}
+
+	// the thumbprint hashes the required members in lexicographic order
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)))
+	s.kid = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	return s, nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWK returns the signer's public key.
+func (s *Signer) JWK() JWK {
+	jwk, _ := s.publicJWK()
+	jwk.Use = "sig"
+	jwk.Alg = "ES256"
+	jwk.Kid = s.kid
+	return jwk
+}
+
+func (s *Signer) publicJWK() (JWK, error) {
+	pub, err := s.key.PublicKey.ECDH()
+	if err != nil || s.key.Curve != elliptic.P256() {
+		return JWK{}, errors.New("oidc: signing key must be a P-256 key")
+	}
+
+	// uncompressed point: 0x04 || x || y
+	point := pub.Bytes()
+
+	return JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}, nil
+}
+
+// Sign returns claims as a JWT signed with ES256.
+func (s *Signer) Sign(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": s.kid})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to sign token: %w", err)
+	}
+
+	// JWS uses the fixed size r || s encoding, not ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature of a JWT signed by s and returns its claims.
+// It does not check any of the claims.
+func (s *Signer) Verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, errors.New("oidc: malformed token signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	r, sig := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&s.key.PublicKey, digest[:], r, sig) {
+		return nil, errors.New("oidc: invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("oidc: malformed token payload")
+	}
+
+	var claims map[string]any
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, errors.New("oidc: malformed token payload")
+	}
+
+	return claims, nil
+}
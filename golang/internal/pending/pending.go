@@ -0,0 +1,250 @@
+// Package pending tracks the requests sent to peers until they are answered,
+// so every response can be matched to the request it answers. Responses that
+// answer no request, answer one twice or arrive after it expired are
+// rejected.
+package pending
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/definition"
+)
+
+// Kind is the type of message a request was sent as.
+type Kind string
+
+const (
+	// KindCredentialPresentation requests ask a peer to present credentials.
+	KindCredentialPresentation Kind = "credential_presentation"
+	// KindCredentialVerification requests ask a peer to sign an agreement.
+	KindCredentialVerification Kind = "credential_verification"
+)
+
+// Origin is what started a request.
+type Origin string
+
+const (
+	// OriginChat requests were started by a chat command of the peer.
+	OriginChat Origin = "chat"
+	// OriginAPI requests were started through the HTTP API.
+	OriginAPI Origin = "api"
+	// OriginGRPC requests were started through the gRPC service.
+	OriginGRPC Origin = "grpc"
+	// OriginAuthentication requests are part of a browser login or session.
+	OriginAuthentication Origin = "authentication"
+)
+
+// State is the position of a request in its lifecycle.
+type State string
+
+const (
+	// StatePending requests are waiting for a response.
+	StatePending State = "pending"
+	// StateAnswered requests have received their response.
+	StateAnswered State = "answered"
+	// StateExpired requests were not answered in time.
+	StateExpired State = "expired"
+)
+
+var (
+	// ErrNotFound is returned when no request matches a lookup.
+	ErrNotFound = errors.New("pending: not found")
+	// ErrUnsolicited is returned for responses to requests we did not send.
+	ErrUnsolicited = errors.New("pending: unsolicited response")
+	// ErrDuplicate is returned for responses to requests already answered.
+	ErrDuplicate = errors.New("pending: duplicate response")
+	// ErrLate is returned for responses to requests that have expired.
+	ErrLate = errors.New("pending: late response")
+)
+
+// Request is a request sent to a peer.
+type Request struct {
+	// ID is the hex encoded ID of the message content the request was sent
+	// as. Responses refer to it.
+	ID          string `json:"id"`
+	Kind        Kind   `json:"kind"`
+	Origin      Origin `json:"origin"`
+	PeerAddress string `json:"peer_address"`
+	// Subject describes what was requested, such as a credential type.
+	Subject string `json:"subject"`
+	// Conditions the requested credentials must satisfy, as sent in the
+	// request's predicate tree, if any.
+	Conditions *definition.Node `json:"-"`
+	State      State            `json:"state"`
+	CreatedAt  time.Time        `json:"created_at"`
+	ExpiresAt  time.Time        `json:"expires_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	// Outcome is what came of an answered request once its response has
+	// been handled, such as a verification decision or a signing status.
+	Outcome string `json:"outcome,omitempty"`
+	// Result holds the details of the outcome, such as a verification
+	// result.
+	Result any `json:"result,omitempty"`
+}
+
+// Registry holds the requests sent to peers, keyed by ID.
+type Registry struct {
+	mu        sync.Mutex
+	requests  map[string]*Request
+	retention time.Duration
+}
+
+// NewRegistry returns an empty registry. Answered and expired requests are
+// kept for retention, so duplicate and late responses can be told apart from
+// unsolicited ones.
+func NewRegistry(retention time.Duration) *Registry {
+	return &Registry{
+		requests:  make(map[string]*Request),
+		retention: retention,
+	}
+}
+
+// Track records a request that is about to be sent.
+func (r *Registry) Track(req Request) (Request, error) {
+	if req.ID == "" {
+		return Request{}, errors.New("pending: request has no ID")
+	}
+
+	now := time.Now()
+	req.State = StatePending
+	req.CreatedAt = now
+	req.UpdatedAt = now
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.requests[req.ID]
+	if exists {
+		return Request{}, fmt.Errorf("pending: request %s is already tracked", req.ID)
+	}
+
+	r.requests[req.ID] = &req
+
+	return req, nil
+}
+
+// Forget drops a request, for one that could not be sent.
+func (r *Registry) Forget(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.requests, id)
+}
+
+// Get returns the request with the given ID.
+func (r *Registry) Get(id string) (Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[id]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+
+	return *req, nil
+}
+
+// Resolve records the response from peerAddress to the request with the given
+// ID, and returns the request. The response is rejected unless it answers a
+// pending request of the same kind sent to the same peer.
+func (r *Registry) Resolve(id string, kind Kind, peerAddress string) (Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[id]
+	if !ok || id == "" {
+		return Request{}, fmt.Errorf("%w: no request %q", ErrUnsolicited, id)
+	}
+
+	if req.Kind != kind {
+		return Request{}, fmt.Errorf("%w: request %s is a %s request", ErrUnsolicited, id, req.Kind)
+	}
+
+	if req.PeerAddress != peerAddress {
+		return Request{}, fmt.Errorf("%w: request %s was sent to another peer", ErrUnsolicited, id)
+	}
+
+	now := time.Now()
+
+	switch {
+	case req.State == StateAnswered:
+		return *req, fmt.Errorf("%w: request %s was answered at %s", ErrDuplicate, id, req.UpdatedAt.Format(time.RFC3339))
+	case req.State == StateExpired || now.After(req.ExpiresAt):
+		return *req, fmt.Errorf("%w: request %s expired at %s", ErrLate, id, req.ExpiresAt.Format(time.RFC3339))
+	}
+
+	req.State = StateAnswered
+	req.UpdatedAt = now
+
+	return *req, nil
+}
+
+// Complete records the outcome of handling the response to an answered
+// request.
+func (r *Registry) Complete(id string, outcome string, result any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if req.State != StateAnswered {
+		return fmt.Errorf("pending: request %s is %s, not answered", id, req.State)
+	}
+
+	req.Outcome = outcome
+	req.Result = result
+	req.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// Expire marks pending requests past their expiry as expired, forgets
+// finished requests older than the retention period and returns the requests
+// that expired.
+func (r *Registry) Expire(now time.Time) []Request {
+	var expired []Request
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, req := range r.requests {
+		switch req.State {
+		case StatePending:
+			if now.After(req.ExpiresAt) {
+				req.State = StateExpired
+				req.UpdatedAt = now
+				expired = append(expired, *req)
+			}
+		case StateAnswered, StateExpired:
+			if now.Sub(req.UpdatedAt) > r.retention {
+				delete(r.requests, id)
+			}
+		}
+	}
+
+	return expired
+}
+
+// List returns all requests the registry knows about, oldest first.
+func (r *Registry) List() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requests := make([]Request, 0, len(r.requests))
+	for _, req := range r.requests {
+		requests = append(requests, *req)
+	}
+
+	slices.SortFunc(requests, func(a, b Request) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return requests
+}
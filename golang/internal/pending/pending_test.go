@@ -0,0 +1,161 @@
+package pending
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func trackTestRequest(t *testing.T, r *Registry, id string, expiresAt time.Time) Request {
+	t.Helper()
+
+	req, err := r.Track(Request{
+		ID:          id,
+		Kind:        KindCredentialPresentation,
+		Origin:      OriginAPI,
+		PeerAddress: "peer",
+		Subject:     "email",
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestTrack(t *testing.T) {
+	r := NewRegistry(time.Hour)
+
+	req := trackTestRequest(t, r, "1", time.Now().Add(time.Minute))
+	if req.State != StatePending || req.CreatedAt.IsZero() {
+		t.Errorf("tracked request = %+v", req)
+	}
+
+	if _, err := r.Track(Request{ID: "1"}); err == nil {
+		t.Error("a request was tracked twice")
+	}
+	if _, err := r.Track(Request{}); err == nil {
+		t.Error("a request without an ID was tracked")
+	}
+
+	r.Forget("1")
+	if _, err := r.Get("1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("forgotten request = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	trackTestRequest(t, r, "1", time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name string
+		id   string
+		kind Kind
+		peer string
+		want error
+	}{
+		{"unsolicited", "2", KindCredentialPresentation, "peer", ErrUnsolicited},
+		{"no ID", "", KindCredentialPresentation, "peer", ErrUnsolicited},
+		{"wrong kind", "1", KindCredentialVerification, "peer", ErrUnsolicited},
+		{"wrong peer", "1", KindCredentialPresentation, "stranger", ErrUnsolicited},
+		{"answer", "1", KindCredentialPresentation, "peer", nil},
+		{"duplicate", "1", KindCredentialPresentation, "peer", ErrDuplicate},
+	}
+
+	for _, test := range tests {
+		_, err := r.Resolve(test.id, test.kind, test.peer)
+		if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+			t.Errorf("%s: Resolve = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	req, err := r.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.State != StateAnswered {
+		t.Errorf("answered request is %s", req.State)
+	}
+}
+
+func TestResolveLate(t *testing.T) {
+	r := NewRegistry(time.Hour)
+
+	// past its expiry, before Expire has run
+	trackTestRequest(t, r, "1", time.Now().Add(-time.Second))
+	if _, err := r.Resolve("1", KindCredentialPresentation, "peer"); !errors.Is(err, ErrLate) {
+		t.Errorf("response after expiry = %v, want %v", err, ErrLate)
+	}
+
+	// after Expire has run
+	trackTestRequest(t, r, "2", time.Now().Add(time.Minute))
+	r.Expire(time.Now().Add(2 * time.Minute))
+	if _, err := r.Resolve("2", KindCredentialPresentation, "peer"); !errors.Is(err, ErrLate) {
+		t.Errorf("response to an expired request = %v, want %v", err, ErrLate)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	trackTestRequest(t, r, "1", time.Now().Add(time.Minute))
+
+	if err := r.Complete("1", "accepted", nil); err == nil {
+		t.Error("a pending request was completed")
+	}
+	if err := r.Complete("2", "accepted", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("completing an unknown request = %v, want %v", err, ErrNotFound)
+	}
+
+	_, err := r.Resolve("1", KindCredentialPresentation, "peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Complete("1", "accepted", "result")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := r.Get("1")
+	if req.Outcome != "accepted" || req.Result != "result" {
+		t.Errorf("completed request = %+v", req)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	now := time.Now()
+
+	trackTestRequest(t, r, "soon", now.Add(time.Minute))
+	trackTestRequest(t, r, "later", now.Add(time.Hour))
+	trackTestRequest(t, r, "answered", now.Add(time.Minute))
+
+	_, err := r.Resolve("answered", KindCredentialPresentation, "peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := r.Expire(now.Add(2 * time.Minute))
+	if len(expired) != 1 || expired[0].ID != "soon" || expired[0].State != StateExpired {
+		t.Errorf("expired = %+v, want soon", expired)
+	}
+
+	// requests only expire once
+	if expired := r.Expire(now.Add(3 * time.Minute)); len(expired) != 0 {
+		t.Errorf("expired again = %+v", expired)
+	}
+
+	// finished requests are kept for the retention period, pending ones
+	// until they expire
+	r.Expire(now.Add(59 * time.Minute))
+	if n := len(r.List()); n != 3 {
+		t.Errorf("kept %d requests within retention, want 3", n)
+	}
+
+	r.Expire(now.Add(2 * time.Hour))
+	list := r.List()
+	if len(list) != 1 || list[0].ID != "later" || list[0].State != StateExpired {
+		t.Errorf("requests after retention = %+v, want later, expired", list)
+	}
+}
@@ -0,0 +1,88 @@
+// Package ratelimit limits how often each client may do something within a
+// sliding window.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows each key at most a fixed number of events within every
+// window. Keys that have gone quiet are forgotten by Prune.
+type Limiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	seen   map[string][]time.Time
+}
+
+// New returns a limiter allowing limit events per key within every window.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		seen:   make(map[string][]time.Time),
+	}
+}
+
+// Limit returns how many events a key may have within a window.
+func (l *Limiter) Limit() int {
+	return l.limit
+}
+
+// Window returns the window events are counted in.
+func (l *Limiter) Window() time.Duration {
+	return l.window
+}
+
+// Allow records an event for key at now and reports whether it is within
+// the limit. Events over the limit are not recorded.
+func (l *Limiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.seen[key][:0]
+	for _, at := range l.seen[key] {
+		if now.Sub(at) < l.window {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) >= l.limit {
+		l.seen[key] = recent
+		return false
+	}
+
+	l.seen[key] = append(recent, now)
+	return true
+}
+
+// Prune forgets keys without events in the window ending at now.
+func (l *Limiter) Prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, times := range l.seen {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= l.window {
+			delete(l.seen, key)
+		}
+	}
+}
+
+// Run prunes the limiter every interval. It never returns.
+func (l *Limiter) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.Prune(now)
+	}
+}
+
+// size returns how many keys the limiter remembers.
+func (l *Limiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.seen)
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := New(2, time.Minute)
+	now := time.Now()
+
+	if !l.Allow("a", now) || !l.Allow("a", now.Add(time.Second)) {
+		t.Fatal("events within the limit were refused")
+	}
+	if l.Allow("a", now.Add(2*time.Second)) {
+		t.Error("an event over the limit was allowed")
+	}
+	if !l.Allow("b", now) {
+		t.Error("keys should be limited separately")
+	}
+
+	// the first event has left the window
+	if !l.Allow("a", now.Add(time.Minute)) {
+		t.Error("an event was refused after the window moved on")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := New(1, time.Minute)
+	now := time.Now()
+
+	l.Allow("quiet", now)
+	l.Allow("busy", now.Add(50*time.Second))
+
+	l.Prune(now.Add(time.Minute))
+
+	if l.size() != 1 {
+		t.Errorf("remembered %d keys after pruning, want 1", l.size())
+	}
+	if l.Allow("busy", now.Add(time.Minute)) {
+		t.Error("pruning forgot a key still in its window")
+	}
+}
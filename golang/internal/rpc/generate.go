@@ -0,0 +1,5 @@
+// Package rpc holds the gRPC service that lets backend systems start the
+// connection server's flows, generated from connection.proto.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative connection.proto
@@ -0,0 +1,50 @@
+// Package selfaccount describes the parts of a Self SDK account the server
+// uses, so flows can run against an in-memory fake instead of the network.
+package selfaccount
+
+import (
+	"errors"
+	"time"
+
+	"github.com/joinself/self-go-sdk/account"
+	"github.com/joinself/self-go-sdk/credential"
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-go-sdk/identity"
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-go-sdk/object"
+)
+
+// Errors a Classified account wraps to say why a connection could not be
+// accepted or established. The SDK reports these failures as plain errors,
+// so callers should also judge them from what they know about the
+// connection.
+var (
+	// ErrExpired is returned for key packages negotiated for an inbox that
+	// has expired.
+	ErrExpired = errors.New("selfaccount: connection expired")
+	// ErrDuplicate is returned for welcomes and key packages already used.
+	ErrDuplicate = errors.New("selfaccount: connection already established")
+	// ErrMalformed is returned for welcomes and key packages that cannot be
+	// decoded.
+	ErrMalformed = errors.New("selfaccount: malformed connection payload")
+)
+
+// Account is implemented by *account.Account and by Fake.
+type Account interface {
+	InboxOpen() (*signing.PublicKey, error)
+	InboxClose(address *signing.PublicKey) error
+	InboxList() ([]*signing.PublicKey, error)
+	IdentityList() ([]*signing.PublicKey, error)
+	IdentityExecute(operation *identity.Operation) error
+	KeychainSigningCreate() (*signing.PublicKey, error)
+	ConnectionNegotiateOutOfBand(asAddress *signing.PublicKey, expires time.Time) (*event.KeyPackage, error)
+	ConnectionAccept(asAddress *signing.PublicKey, welcome []byte) (*signing.PublicKey, error)
+	ConnectionEstablish(asAddress *signing.PublicKey, keyPackage []byte) (*signing.PublicKey, error)
+	MessageSend(toAddress *signing.PublicKey, content *message.Content) error
+	CredentialIssue(unsigned *credential.Credential) (*credential.VerifiableCredential, error)
+	PresentationIssue(unsigned *credential.Presentation) (*credential.VerifiablePresentation, error)
+	ObjectUpload(obj *object.Object, persist bool) error
+}
+
+var _ Account = (*account.Account)(nil)
@@ -0,0 +1,137 @@
+package selfaccount
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-go-sdk/keypair/signing"
+)
+
+// Classified wraps an Account, usually *account.Account, so that failed
+// connections wrap ErrExpired, ErrDuplicate or ErrMalformed. The SDK reports
+// its failures as plain errors, so the wrapper judges them from what it saw
+// itself: the expiry each inbox negotiated, the payloads already used with
+// each inbox and empty payloads. Other failures are returned as they are.
+//
+// Used payloads are remembered until the inbox's negotiated expiry, or for a
+// day on inboxes that negotiated none, such as the primary inbox.
+type Classified struct {
+	Account
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+	used    map[string]map[[sha256.Size]byte]time.Time
+	now     func() time.Time
+}
+
+// usedRetention is how long a payload used with an inbox that negotiated no
+// expiry is remembered to recognise replays.
+const usedRetention = 24 * time.Hour
+
+// NewClassified returns acc with its connection failures classified.
+func NewClassified(acc Account) *Classified {
+	return &Classified{
+		Account: acc,
+		expires: make(map[string]time.Time),
+		used:    make(map[string]map[[sha256.Size]byte]time.Time),
+		now:     time.Now,
+	}
+}
+
+// InboxClose implements Account, and forgets what was seen for the inbox.
+func (c *Classified) InboxClose(address *signing.PublicKey) error {
+	err := c.Account.InboxClose(address)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.expires, address.String())
+	delete(c.used, address.String())
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ConnectionNegotiateOutOfBand implements Account, and records when the
+// negotiated connection expires.
+func (c *Classified) ConnectionNegotiateOutOfBand(asAddress *signing.PublicKey, expires time.Time) (*event.KeyPackage, error) {
+	kp, err := c.Account.ConnectionNegotiateOutOfBand(asAddress, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.expires[asAddress.String()] = expires
+	c.mu.Unlock()
+
+	return kp, nil
+}
+
+// ConnectionAccept implements Account.
+func (c *Classified) ConnectionAccept(asAddress *signing.PublicKey, welcome []byte) (*signing.PublicKey, error) {
+	return c.connect(asAddress, welcome, c.Account.ConnectionAccept)
+}
+
+// ConnectionEstablish implements Account.
+func (c *Classified) ConnectionEstablish(asAddress *signing.PublicKey, keyPackage []byte) (*signing.PublicKey, error) {
+	return c.connect(asAddress, keyPackage, c.Account.ConnectionEstablish)
+}
+
+func (c *Classified) connect(asAddress *signing.PublicKey, payload []byte, connect func(*signing.PublicKey, []byte) (*signing.PublicKey, error)) (*signing.PublicKey, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("%w: empty payload", ErrMalformed)
+	}
+
+	inbox := asAddress.String()
+	sum := sha256.Sum256(payload)
+
+	c.mu.Lock()
+	expires, negotiated := c.expires[inbox]
+	c.forget(inbox)
+	_, used := c.used[inbox][sum]
+	c.mu.Unlock()
+
+	if used {
+		return nil, fmt.Errorf("%w: payload was already used with %s", ErrDuplicate, inbox)
+	}
+
+	group, err := connect(asAddress, payload)
+	if err != nil {
+		if negotiated && c.now().After(expires) {
+			return nil, fmt.Errorf("%w: %w", ErrExpired, err)
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.used[inbox] == nil {
+		c.used[inbox] = make(map[[sha256.Size]byte]time.Time)
+	}
+	c.used[inbox][sum] = c.now()
+	c.mu.Unlock()
+
+	return group, nil
+}
+
+// forget drops the payloads used with an inbox that no longer need to be
+// remembered. The caller must hold c.mu.
+func (c *Classified) forget(inbox string) {
+	now := c.now()
+
+	if expires, ok := c.expires[inbox]; ok {
+		if now.After(expires) {
+			delete(c.used, inbox)
+		}
+		return
+	}
+
+	for sum, usedAt := range c.used[inbox] {
+		if now.Sub(usedAt) > usedRetention {
+			delete(c.used[inbox], sum)
+		}
+	}
+}
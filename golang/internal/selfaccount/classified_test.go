@@ -0,0 +1,163 @@
+package selfaccount
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+)
+
+func TestClassified(t *testing.T) {
+	fake, err := NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	acc := NewClassified(fake)
+	acc.now = func() time.Time { return now }
+
+	inbox, err := acc.InboxOpen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acc.ConnectionNegotiateOutOfBand(inbox, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acc.ConnectionEstablish(inbox, nil)
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("empty key package = %v, want %v", err, ErrMalformed)
+	}
+
+	_, err = acc.ConnectionEstablish(inbox, []byte("key package"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acc.ConnectionEstablish(inbox, []byte("key package"))
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("replayed key package = %v, want %v", err, ErrDuplicate)
+	}
+	if n := len(fake.Connections()); n != 1 {
+		t.Errorf("the SDK made %d connections, want 1", n)
+	}
+
+	// failures before the negotiated expiry are left to the caller
+	network := errors.New("Network")
+	fake.Fail("ConnectionAccept", network)
+
+	_, err = acc.ConnectionAccept(inbox, []byte("welcome"))
+	if !errors.Is(err, network) || errors.Is(err, ErrExpired) {
+		t.Errorf("failure before expiry = %v, want %v", err, network)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	_, err = acc.ConnectionAccept(inbox, []byte("welcome"))
+	if !errors.Is(err, ErrExpired) || !errors.Is(err, network) {
+		t.Errorf("failure after expiry = %v, want %v wrapping %v", err, ErrExpired, network)
+	}
+
+	// closed inboxes are forgotten
+	err = acc.InboxClose(inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acc.ConnectionEstablish(inbox, []byte("key package"))
+	if err != nil {
+		t.Errorf("key package for a closed inbox = %v, want the SDK's answer", err)
+	}
+}
+
+func TestClassifiedPrimaryInbox(t *testing.T) {
+	fake, err := NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := NewClassified(fake)
+	acc.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+
+	inboxes, err := acc.InboxList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inboxes that negotiated nothing never expire
+	network := errors.New("Network")
+	fake.Fail("ConnectionAccept", network)
+
+	_, err = acc.ConnectionAccept(inboxes[0], []byte("welcome"))
+	if !errors.Is(err, network) || errors.Is(err, ErrExpired) {
+		t.Errorf("failure on the primary inbox = %v, want %v", err, network)
+	}
+}
+
+func TestClassifiedForgetsUsedPayloads(t *testing.T) {
+	fake, err := NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	acc := NewClassified(fake)
+	acc.now = func() time.Time { return now }
+
+	inboxes, err := acc.InboxList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := inboxes[0]
+
+	invitation, err := acc.InboxOpen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acc.ConnectionNegotiateOutOfBand(invitation, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		for _, inbox := range []*signing.PublicKey{primary, invitation} {
+			_, err = acc.ConnectionAccept(inbox, []byte{byte(i)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	used := func(inbox *signing.PublicKey) int {
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		return len(acc.used[inbox.String()])
+	}
+
+	// payloads are remembered for the inbox's negotiated expiry
+	now = now.Add(2 * time.Minute)
+
+	_, err = acc.ConnectionAccept(invitation, []byte("welcome"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := used(invitation); n != 1 {
+		t.Errorf("remembered %d payloads after the inbox expired, want 1", n)
+	}
+
+	// and for a day on inboxes without one
+	now = now.Add(usedRetention)
+
+	_, err = acc.ConnectionAccept(primary, []byte("welcome"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := used(primary); n != 1 {
+		t.Errorf("remembered %d payloads on the primary inbox after a day, want 1", n)
+	}
+}
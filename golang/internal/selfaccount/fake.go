@@ -0,0 +1,314 @@
+package selfaccount
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-go-sdk/credential"
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-go-sdk/identity"
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-go-sdk/object"
+)
+
+// SentMessage is a message sent through a Fake.
+type SentMessage struct {
+	To      *signing.PublicKey
+	Content *message.Content
+}
+
+// Connection is a connection accepted or established through a Fake.
+type Connection struct {
+	// InboxAddress is the inbox the peer connected to.
+	InboxAddress *signing.PublicKey
+	// GroupAddress is the address returned for the connection.
+	GroupAddress *signing.PublicKey
+	// Payload is the welcome or key package the connection was made from.
+	Payload []byte
+}
+
+// Fake is an in-memory Account. Inboxes, keys and connection addresses are
+// generated locally and every call is recorded. Errors can be injected per
+// method by name, for example Fail("MessageSend", err). Methods that return
+// SDK objects return empty ones, which the message builders accept; the
+// Func fields replace that behaviour.
+type Fake struct {
+	mu          sync.Mutex
+	failures    map[string]error
+	inboxes     []*signing.PublicKey
+	identities  []*signing.PublicKey
+	sent        []SentMessage
+	uploaded    []*object.Object
+	connections []Connection
+
+	ConnectionNegotiateOutOfBandFunc func(asAddress *signing.PublicKey, expires time.Time) (*event.KeyPackage, error)
+	CredentialIssueFunc              func(unsigned *credential.Credential) (*credential.VerifiableCredential, error)
+	PresentationIssueFunc            func(unsigned *credential.Presentation) (*credential.VerifiablePresentation, error)
+}
+
+// NewFake returns a Fake with a single open inbox, like a new account.
+func NewFake() (*Fake, error) {
+	address, err := NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fake{
+		failures: make(map[string]error),
+		inboxes:  []*signing.PublicKey{address},
+	}, nil
+}
+
+// NewAddress returns the address of a new random Ed25519 key.
+func NewAddress() (*signing.PublicKey, error) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("selfaccount: failed to generate key: %w", err)
+	}
+
+	// addresses are a key type byte followed by the public key
+	address, err := signing.FromAddress("00" + hex.EncodeToString(public))
+	if err != nil {
+		return nil, fmt.Errorf("selfaccount: failed to decode address: %w", err)
+	}
+
+	return address, nil
+}
+
+// Fail makes every later call to method return err. A nil err clears it.
+func (f *Fake) Fail(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.failures, method)
+		return
+	}
+
+	f.failures[method] = err
+}
+
+// Sent returns the messages sent so far.
+func (f *Fake) Sent() []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.sent)
+}
+
+// Connections returns the connections accepted or established so far.
+func (f *Fake) Connections() []Connection {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.connections)
+}
+
+// Uploaded returns the objects uploaded so far.
+func (f *Fake) Uploaded() []*object.Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.uploaded)
+}
+
+func (f *Fake) failure(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.failures[method]
+}
+
+// InboxOpen implements Account.
+func (f *Fake) InboxOpen() (*signing.PublicKey, error) {
+	err := f.failure("InboxOpen")
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.inboxes = append(f.inboxes, address)
+	f.mu.Unlock()
+
+	return address, nil
+}
+
+// InboxClose implements Account.
+func (f *Fake) InboxClose(address *signing.PublicKey) error {
+	err := f.failure("InboxClose")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.inboxes = slices.DeleteFunc(f.inboxes, func(inbox *signing.PublicKey) bool {
+		return inbox.Matches(address)
+	})
+	f.mu.Unlock()
+
+	return nil
+}
+
+// InboxList implements Account.
+func (f *Fake) InboxList() ([]*signing.PublicKey, error) {
+	err := f.failure("InboxList")
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.inboxes), nil
+}
+
+// IdentityList implements Account.
+func (f *Fake) IdentityList() ([]*signing.PublicKey, error) {
+	err := f.failure("IdentityList")
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.identities), nil
+}
+
+// IdentityExecute implements Account. Every operation creates a new identity.
+func (f *Fake) IdentityExecute(operation *identity.Operation) error {
+	err := f.failure("IdentityExecute")
+	if err != nil {
+		return err
+	}
+
+	address, err := NewAddress()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.identities = append(f.identities, address)
+	f.mu.Unlock()
+
+	return nil
+}
+
+// KeychainSigningCreate implements Account.
+func (f *Fake) KeychainSigningCreate() (*signing.PublicKey, error) {
+	err := f.failure("KeychainSigningCreate")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAddress()
+}
+
+// ConnectionNegotiateOutOfBand implements Account.
+func (f *Fake) ConnectionNegotiateOutOfBand(asAddress *signing.PublicKey, expires time.Time) (*event.KeyPackage, error) {
+	err := f.failure("ConnectionNegotiateOutOfBand")
+	if err != nil {
+		return nil, err
+	}
+
+	if f.ConnectionNegotiateOutOfBandFunc != nil {
+		return f.ConnectionNegotiateOutOfBandFunc(asAddress, expires)
+	}
+
+	return &event.KeyPackage{}, nil
+}
+
+// ConnectionAccept implements Account.
+func (f *Fake) ConnectionAccept(asAddress *signing.PublicKey, welcome []byte) (*signing.PublicKey, error) {
+	return f.connect("ConnectionAccept", asAddress, welcome)
+}
+
+// ConnectionEstablish implements Account.
+func (f *Fake) ConnectionEstablish(asAddress *signing.PublicKey, keyPackage []byte) (*signing.PublicKey, error) {
+	return f.connect("ConnectionEstablish", asAddress, keyPackage)
+}
+
+func (f *Fake) connect(method string, asAddress *signing.PublicKey, payload []byte) (*signing.PublicKey, error) {
+	err := f.failure(method)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.connections = append(f.connections, Connection{InboxAddress: asAddress, GroupAddress: group, Payload: payload})
+	f.mu.Unlock()
+
+	return group, nil
+}
+
+// MessageSend implements Account.
+func (f *Fake) MessageSend(toAddress *signing.PublicKey, content *message.Content) error {
+	err := f.failure("MessageSend")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.sent = append(f.sent, SentMessage{To: toAddress, Content: content})
+	f.mu.Unlock()
+
+	return nil
+}
+
+// CredentialIssue implements Account.
+func (f *Fake) CredentialIssue(unsigned *credential.Credential) (*credential.VerifiableCredential, error) {
+	err := f.failure("CredentialIssue")
+	if err != nil {
+		return nil, err
+	}
+
+	if f.CredentialIssueFunc != nil {
+		return f.CredentialIssueFunc(unsigned)
+	}
+
+	return &credential.VerifiableCredential{}, nil
+}
+
+// PresentationIssue implements Account.
+func (f *Fake) PresentationIssue(unsigned *credential.Presentation) (*credential.VerifiablePresentation, error) {
+	err := f.failure("PresentationIssue")
+	if err != nil {
+		return nil, err
+	}
+
+	if f.PresentationIssueFunc != nil {
+		return f.PresentationIssueFunc(unsigned)
+	}
+
+	return &credential.VerifiablePresentation{}, nil
+}
+
+// ObjectUpload implements Account.
+func (f *Fake) ObjectUpload(obj *object.Object, persist bool) error {
+	err := f.failure("ObjectUpload")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.uploaded = append(f.uploaded, obj)
+	f.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package trust decides whether the issuer of a presented credential is
+// trusted for the credential's type and the request it answers.
+package trust
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// Self stands for the server's own address in issuer lists.
+const Self = "self"
+
+// set is a list of issuers, compared with Matches.
+type set []*signing.PublicKey
+
+func (s set) contains(issuer *signing.PublicKey) bool {
+	return slices.ContainsFunc(s, issuer.Matches)
+}
+
+// Policy holds the compiled trust configuration. Credentials are only
+// trusted from the issuers listed for their type or the definition they
+// answer, or from any issuer for the types listed as accepting any.
+type Policy struct {
+	issuers         map[string]set
+	anyIssuer       map[string]bool
+	allow           set
+	deny            set
+	allowSelfIssued bool
+	definitions     map[string]definitionPolicy
+}
+
+type definitionPolicy struct {
+	types   []string
+	issuers set
+	deny    set
+}
+
+// New compiles the trust configuration and the issuers of each request
+// definition. self is the server's address, which "self" in a list stands
+// for, and resolveType turns credential type aliases into types.
+func New(cfg config.Trust, definitions map[string]config.CredentialDefinition, self *signing.PublicKey, resolveType func(string) string) (*Policy, error) {
+	newSet := func(path string, issuers []string) (set, error) {
+		s := make(set, 0, len(issuers))
+		for i, issuer := range issuers {
+			if issuer == Self {
+				s = append(s, self)
+				continue
+			}
+
+			address, err := signing.FromAddress(issuer)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: invalid issuer address %q: %w", path, i, issuer, err)
+			}
+			s = append(s, address)
+		}
+		return s, nil
+	}
+
+	var err error
+
+	p := &Policy{
+		issuers:         make(map[string]set),
+		anyIssuer:       make(map[string]bool),
+		allowSelfIssued: cfg.AllowSelfIssued,
+		definitions:     make(map[string]definitionPolicy),
+	}
+
+	p.allow, err = newSet("credentials.trust.allow", cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	p.deny, err = newSet("credentials.trust.deny", cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	for credentialType, issuers := range cfg.Issuers {
+		p.issuers[resolveType(credentialType)], err = newSet("credentials.trust.issuers."+credentialType, issuers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, credentialType := range cfg.AnyIssuer {
+		p.anyIssuer[resolveType(credentialType)] = true
+	}
+
+	for name, definition := range definitions {
+		path := "credentials.definitions." + name
+
+		issuers, err := newSet(path+".issuers", definition.Issuers)
+		if err != nil {
+			return nil, err
+		}
+
+		deny, err := newSet(path+".deny_issuers", definition.DenyIssuers)
+		if err != nil {
+			return nil, err
+		}
+
+		p.definitions[name] = definitionPolicy{
+			types:   []string{resolveType(definition.Type)},
+			issuers: issuers,
+			deny:    deny,
+		}
+	}
+
+	return p, nil
+}
+
+// AnyIssuer returns the credential types accepted from any issuer, sorted.
+func (p *Policy) AnyIssuer() []string {
+	return slices.Sorted(maps.Keys(p.anyIssuer))
+}
+
+// Trusted reports whether any issuer is trusted for the named definition, so
+// that a credential can answer a request for it.
+func (p *Policy) Trusted(definition string) bool {
+	def, ok := p.definitions[definition]
+	if !ok {
+		return false
+	}
+
+	if len(def.issuers) > 0 || len(p.allow) > 0 {
+		return true
+	}
+
+	for _, credentialType := range def.types {
+		if len(p.issuers[credentialType]) > 0 || p.anyIssuer[credentialType] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Untrusted returns the definitions no credential can answer, as no issuer
+// is trusted for them, sorted.
+func (p *Policy) Untrusted() []string {
+	var names []string
+
+	for name := range p.definitions {
+		if !p.Trusted(name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// Check reports whether a credential of the given types from issuer,
+// presented by holder in response to a request for the named definition, is
+// trusted. Issuers are looked up for the type the definition requests, not
+// for the other types the credential claims. If it is not trusted, the
+// reason says why.
+func (p *Policy) Check(definition string, types []string, issuer, holder *signing.PublicKey) (verification.Reason, bool) {
+	def := p.definitions[definition]
+
+	if p.deny.contains(issuer) || def.deny.contains(issuer) {
+		return verification.Reason{
+			Code:    verification.CodeIssuerDenied,
+			Message: fmt.Sprintf("issuer %s is denied", issuer),
+		}, false
+	}
+
+	if issuer.Matches(holder) && !p.allowSelfIssued {
+		return verification.Reason{
+			Code:    verification.CodeSelfIssued,
+			Message: "credential is issued by the peer presenting it",
+		}, false
+	}
+
+	if def.issuers.contains(issuer) || p.allow.contains(issuer) {
+		return verification.Reason{}, true
+	}
+
+	// the definition's issuers replace those of the type it requests. Only
+	// the requested type counts, a credential can claim any other type too.
+	if len(def.issuers) == 0 {
+		for _, credentialType := range def.types {
+			if !slices.Contains(types, credentialType) {
+				continue
+			}
+			if p.issuers[credentialType].contains(issuer) || p.anyIssuer[credentialType] {
+				return verification.Reason{}, true
+			}
+		}
+	}
+
+	return verification.Reason{
+		Code:    verification.CodeIssuerUntrusted,
+		Message: fmt.Sprintf("issuer %s is not trusted for %v", issuer, def.types),
+	}, false
+}
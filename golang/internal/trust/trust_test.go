@@ -0,0 +1,121 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+func newAddress(t *testing.T) *signing.PublicKey {
+	t.Helper()
+
+	address, err := selfaccount.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// sameAddress returns a second key for the same address, so lookups cannot
+// rely on pointer equality
+func sameAddress(t *testing.T, address *signing.PublicKey) *signing.PublicKey {
+	t.Helper()
+
+	same, err := signing.FromAddress(address.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return same
+}
+
+func TestCheck(t *testing.T) {
+	self := newAddress(t)
+	emailIssuer := newAddress(t)
+	allowed := newAddress(t)
+	denied := newAddress(t)
+	stranger := newAddress(t)
+	holder := newAddress(t)
+
+	resolve := func(credentialType string) string {
+		if credentialType == "email" {
+			return "EmailCredential"
+		}
+		return credentialType
+	}
+
+	policy, err := New(config.Trust{
+		Issuers:   map[string][]string{"email": {emailIssuer.String()}},
+		AnyIssuer: []string{"PassportCredential"},
+		Allow:     []string{allowed.String()},
+		Deny:      []string{denied.String()},
+	}, map[string]config.CredentialDefinition{
+		"email":    {Type: "email"},
+		"custom":   {Type: "CustomerCredential", Issuers: []string{Self}},
+		"liveness": {Type: "LivenessCredential"},
+		"document": {Type: "PassportCredential"},
+	}, self, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		definition string
+		types      []string
+		issuer     *signing.PublicKey
+		want       verification.Code
+	}{
+		{"listed issuer", "email", []string{"EmailCredential"}, sameAddress(t, emailIssuer), ""},
+		{"unlisted issuer", "email", []string{"EmailCredential"}, stranger, verification.CodeIssuerUntrusted},
+		{"allowed issuer", "email", []string{"EmailCredential"}, sameAddress(t, allowed), ""},
+		{"denied issuer", "email", []string{"EmailCredential"}, sameAddress(t, denied), verification.CodeIssuerDenied},
+		{"self issued", "email", []string{"EmailCredential"}, sameAddress(t, holder), verification.CodeSelfIssued},
+		{"definition issuer", "custom", []string{"CustomerCredential"}, sameAddress(t, self), ""},
+		{"not the definition issuer", "custom", []string{"CustomerCredential"}, emailIssuer, verification.CodeIssuerUntrusted},
+		{"type without issuers", "liveness", []string{"LivenessCredential"}, stranger, verification.CodeIssuerUntrusted},
+		{"type with any issuer", "document", []string{"PassportCredential"}, stranger, ""},
+		{"claims a type with any issuer", "liveness", []string{"LivenessCredential", "PassportCredential"}, stranger, verification.CodeIssuerUntrusted},
+		{"claims a type with a listed issuer", "liveness", []string{"LivenessCredential", "EmailCredential"}, emailIssuer, verification.CodeIssuerUntrusted},
+		{"does not claim the requested type", "email", []string{"PassportCredential"}, emailIssuer, verification.CodeIssuerUntrusted},
+		{"unknown definition", "unknown", []string{"PassportCredential"}, stranger, verification.CodeIssuerUntrusted},
+	} {
+		reason, ok := policy.Check(tc.definition, tc.types, tc.issuer, holder)
+		if ok != (tc.want == "") || reason.Code != tc.want {
+			t.Errorf("%s: Check = %v, %+v, want %q", tc.name, ok, reason, tc.want)
+		}
+	}
+
+	if untrusted := policy.Untrusted(); len(untrusted) != 0 {
+		t.Errorf("Untrusted = %v, allow trusts issuers for every definition", untrusted)
+	}
+}
+
+func TestUntrusted(t *testing.T) {
+	policy, err := New(config.Trust{AnyIssuer: []string{"email"}}, map[string]config.CredentialDefinition{
+		"email":    {Type: "email"},
+		"custom":   {Type: "CustomerCredential", Issuers: []string{Self}},
+		"liveness": {Type: "liveness"},
+		"document": {Type: "passport"},
+	}, newAddress(t), func(credentialType string) string { return credentialType })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	untrusted := policy.Untrusted()
+	if len(untrusted) != 2 || untrusted[0] != "document" || untrusted[1] != "liveness" {
+		t.Errorf("Untrusted = %v, want [document liveness]", untrusted)
+	}
+
+	if types := policy.AnyIssuer(); len(types) != 1 || types[0] != "email" {
+		t.Errorf("AnyIssuer = %v", types)
+	}
+
+	for name, want := range map[string]bool{"email": true, "custom": true, "liveness": false, "document": false, "unknown": false} {
+		if got := policy.Trusted(name); got != want {
+			t.Errorf("Trusted(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package validity checks a credential's validity window. Each rule checks
+// one property and gives its own reason when it fails, and every rule
+// tolerates the same clock skew between the issuer and this server.
+package validity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// Window is the period a credential is valid for. A zero ValidUntil means
+// it does not expire.
+type Window struct {
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// Rule checks a window at now. It returns false and the reason when the
+// window fails the rule.
+type Rule func(w Window, now time.Time) (verification.Reason, bool)
+
+// NotBefore rejects credentials used before they become valid.
+func NotBefore(skew time.Duration) Rule {
+	return func(w Window, now time.Time) (verification.Reason, bool) {
+		if w.ValidFrom.After(now.Add(skew)) {
+			return verification.Reason{
+				Code:    verification.CodeNotYetValid,
+				Message: fmt.Sprintf("valid from %s", w.ValidFrom.Format(time.RFC3339)),
+			}, false
+		}
+		return verification.Reason{}, true
+	}
+}
+
+// NotExpired rejects credentials used after they expired.
+func NotExpired(skew time.Duration) Rule {
+	return func(w Window, now time.Time) (verification.Reason, bool) {
+		if !w.ValidUntil.IsZero() && w.ValidUntil.Before(now.Add(-skew)) {
+			return verification.Reason{
+				Code:    verification.CodeExpired,
+				Message: fmt.Sprintf("expired at %s", w.ValidUntil.Format(time.RFC3339)),
+			}, false
+		}
+		return verification.Reason{}, true
+	}
+}
+
+// MaxAge rejects credentials that became valid longer than age ago.
+func MaxAge(age, skew time.Duration) Rule {
+	return func(w Window, now time.Time) (verification.Reason, bool) {
+		if now.Sub(w.ValidFrom) > age+skew {
+			return verification.Reason{
+				Code:    verification.CodeTooOld,
+				Message: fmt.Sprintf("issued %s ago, at most %s is accepted", now.Sub(w.ValidFrom).Round(time.Second), age),
+			}, false
+		}
+		return verification.Reason{}, true
+	}
+}
+
+// Check applies every rule to w and returns the reasons of the ones that
+// fail.
+func Check(rules []Rule, w Window, now time.Time) []verification.Reason {
+	var reasons []verification.Reason
+
+	for _, rule := range rules {
+		reason, ok := rule(w, now)
+		if !ok {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	return reasons
+}
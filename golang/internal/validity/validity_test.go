@@ -0,0 +1,74 @@
+package validity
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+func TestRules(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	const skew = time.Minute
+
+	tests := []struct {
+		name string
+		rule Rule
+		w    Window
+		want verification.Code
+	}{
+		{"valid now", NotBefore(skew), Window{ValidFrom: now}, ""},
+		{"valid within the skew", NotBefore(skew), Window{ValidFrom: now.Add(skew)}, ""},
+		{"valid after the skew", NotBefore(skew), Window{ValidFrom: now.Add(skew + time.Second)}, verification.CodeNotYetValid},
+		{"valid later without skew", NotBefore(0), Window{ValidFrom: now.Add(time.Second)}, verification.CodeNotYetValid},
+
+		{"no expiry", NotExpired(skew), Window{ValidFrom: now.Add(-time.Hour)}, ""},
+		{"expires later", NotExpired(skew), Window{ValidUntil: now.Add(time.Second)}, ""},
+		{"expires now", NotExpired(skew), Window{ValidUntil: now}, ""},
+		{"expired within the skew", NotExpired(skew), Window{ValidUntil: now.Add(-skew)}, ""},
+		{"expired before the skew", NotExpired(skew), Window{ValidUntil: now.Add(-skew - time.Second)}, verification.CodeExpired},
+		{"expired without skew", NotExpired(0), Window{ValidUntil: now.Add(-time.Second)}, verification.CodeExpired},
+
+		{"younger than the max age", MaxAge(time.Hour, skew), Window{ValidFrom: now.Add(-time.Hour)}, ""},
+		{"older within the skew", MaxAge(time.Hour, skew), Window{ValidFrom: now.Add(-time.Hour - skew)}, ""},
+		{"older than the max age", MaxAge(time.Hour, skew), Window{ValidFrom: now.Add(-time.Hour - skew - time.Second)}, verification.CodeTooOld},
+		{"valid from the future", MaxAge(time.Hour, skew), Window{ValidFrom: now.Add(time.Hour)}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, ok := test.rule(test.w, now)
+			if ok != (test.want == "") || reason.Code != test.want {
+				t.Errorf("rule = %v, %t, want code %q", reason, ok, test.want)
+			}
+			if !ok && reason.Message == "" {
+				t.Error("the reason has no message")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rules := []Rule{NotBefore(time.Minute), NotExpired(time.Minute), MaxAge(time.Hour, time.Minute)}
+
+	if reasons := Check(rules, Window{ValidFrom: now.Add(-time.Minute), ValidUntil: now.Add(time.Hour)}, now); len(reasons) != 0 {
+		t.Errorf("valid window = %v, want no reasons", reasons)
+	}
+
+	// every failing rule gives its own reason, in order
+	reasons := Check(rules, Window{ValidFrom: now.Add(-2 * time.Hour), ValidUntil: now.Add(-time.Hour)}, now)
+
+	var codes []verification.Code
+	for _, reason := range reasons {
+		codes = append(codes, reason.Code)
+	}
+	if want := []verification.Code{verification.CodeExpired, verification.CodeTooOld}; !slices.Equal(codes, want) {
+		t.Errorf("expired old window = %v, want %v", codes, want)
+	}
+
+	if reasons := Check(nil, Window{ValidUntil: now.Add(-time.Hour)}, now); len(reasons) != 0 {
+		t.Errorf("no rules = %v, want no reasons", reasons)
+	}
+}
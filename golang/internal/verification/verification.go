@@ -0,0 +1,183 @@
+// Package verification describes the outcome of verifying the credentials a
+// peer presented in response to a request, in a form that can be stored,
+// logged and sent on to other systems.
+package verification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Decision is the overall outcome of a verification.
+type Decision string
+
+const (
+	// DecisionAccepted verifications have at least one credential that
+	// passed every check.
+	DecisionAccepted Decision = "accepted"
+	// DecisionRejected verifications were declined by the peer.
+	DecisionRejected Decision = "rejected"
+	// DecisionFailed verifications have no credential that passed every
+	// check.
+	DecisionFailed Decision = "failed"
+)
+
+// Code identifies why a presentation, credential or verification did not
+// pass.
+type Code string
+
+const (
+	// CodeDeclined responses were not accepted by the peer.
+	CodeDeclined Code = "declined"
+	// CodeNoCredentials responses carried no credential that passed.
+	CodeNoCredentials Code = "no_credentials"
+	// CodePresentationInvalid presentations failed signature validation.
+	CodePresentationInvalid Code = "presentation_invalid"
+	// CodeHolderMismatch presentations are held by someone other than the
+	// peer that sent them.
+	CodeHolderMismatch Code = "holder_mismatch"
+	// CodeCredentialInvalid credentials failed signature validation.
+	CodeCredentialInvalid Code = "credential_invalid"
+	// CodeNotYetValid credentials are used before they become valid.
+	CodeNotYetValid Code = "not_yet_valid"
+	// CodeExpired credentials are used after they expired.
+	CodeExpired Code = "expired"
+	// CodeTooOld credentials became valid longer ago than the request
+	// accepts.
+	CodeTooOld Code = "too_old"
+	// CodeUnmatched credentials do not satisfy the predicates of the
+	// request they were presented for.
+	CodeUnmatched Code = "unmatched"
+	// CodeClaimsUnreadable credentials have claims that could not be
+	// decoded.
+	CodeClaimsUnreadable Code = "claims_unreadable"
+	// CodeIssuerDenied credentials were issued by a denied issuer.
+	CodeIssuerDenied Code = "issuer_denied"
+	// CodeIssuerUntrusted credentials were issued by an issuer not allowed
+	// for their type.
+	CodeIssuerUntrusted Code = "issuer_untrusted"
+	// CodeSelfIssued credentials were issued by the peer presenting them.
+	CodeSelfIssued Code = "self_issued"
+)
+
+// Reason explains why something did not pass.
+type Reason struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r Reason) String() string {
+	return fmt.Sprintf("%s: %s", r.Code, r.Message)
+}
+
+// Credential is a credential from a presentation and the outcome of its
+// checks.
+type Credential struct {
+	Types      []string       `json:"types"`
+	Issuer     string         `json:"issuer"`
+	ValidFrom  time.Time      `json:"valid_from"`
+	ValidUntil time.Time      `json:"valid_until,omitzero"`
+	Claims     map[string]any `json:"claims,omitempty"`
+	Valid      bool           `json:"valid"`
+	Reasons    []Reason       `json:"reasons,omitempty"`
+}
+
+// Reject marks the credential as not valid.
+func (c *Credential) Reject(code Code, format string, args ...any) {
+	c.Valid = false
+	c.Reasons = append(c.Reasons, Reason{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Presentation is a presentation from a response and the outcome of its
+// checks.
+type Presentation struct {
+	Types       []string     `json:"types"`
+	Holder      string       `json:"holder"`
+	Valid       bool         `json:"valid"`
+	Reasons     []Reason     `json:"reasons,omitempty"`
+	Credentials []Credential `json:"credentials,omitempty"`
+}
+
+// Reject marks the presentation as not valid.
+func (p *Presentation) Reject(code Code, format string, args ...any) {
+	p.Valid = false
+	p.Reasons = append(p.Reasons, Reason{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Result is the outcome of verifying a response to a credential request.
+type Result struct {
+	PeerAddress string `json:"peer_address"`
+	// RequestID is the ID of the request the response answers.
+	RequestID string `json:"request_id"`
+	// Definition is the name of the credential definition requested.
+	Definition string `json:"definition"`
+	// Status is the response status sent by the peer.
+	Status        string         `json:"status"`
+	Presentations []Presentation `json:"presentations"`
+	Decision      Decision       `json:"decision"`
+	Reasons       []Reason       `json:"reasons,omitempty"`
+	VerifiedAt    time.Time      `json:"verified_at"`
+}
+
+// Decide sets the decision from the response status and the outcome of the
+// presentations. A declined response is rejected; otherwise the result is
+// accepted if any credential in a valid presentation is valid.
+func (r *Result) Decide(accepted bool) {
+	r.Reasons = nil
+
+	if !accepted {
+		r.Decision = DecisionRejected
+		r.Reasons = append(r.Reasons, Reason{Code: CodeDeclined, Message: "the peer declined with status " + r.Status})
+		return
+	}
+
+	if len(r.Accepted()) == 0 {
+		r.Decision = DecisionFailed
+		r.Reasons = append(r.Reasons, Reason{Code: CodeNoCredentials, Message: "no presented credential passed verification"})
+		for _, p := range r.Presentations {
+			r.Reasons = append(r.Reasons, p.Reasons...)
+			for _, c := range p.Credentials {
+				r.Reasons = append(r.Reasons, c.Reasons...)
+			}
+		}
+		return
+	}
+
+	r.Decision = DecisionAccepted
+}
+
+// Accepted returns the valid credentials from valid presentations.
+func (r *Result) Accepted() []Credential {
+	var accepted []Credential
+
+	for _, p := range r.Presentations {
+		if !p.Valid {
+			continue
+		}
+		for _, c := range p.Credentials {
+			if c.Valid {
+				accepted = append(accepted, c)
+			}
+		}
+	}
+
+	return accepted
+}
+
+// String summarises the result, for logs.
+func (r *Result) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s for request %s from %s", r.Definition, r.Decision, r.RequestID, r.PeerAddress)
+
+	if len(r.Reasons) > 0 {
+		reasons := make([]string, 0, len(r.Reasons))
+		for _, reason := range r.Reasons {
+			reasons = append(reasons, reason.String())
+		}
+		fmt.Fprintf(&b, " (%s)", strings.Join(reasons, "; "))
+	}
+
+	return b.String()
+}
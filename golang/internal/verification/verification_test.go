@@ -0,0 +1,135 @@
+package verification
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func codes(reasons []Reason) []Code {
+	var codes []Code
+	for _, reason := range reasons {
+		codes = append(codes, reason.Code)
+	}
+	return codes
+}
+
+func TestReject(t *testing.T) {
+	c := Credential{Valid: true}
+	c.Reject(CodeExpired, "expired at %s", "noon")
+	c.Reject(CodeTooOld, "too old")
+
+	if c.Valid {
+		t.Error("rejected credential is valid")
+	}
+	if want := []Reason{{CodeExpired, "expired at noon"}, {CodeTooOld, "too old"}}; !slices.Equal(c.Reasons, want) {
+		t.Errorf("credential reasons = %v, want %v", c.Reasons, want)
+	}
+
+	p := Presentation{Valid: true}
+	p.Reject(CodeHolderMismatch, "held by %s", "someone else")
+
+	if p.Valid || !slices.Equal(p.Reasons, []Reason{{CodeHolderMismatch, "held by someone else"}}) {
+		t.Errorf("rejected presentation = %+v", p)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	valid := Credential{Valid: true}
+	expired := Credential{Reasons: []Reason{{CodeExpired, "expired"}}}
+	untrusted := Credential{Reasons: []Reason{{CodeIssuerUntrusted, "untrusted"}}}
+	forged := []Reason{{CodePresentationInvalid, "bad signature"}}
+
+	tests := []struct {
+		name          string
+		accepted      bool
+		presentations []Presentation
+		want          Decision
+		reasons       []Code
+		credentials   int
+	}{
+		{
+			name:    "declined",
+			want:    DecisionRejected,
+			reasons: []Code{CodeDeclined},
+		},
+		{
+			name:     "nothing presented",
+			accepted: true,
+			want:     DecisionFailed,
+			reasons:  []Code{CodeNoCredentials},
+		},
+		{
+			name:          "valid credential",
+			accepted:      true,
+			presentations: []Presentation{{Valid: true, Credentials: []Credential{valid}}},
+			want:          DecisionAccepted,
+			credentials:   1,
+		},
+		{
+			name:          "valid credential in an invalid presentation",
+			accepted:      true,
+			presentations: []Presentation{{Reasons: forged, Credentials: []Credential{valid}}},
+			want:          DecisionFailed,
+			reasons:       []Code{CodeNoCredentials, CodePresentationInvalid},
+		},
+		{
+			name:          "invalid credentials in a valid presentation",
+			accepted:      true,
+			presentations: []Presentation{{Valid: true, Credentials: []Credential{expired, untrusted}}},
+			want:          DecisionFailed,
+			reasons:       []Code{CodeNoCredentials, CodeExpired, CodeIssuerUntrusted},
+		},
+		{
+			name:     "one valid credential among invalid ones",
+			accepted: true,
+			presentations: []Presentation{
+				{Reasons: forged, Credentials: []Credential{valid}},
+				{Valid: true, Credentials: []Credential{expired, valid}},
+			},
+			want:        DecisionAccepted,
+			credentials: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Result{Status: "rejected", Presentations: test.presentations}
+			r.Decide(test.accepted)
+
+			if r.Decision != test.want {
+				t.Errorf("decision = %s, want %s", r.Decision, test.want)
+			}
+			if got := codes(r.Reasons); !slices.Equal(got, test.reasons) {
+				t.Errorf("reasons = %v, want %v", got, test.reasons)
+			}
+			if n := len(r.Accepted()); n != test.credentials {
+				t.Errorf("accepted %d credentials, want %d", n, test.credentials)
+			}
+		})
+	}
+}
+
+func TestDecideAgain(t *testing.T) {
+	r := &Result{Presentations: []Presentation{{Valid: true, Credentials: []Credential{{Valid: true}}}}}
+
+	r.Decide(false)
+	r.Decide(true)
+
+	// reasons of an earlier decision are dropped
+	if r.Decision != DecisionAccepted || len(r.Reasons) != 0 {
+		t.Errorf("result decided again = %s %v", r.Decision, r.Reasons)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	r := &Result{PeerAddress: "peer", RequestID: "42", Definition: "email", Status: "rejected"}
+	r.Decide(false)
+
+	s := r.String()
+	for _, want := range []string{"email rejected for request 42 from peer", "declined: the peer declined with status rejected"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String() = %q, want it to contain %q", s, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS deliveries (
+	id              TEXT PRIMARY KEY,
+	endpoint        TEXT NOT NULL,
+	event_id        TEXT NOT NULL,
+	event_type      TEXT NOT NULL,
+	payload         BLOB NOT NULL,
+	state           TEXT NOT NULL,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT NOT NULL DEFAULT '',
+	next_attempt_at INTEGER NOT NULL,
+	created_at      INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_due ON deliveries (state, next_attempt_at);
+`
+
+// State is the position of a delivery in the queue.
+type State string
+
+const (
+	// StatePending deliveries are waiting for their next attempt.
+	StatePending State = "pending"
+	// StateDead deliveries ran out of attempts and wait to be replayed.
+	StateDead State = "dead"
+)
+
+// Delivery is an event to be delivered to one endpoint.
+type Delivery struct {
+	ID            string    `json:"id"`
+	Endpoint      string    `json:"endpoint"`
+	EventID       string    `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	Payload       []byte    `json:"-"`
+	State         State     `json:"state"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Queue is a durable queue of deliveries, backed by a SQLite database.
+// Deliveries stay in it until they succeed; the ones that run out of
+// attempts are kept as dead letters.
+type Queue struct {
+	db *sql.DB
+}
+
+// OpenQueue opens, and if needed creates, the SQLite queue database at path.
+func OpenQueue(path string) (*Queue, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("webhook: failed to open %s: %w", path, err)
+	}
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("webhook: failed to create schema: %w", err)
+	}
+
+	return &Queue{db: db}, nil
+}
+
+// Close closes the database.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Enqueue adds a delivery, due immediately.
+func (q *Queue) Enqueue(ctx context.Context, d Delivery) error {
+	now := time.Now()
+
+	_, err := q.db.ExecContext(ctx, `
+		INSERT INTO deliveries (id, endpoint, event_id, event_type, payload, state, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID,
+		d.Endpoint,
+		d.EventID,
+		d.EventType,
+		d.Payload,
+		StatePending,
+		now.UnixNano(),
+		now.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("webhook: failed to enqueue %s: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+// Due returns up to limit pending deliveries whose next attempt is due at
+// now, oldest first.
+func (q *Queue) Due(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return q.list(ctx, "WHERE state = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?", StatePending, now.UnixNano(), limit)
+}
+
+// Dead returns the dead letters, oldest first.
+func (q *Queue) Dead(ctx context.Context) ([]Delivery, error) {
+	return q.list(ctx, "WHERE state = ? ORDER BY created_at", StateDead)
+}
+
+// Delivered removes a delivery that succeeded.
+func (q *Queue) Delivered(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, `DELETE FROM deliveries WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("webhook: failed to remove %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// Failed records a failed attempt. The delivery is retried at next, or
+// becomes a dead letter if next is zero.
+func (q *Queue) Failed(ctx context.Context, id string, reason string, next time.Time) error {
+	// the zero time has no UnixNano, dead letters are never due
+	state := StatePending
+	nextAttemptAt := int64(0)
+	if next.IsZero() {
+		state = StateDead
+	} else {
+		nextAttemptAt = next.UnixNano()
+	}
+
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE deliveries
+		SET state = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE id = ?`,
+		state,
+		reason,
+		nextAttemptAt,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("webhook: failed to record attempt on %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// Replay makes dead letters due again with a fresh set of attempts. With no
+// ids every dead letter is replayed. It returns how many were replayed.
+func (q *Queue) Replay(ctx context.Context, ids ...string) (int, error) {
+	query := `UPDATE deliveries SET state = ?, attempts = 0, next_attempt_at = ? WHERE state = ?`
+	args := []any{StatePending, time.Now().UnixNano(), StateDead}
+
+	if len(ids) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("webhook: failed to replay: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("webhook: failed to replay: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (q *Queue) list(ctx context.Context, where string, args ...any) ([]Delivery, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT id, endpoint, event_id, event_type, payload, state, attempts, last_error, next_attempt_at, created_at
+		FROM deliveries `+where,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: failed to list deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		var nextAttemptAt, createdAt int64
+
+		err = rows.Scan(&d.ID, &d.Endpoint, &d.EventID, &d.EventType, &d.Payload, &d.State, &d.Attempts, &d.LastError, &nextAttemptAt, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("webhook: failed to list deliveries: %w", err)
+		}
+
+		if nextAttemptAt != 0 {
+			d.NextAttemptAt = time.Unix(0, nextAttemptAt)
+		}
+		d.CreatedAt = time.Unix(0, createdAt)
+		deliveries = append(deliveries, d)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("webhook: failed to list deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
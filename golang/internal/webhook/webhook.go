@@ -0,0 +1,313 @@
+// Package webhook delivers server events to HTTP endpoints. Every event is
+// queued once per endpoint that subscribes to it and POSTed as JSON, signed
+// with the endpoint's secret. Failed deliveries are retried with exponential
+// backoff, and kept as dead letters once they run out of attempts.
+//
+// Receivers check the X-Self-Signature header with Verify. It has the form
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">".
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Self-Event"
+	HeaderDelivery  = "X-Self-Delivery"
+	HeaderSignature = "X-Self-Signature"
+)
+
+var (
+	// ErrInvalidSignature is returned by Verify for a missing, malformed or
+	// wrong signature.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrStaleSignature is returned by Verify for a signature older than the
+	// tolerance.
+	ErrStaleSignature = errors.New("webhook: signature timestamp outside tolerance")
+)
+
+// Deliveries counts delivery attempts by outcome, for the server to publish
+var Deliveries = new(expvar.Map)
+
+// batchSize is how many due deliveries are attempted per pass.
+const batchSize = 50
+
+type endpoint struct {
+	url    string
+	secret string
+	events []events.Type
+}
+
+func (e endpoint) wants(t events.Type) bool {
+	return len(e.events) == 0 || slices.Contains(e.events, t)
+}
+
+// Dispatcher queues events for the configured endpoints and delivers them.
+type Dispatcher struct {
+	queue          *Queue
+	client         *http.Client
+	endpoints      []endpoint
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	wake           chan struct{}
+}
+
+// New returns a dispatcher for the endpoints in cfg, queueing deliveries in
+// queue. It fails if an endpoint subscribes to an unknown event type.
+func New(cfg config.Webhooks, queue *Queue) (*Dispatcher, error) {
+	d := &Dispatcher{
+		queue:          queue,
+		client:         &http.Client{Timeout: cfg.Timeout},
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		wake:           make(chan struct{}, 1),
+	}
+
+	for i, e := range cfg.Endpoints {
+		ep := endpoint{url: e.URL, secret: e.Secret}
+
+		for _, name := range e.Events {
+			t := events.Type(strings.TrimSpace(name))
+			if !slices.Contains(events.Types, t) {
+				return nil, fmt.Errorf("webhook: endpoint %d subscribes to unknown event %q", i, name)
+			}
+			ep.events = append(ep.events, t)
+		}
+
+		d.endpoints = append(d.endpoints, ep)
+	}
+
+	return d, nil
+}
+
+// Publish queues event for every endpoint subscribed to its type. It is
+// meant to be subscribed to an events.Bus.
+func (d *Dispatcher) Publish(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Webhook: failed to encode %s event %s: %v", event.Type, event.ID, err)
+		return
+	}
+
+	queued := false
+
+	for _, e := range d.endpoints {
+		if !e.wants(event.Type) {
+			continue
+		}
+
+		err = d.queue.Enqueue(context.Background(), Delivery{
+			ID:        newID(),
+			Endpoint:  e.url,
+			EventID:   event.ID,
+			EventType: string(event.Type),
+			Payload:   payload,
+		})
+		if err != nil {
+			log.Printf("Webhook: failed to queue %s event %s for %s: %v", event.Type, event.ID, e.url, err)
+			continue
+		}
+
+		queued = true
+	}
+
+	if queued {
+		d.Wake()
+	}
+}
+
+// Wake makes Run check for due deliveries without waiting for its interval.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due deliveries every interval, or sooner when woken, until
+// ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for {
+		due, err := d.queue.Due(ctx, time.Now(), batchSize)
+		if err != nil {
+			log.Printf("Webhook: %v", err)
+			return
+		}
+
+		for _, delivery := range due {
+			d.attempt(ctx, delivery)
+		}
+
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	i := slices.IndexFunc(d.endpoints, func(e endpoint) bool { return e.url == delivery.Endpoint })
+	if i < 0 {
+		d.failed(ctx, delivery, errors.New("endpoint is no longer configured"), true)
+		return
+	}
+
+	err := d.send(ctx, d.endpoints[i], delivery)
+	if err != nil {
+		d.failed(ctx, delivery, err, delivery.Attempts+1 >= d.maxAttempts)
+		return
+	}
+
+	Deliveries.Add("succeeded", 1)
+
+	err = d.queue.Delivered(ctx, delivery.ID)
+	if err != nil {
+		log.Printf("Webhook: %v", err)
+	}
+}
+
+func (d *Dispatcher) failed(ctx context.Context, delivery Delivery, reason error, dead bool) {
+	var next time.Time
+	if !dead {
+		next = time.Now().Add(Backoff(delivery.Attempts+1, d.initialBackoff, d.maxBackoff))
+	}
+
+	if dead {
+		Deliveries.Add("dead", 1)
+		log.Printf("Webhook: giving up on delivery %s of %s event %s to %s: %v", delivery.ID, delivery.EventType, delivery.EventID, delivery.Endpoint, reason)
+	} else {
+		Deliveries.Add("failed", 1)
+		log.Printf("Webhook: delivery %s to %s failed, retrying at %s: %v", delivery.ID, delivery.Endpoint, next.Format(time.RFC3339), reason)
+	}
+
+	err := d.queue.Failed(ctx, delivery.ID, reason.Error(), next)
+	if err != nil {
+		log.Printf("Webhook: %v", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, e endpoint, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderSignature, Sign(e.secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Backoff returns how long to wait after the given number of failed
+// attempts: initial, doubled for every attempt after the first, capped at
+// max.
+func Backoff(attempts int, initial, max time.Duration) time.Duration {
+	backoff := initial
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, max)
+}
+
+// Sign returns the X-Self-Signature header value for body, sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(secret, timestamp, body)
+}
+
+// Verify checks an X-Self-Signature header value against body. Signatures
+// made more than tolerance away from now are rejected, to limit replays.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp, sig string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	if now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return ErrStaleSignature
+	}
+
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
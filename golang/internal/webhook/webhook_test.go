@@ -0,0 +1,314 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+)
+
+const testSecret = "secret"
+
+// receiver is a webhook endpoint that checks signatures and answers with
+// the next status in its list, then 200
+type receiver struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	statuses []int
+	received []events.Event
+	ids      []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Error(err)
+		return
+	}
+
+	err = Verify(testSecret, r.Header.Get(HeaderSignature), body, time.Minute, time.Now())
+	if err != nil {
+		rc.t.Errorf("delivery %s: %v", r.Header.Get(HeaderDelivery), err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var event events.Event
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		rc.t.Error(err)
+	}
+
+	if r.Header.Get(HeaderEvent) != string(event.Type) || r.Header.Get("Content-Type") != "application/json" {
+		rc.t.Errorf("headers = %v", r.Header)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.ids = append(rc.ids, r.Header.Get(HeaderDelivery))
+
+	if len(rc.statuses) > 0 {
+		status := rc.statuses[0]
+		rc.statuses = rc.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	rc.received = append(rc.received, event)
+}
+
+func (rc *receiver) events() []events.Event {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.received
+}
+
+func (rc *receiver) attempts() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.ids)
+}
+
+// newTestDispatcher returns a dispatcher delivering every event to rc
+func newTestDispatcher(t *testing.T, rc *receiver, maxAttempts int) (*Dispatcher, *Queue) {
+	t.Helper()
+
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	queue, err := OpenQueue(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Close() })
+
+	d, err := New(config.Webhooks{
+		Endpoints:      []config.WebhookEndpoint{{URL: srv.URL, Secret: testSecret}},
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+		Timeout:        time.Second,
+	}, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d, queue
+}
+
+func testEvent(id string) events.Event {
+	return events.Event{
+		ID:   id,
+		Type: events.ConnectionEstablished,
+		Time: time.Now(),
+		Data: events.ConnectionData{PeerAddress: "peer"},
+	}
+}
+
+// deliverAll attempts deliveries until none are pending
+func deliverAll(t *testing.T, d *Dispatcher, q *Queue) {
+	t.Helper()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		d.deliverDue(ctx)
+
+		pending, err := q.Due(ctx, time.Now().Add(time.Hour), batchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("deliveries are still pending")
+}
+
+func TestDeliver(t *testing.T) {
+	rc := &receiver{t: t}
+	d, q := newTestDispatcher(t, rc, 3)
+
+	d.Publish(testEvent("event"))
+	deliverAll(t, d, q)
+
+	received := rc.events()
+	if len(received) != 1 || received[0].ID != "event" || received[0].Type != events.ConnectionEstablished {
+		t.Errorf("received %+v", received)
+	}
+
+	dead, err := q.Dead(context.Background())
+	if err != nil || len(dead) != 0 {
+		t.Errorf("dead letters = %v, %v", dead, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}}
+	d, q := newTestDispatcher(t, rc, 3)
+
+	d.Publish(testEvent("event"))
+
+	// the first attempt fails and is retried after the backoff
+	d.deliverDue(context.Background())
+
+	pending, err := q.Due(context.Background(), time.Now().Add(time.Hour), batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" || !pending[0].NextAttemptAt.After(pending[0].CreatedAt) {
+		t.Fatalf("pending after a failure = %+v", pending)
+	}
+
+	deliverAll(t, d, q)
+
+	if n := rc.attempts(); n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+	if received := rc.events(); len(received) != 1 {
+		t.Errorf("received %d events, want 1", len(received))
+	}
+
+	// every attempt carries the same delivery id
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.ids[0] != rc.ids[1] || rc.ids[1] != rc.ids[2] {
+		t.Errorf("delivery ids = %v", rc.ids)
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	rc := &receiver{t: t, statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError}}
+	d, q := newTestDispatcher(t, rc, 2)
+	ctx := context.Background()
+
+	d.Publish(testEvent("event"))
+	deliverAll(t, d, q)
+
+	dead, err := q.Dead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].EventID != "event" || !dead[0].NextAttemptAt.IsZero() {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+	if received := rc.events(); len(received) != 0 {
+		t.Fatalf("received %d events from failed deliveries", len(received))
+	}
+
+	// dead letters are never due
+	d.deliverDue(ctx)
+	if n := rc.attempts(); n != 2 {
+		t.Errorf("%d attempts after dead-lettering, want 2", n)
+	}
+
+	n, err := q.Replay(ctx, "unknown")
+	if err != nil || n != 0 {
+		t.Errorf("Replay(unknown) = %d, %v", n, err)
+	}
+
+	n, err = q.Replay(ctx, dead[0].ID)
+	if err != nil || n != 1 {
+		t.Fatalf("Replay = %d, %v", n, err)
+	}
+
+	deliverAll(t, d, q)
+
+	received := rc.events()
+	if len(received) != 1 || received[0].ID != "event" {
+		t.Errorf("received %+v after replaying", received)
+	}
+
+	dead, err = q.Dead(ctx)
+	if err != nil || len(dead) != 0 {
+		t.Errorf("dead letters after replaying = %v, %v", dead, err)
+	}
+}
+
+func TestUnsubscribedEndpoint(t *testing.T) {
+	queue, err := OpenQueue(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+
+	d, err := New(config.Webhooks{
+		Endpoints:   []config.WebhookEndpoint{{URL: "http://127.0.0.1:1", Events: []string{string(events.AgreementSigned)}}},
+		MaxAttempts: 1,
+	}, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Publish(testEvent("event"))
+
+	due, err := queue.Due(context.Background(), time.Now(), batchSize)
+	if err != nil || len(due) != 0 {
+		t.Errorf("queued %v, %v for an endpoint not subscribed to the event", due, err)
+	}
+
+	_, err = New(config.Webhooks{Endpoints: []config.WebhookEndpoint{{URL: "http://127.0.0.1:1", Events: []string{"unknown"}}}}, queue)
+	if err == nil {
+		t.Error("New accepted an unknown event type")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{10, 30 * time.Second},
+	} {
+		if got := Backoff(tc.attempts, time.Second, 30*time.Second); got != tc.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"event"}`)
+	now := time.Now()
+	header := Sign(testSecret, now, body)
+
+	if err := Verify(testSecret, header, body, time.Minute, now); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"wrong secret", "other", header, body, now, ErrInvalidSignature},
+		{"changed body", testSecret, header, []byte(`{"id":"other"}`), now, ErrInvalidSignature},
+		{"no signature", testSecret, "", body, now, ErrInvalidSignature},
+		{"no timestamp", testSecret, "v1=abc", body, now, ErrInvalidSignature},
+		{"stale", testSecret, header, body, now.Add(time.Hour), ErrStaleSignature},
+	} {
+		if err := Verify(tc.secret, tc.header, tc.body, time.Minute, tc.now); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Verify = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+)
+
+// findInvitation returns the ID of the invitation issued for one of our
+// inboxes, or handed out with a key package, and an empty ID if there is none
+func (s *server) findInvitation(inboxAddress *signing.PublicKey, keyPackage []byte) string {
+	inv, err := s.invitations.ByInbox(inboxAddress.String())
+	if errors.Is(err, invitation.ErrNotFound) && len(keyPackage) > 0 {
+		inv, err = s.invitations.ByKeyPackage(keyPackage)
+	}
+	if errors.Is(err, invitation.ErrNotFound) {
+		log.Printf("No invitation found for inbox %s", inboxAddress)
+		return ""
+	}
+
+	return inv.ID
+}
+
+// invitationScanned records that a peer has used an invitation
+func (s *server) invitationScanned(invitationID string, peerAddress *signing.PublicKey) {
+	inv, err := s.invitations.Scanned(invitationID, peerAddress.String())
+	if errors.Is(err, invitation.ErrNotFound) {
+		return
+	}
+
+	log.Printf("Invitation %s from %s scanned by %s (metadata: %v)", inv.ID, inv.Creator, peerAddress, inv.Metadata)
+}
+
+// invitationConnected records the connection established through an
+// invitation
+func (s *server) invitationConnected(invitationID string, peerAddress, connectionAddress *signing.PublicKey) {
+	inv, err := s.invitations.Connected(invitationID, peerAddress.String(), connectionAddress.String())
+	if errors.Is(err, invitation.ErrNotFound) {
+		return
+	}
+
+	log.Printf("Invitation %s from %s connected to %s", inv.ID, inv.Creator, peerAddress)
+}
+
+// invitationFailed records a failed connection attempt against an invitation
+func (s *server) invitationFailed(invitationID string, peerAddress *signing.PublicKey, reason string) {
+	inv, err := s.invitations.Failed(invitationID, peerAddress.String(), reason)
+	if errors.Is(err, invitation.ErrNotFound) {
+		return
+	}
+
+	log.Printf("Invitation %s from %s failed to connect %s: %s", inv.ID, inv.Creator, peerAddress, reason)
+}
+
+// expireInvitations periodically expires invitations that were never used
+func (s *server) expireInvitations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, inv := range s.invitations.Expire(now) {
+			log.Printf("Invitation %s from %s expired", inv.ID, inv.Creator)
+		}
+	}
+}
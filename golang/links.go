@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-sdk-examples/golang/internal/deeplink"
+)
+
+// link returns an app link under baseURL carrying the same anonymous message
+// as the connection QR code. Without a base URL no link is built, and the
+// link is empty.
+func (r *connectionRequest) link(baseURL string) (string, error) {
+	if baseURL == "" {
+		return "", nil
+	}
+	return encodeConnectionLink(baseURL, r.message)
+}
+
+// encodeConnectionLink encodes an anonymous message as an app link under
+// baseURL
+func encodeConnectionLink(baseURL string, msg *event.AnonymousMessage) (string, error) {
+	encoded, err := msg.Encode()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode anonymous message: %w", err)
+	}
+
+	return deeplink.Encode(baseURL, encoded)
+}
+
+// decodeConnectionLink turns an app link back into the anonymous message it
+// was created from
+func decodeConnectionLink(link string) (*event.AnonymousMessage, error) {
+	encoded, err := deeplink.Decode(link)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := event.DecodeAnonymousMessage(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode anonymous message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// printConnectionLink decodes an app link and prints the discovery request
+// it carries
+func printConnectionLink(link string) error {
+	if link == "" {
+		return fmt.Errorf("no link given")
+	}
+
+	msg, err := decodeConnectionLink(link)
+	if err != nil {
+		return err
+	}
+
+	request, err := message.DecodeDiscoveryRequest(msg.Content())
+	if err != nil {
+		return fmt.Errorf("link does not carry a discovery request: %w", err)
+	}
+
+	log.Printf("Discovery request: %s", hex.EncodeToString(msg.Content().ID()))
+	log.Printf("Expires: %s", request.Expires().Format("2006-01-02 15:04:05 MST"))
+
+	return nil
+}
@@ -2,68 +2,280 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-pdf/fpdf"
 	"github.com/joinself/self-go-sdk/account"
 	"github.com/joinself/self-go-sdk/credential"
-	"github.com/joinself/self-go-sdk/credential/predicate"
 	"github.com/joinself/self-go-sdk/event"
 	"github.com/joinself/self-go-sdk/identity"
 	"github.com/joinself/self-go-sdk/keypair/signing"
 	"github.com/joinself/self-go-sdk/message"
 	"github.com/joinself/self-go-sdk/object"
+	"github.com/joinself/self-sdk-examples/golang/internal/command"
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/contacts"
+	"github.com/joinself/self-sdk-examples/golang/internal/conversation"
+	"github.com/joinself/self-sdk-examples/golang/internal/definition"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/oidc"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+	"github.com/joinself/self-sdk-examples/golang/internal/trust"
+	"github.com/joinself/self-sdk-examples/golang/internal/webhook"
 )
 
-var selfAccount *account.Account
-var inboxAddress *signing.PublicKey
+// server holds everything the connection server's flows and endpoints share
+type server struct {
+	account       selfaccount.Account
+	config        *config.Config
+	env           environment
+	address       *signing.PublicKey
+	invitations   *invitation.Registry
+	inboxes       *inboxManager
+	contacts      contacts.Store
+	requests      *pending.Registry
+	definitions   map[string]*definition.Definition
+	trust         *trust.Policy
+	commands      *command.Router
+	conversations *conversation.Manager
+	events        *events.Bus
+	auths         *authTracker
+	sessions      *sessionStore
+	oidc          *oidc.Provider
+	chatLimiter   *ratelimit.Limiter
+	chatNotices   *ratelimit.Limiter
+	httpLimiter   *ratelimit.Limiter
+}
 
 func main() {
-	log.Println("Self SDK Connection Server")
-	log.Println("=============================")
+	configPath := flag.String("config", os.Getenv("SELF_CONFIG"), "path to a YAML configuration file (env SELF_CONFIG)")
+	config.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [decode-link <link> | webhooks list | webhooks replay [delivery...]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, flag.CommandLine)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	env, err := parseEnvironment(cfg.Environment)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "":
+		log.Println("Self SDK Connection Server")
+		log.Println("=============================")
 
-	startSelf()
+		err = startSelf(cfg, env)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "decode-link":
+		err = printConnectionLink(flag.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to decode link: %v", err)
+		}
+	case "webhooks":
+		err = runWebhooksCommand(cfg, flag.Args()[1:])
+		if err != nil {
+			log.Fatalf("Failed to run webhooks command: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 }
 
-func startSelf() {
-	// clear state
-	err := os.RemoveAll("./self-store")
+// newAccountConfig returns the account configuration of the server's
+// self-store. Callbacks are left for the caller to set.
+func newAccountConfig(cfg *config.Config, env environment, storagePath string, storageKey []byte) *account.Config {
+	return &account.Config{
+		StoragePath: storagePath,
+		StorageKey:  storageKey,
+		Environment: env.target(),
+		LogLevel:    accountLogLevel(cfg.LogLevel),
+	}
+}
+
+func accountLogLevel(level string) account.LogLevel {
+	switch level {
+	case "error":
+		return account.LogError
+	case "info":
+		return account.LogInfo
+	case "debug":
+		return account.LogDebug
+	case "trace":
+		return account.LogTrace
+	default:
+		return account.LogWarn
+	}
+}
+
+// startSelf runs the server until it is stopped by a signal or fails. Errors
+// are returned rather than fatal, so deferred cleanup always runs.
+func startSelf(cfg *config.Config, env environment) error {
+	ephemeral := cfg.Store.Ephemeral
+
+	s := &server{
+		config: cfg,
+		env:    env,
+	}
+
+	storagePath, storageKey, cleanupStore, err := prepareStore(cfg.Store, env)
+	if err != nil {
+		return err
+	}
+	defer cleanupStore()
+
+	if ephemeral {
+		log.Println("Running with an ephemeral self-store:", storagePath)
+	} else {
+		log.Println("Using persistent self-store:", storagePath)
+	}
+
+	// remember who we are connected to
+	if ephemeral {
+		s.contacts = contacts.NewMemoryStore()
+	} else {
+		s.contacts, err = contacts.OpenSQLite(cfg.Contacts.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open contacts database: %w", err)
+		}
+	}
+	defer s.contacts.Close()
+
+	// publish the outcomes of flows, and deliver them to webhook endpoints
+	s.events = events.NewBus()
+
+	if len(cfg.Webhooks.Endpoints) > 0 {
+		queuePath := cfg.Webhooks.QueuePath
+		if ephemeral {
+			queuePath = filepath.Join(storagePath, "webhooks.db")
+		}
+
+		queue, err := webhook.OpenQueue(queuePath)
+		if err != nil {
+			return fmt.Errorf("failed to open webhook queue: %w", err)
+		}
+		defer queue.Close()
+
+		dispatcher, err := webhook.New(cfg.Webhooks, queue)
+		if err != nil {
+			return err
+		}
+
+		s.events.Subscribe(dispatcher.Publish)
+		go dispatcher.Run(context.Background(), time.Second)
+
+		log.Printf("Delivering events to %d webhook endpoints", len(cfg.Webhooks.Endpoints))
+	}
+
+	// track the invitations handed out, finished ones are kept for a day
+	s.invitations = invitation.NewRegistry(24 * time.Hour)
+	go s.expireInvitations(time.Minute)
+
+	// track the requests sent to peers, answered ones are kept for a day to
+	// recognise duplicate and late responses
+	s.requests = pending.NewRegistry(24 * time.Hour)
+	go s.expireRequests(time.Minute)
+
+	// compile the credentials that can be requested
+	s.definitions, err = definition.Compile(cfg.Credentials.Definitions)
 	if err != nil {
-		log.Fatalf("Failed to clear self-store: %v\n", err)
+		return fmt.Errorf("failed to compile credential definitions: %w", err)
 	}
 
-	storageKey, err := generateRandomBytes(32)
+	// route chat messages to the commands they name, or to the menu a peer
+	// is answering
+	s.conversations = conversation.NewManager(cfg.Chat.ConversationTimeout)
+	go s.conversations.Run(time.Minute)
+
+	// limit how many messages each peer can send, known commands or not
+	s.chatLimiter = ratelimit.New(cfg.Chat.RateLimit.Commands, cfg.Chat.RateLimit.Window)
+	go s.chatLimiter.Run(time.Minute)
+
+	// tell peers over the limit once a window, not once a message
+	s.chatNotices = ratelimit.New(1, cfg.Chat.RateLimit.Window)
+	go s.chatNotices.Run(time.Minute)
+
+	s.commands, err = s.newCommandRouter()
 	if err != nil {
-		log.Fatalf("error generating random bytes: %v", err)
+		return fmt.Errorf("failed to register chat commands: %w", err)
+	}
+
+	// follow peers through connecting and sharing credentials to log in
+	s.auths = newAuthTracker()
+	s.events.Subscribe(s.authenticationEvent)
+
+	// limit how many connection requests, sessions and logins each client
+	// address can start
+	s.httpLimiter = ratelimit.New(cfg.HTTP.RateLimit.Requests, cfg.HTTP.RateLimit.Window)
+	go s.httpLimiter.Run(time.Minute)
+
+	// bind browsers to the authentication of whoever connects with the QR
+	// code they were handed
+	s.sessions = newSessionStore()
+	go s.sessions.run(time.Minute)
+
+	if len(cfg.OIDC.Clients) > 0 {
+		keyPath := cfg.OIDC.KeyFile
+		if ephemeral {
+			keyPath = filepath.Join(storagePath, "oidc-key.pem")
+		}
+
+		signer, err := oidc.LoadSigner(keyPath)
+		if err != nil {
+			return err
+		}
+
+		authenticator := &oidcAuthenticator{s: s}
+		s.oidc = oidc.New(cfg.OIDC, signer, authenticator)
+		authenticator.provider = s.oidc
+		go s.oidc.Run(time.Minute)
+
+		log.Printf("Serving OpenID Connect logins for %d clients as %s", len(cfg.OIDC.Clients), cfg.OIDC.Issuer)
 	}
 
+	// events queued while the server was offline can be delivered before
+	// account.New returns, queue them until the server is wired up
+	startup := &startupQueue{}
+
 	// configure self account and callbacks
-	cfg := &account.Config{
-		StoragePath: "./self-store",
-		StorageKey:  storageKey,
-		Environment: account.TargetSandbox,
-		LogLevel:    account.LogWarn,
-		Callbacks: account.Callbacks{
-			OnConnect: func(acc *account.Account) {
-				log.Println("Connected to Self network")
-			},
-			OnDisconnect: func(acc *account.Account, err error) {
-				log.Println("Disconnected from Self network:", err)
-			},
-			OnWelcome: func(acc *account.Account, wlc *event.Welcome) {
+	accountConfig := newAccountConfig(cfg, env, storagePath, storageKey)
+	accountConfig.Callbacks = account.Callbacks{
+		OnConnect: func(acc *account.Account) {
+			log.Println("Connected to Self network")
+		},
+		OnDisconnect: func(acc *account.Account, err error) {
+			log.Println("Disconnected from Self network:", err)
+		},
+		OnWelcome: func(acc *account.Account, wlc *event.Welcome) {
+			startup.do(func() {
 				log.Printf("Connection received from: %s", wlc.FromAddress().String())
-
-				// Accept the connection request
-				_, err := acc.ConnectionAccept(wlc.ToAddress(), wlc.Welcome())
-				if err != nil {
-					log.Printf("Failed to accept connection: %v", err)
+				if !s.acceptConnection(wlc.ToAddress(), wlc.FromAddress(), wlc.Welcome()) {
 					return
 				}
 
@@ -72,130 +284,201 @@ func startSelf() {
 
 				// Generate new QR code for the next connection
 				log.Println("\nReady for next connection:")
-				displayConnectionQR()
-			},
-			OnKeyPackage: func(acc *account.Account, kp *event.KeyPackage) {
-				_, err := acc.ConnectionEstablish(kp.ToAddress(), kp.KeyPackage())
-				if err != nil {
-					log.Println("OnKeyPackage: Failed to establish connection:", err)
-					panic(err)
-				} else {
-					log.Println("OnKeyPackage: Successfully established connection with client:", kp.FromAddress())
-				}
-			},
-			OnMessage: func(acc *account.Account, msg *event.Message) {
+				s.displayConnectionQR()
+			})
+		},
+		OnKeyPackage: func(acc *account.Account, kp *event.KeyPackage) {
+			startup.do(func() {
+				s.establishConnection(kp.ToAddress(), kp.FromAddress(), kp.KeyPackage())
+			})
+		},
+		OnMessage: func(acc *account.Account, msg *event.Message) {
+			startup.do(func() {
 				contentType := event.ContentTypeOf(msg)
 				if contentType == message.ContentTypeCredentialPresentationResponse {
-					handleCredentialResponse(msg)
+					s.dispatch(msg.FromAddress(), "handleCredentialResponse", func() error {
+						return s.handleCredentialResponse(msg)
+					})
 				} else if contentType == message.ContentTypeCredentialVerificationResponse {
-					handleDocumentSigningResponse(msg)
+					s.dispatch(msg.FromAddress(), "handleDocumentSigningResponse", func() error {
+						return s.handleDocumentSigningResponse(msg)
+					})
 				} else if contentType == message.ContentTypeChat {
-					handleChatMessage(acc, msg)
+					s.dispatch(msg.FromAddress(), "handleChatMessage", func() error {
+						return s.handleChatMessage(msg)
+					})
 				} else if contentType == message.ContentTypeDiscoveryRequest {
 					log.Printf("Received discovery request from %s", msg.FromAddress())
-					handleDiscoveryRequest(acc, msg)
+					s.dispatch(msg.FromAddress(), "handleDiscoveryRequest", func() error {
+						return s.handleDiscoveryRequest(msg)
+					})
 				} else if contentType == message.ContentTypeDiscoveryResponse {
 					log.Printf("Received discovery response from %s", msg.FromAddress())
-					handleDiscoveryResponse(msg)
+					s.dispatch(msg.FromAddress(), "handleDiscoveryResponse", func() error {
+						return s.handleDiscoveryResponse(msg)
+					})
 				} else if contentType == message.ContentTypeIntroduction {
 					log.Printf("Received introduction message from %s", msg.FromAddress())
 				} else {
 					log.Printf("Unknown message type: %d from %s", event.ContentTypeOf(msg), msg.FromAddress())
 				}
-			},
+			})
 		},
 	}
 
 	// start self account
-	selfAccount, err = account.New(cfg)
+	selfAccount, err := account.New(accountConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize account: %w", err)
+	}
+	defer func() {
+		log.Println("Cleaning up...")
+		err := selfAccount.Close()
+		if err != nil {
+			log.Println("Error in defer close:", err)
+		} else {
+			log.Println("Self client closed")
+		}
+	}()
+
+	s.account = selfaccount.NewClassified(selfAccount)
+
+	log.Printf("Self account initialized (%s)", env)
+
+	if !ephemeral {
+		err = recordStoreEnvironment(storagePath, env)
+		if err != nil {
+			return err
+		}
+	}
+
+	inboxList, err := s.account.InboxList()
+	if err != nil {
+		return fmt.Errorf("failed to get inbox list: %w", err)
+	}
+
+	// track open inboxes and close the ones that expire unused
+	s.inboxes = newInboxManager(s.account, filepath.Join(storagePath, inboxesFile))
+
+	s.address, err = s.inboxes.primary(inboxList)
+	if err != nil {
+		return fmt.Errorf("failed to find the server address: %w", err)
+	}
+
+	log.Println("server address:", s.address)
+
+	// decide whose credentials to accept, "self" is the server address
+	s.trust, err = trust.New(cfg.Credentials.Trust, cfg.Credentials.Definitions, s.address, definition.ResolveType)
 	if err != nil {
-		log.Fatal("failed to initialize account: ", err)
+		return fmt.Errorf("failed to compile trust policy: %w", err)
+	}
+	if types := s.trust.AnyIssuer(); len(types) > 0 {
+		log.Printf("Accepting %s credentials from any issuer", strings.Join(types, ", "))
+	}
+	if names := s.trust.Untrusted(); len(names) > 0 {
+		log.Printf("No issuers are trusted for the %s credential definitions, they cannot be requested", strings.Join(names, ", "))
 	}
 
-	log.Println("Self account initialized")
+	// logins that ask for an untrusted credential could never succeed
+	if s.oidc != nil {
+		for _, scope := range slices.Sorted(maps.Keys(cfg.OIDC.Scopes)) {
+			for _, name := range cfg.OIDC.Scopes[scope] {
+				if !s.trust.Trusted(name) {
+					return fmt.Errorf("oidc.scopes.%s: no issuer is trusted for the %s credential definition, configure credentials.trust", scope, name)
+				}
+			}
+		}
+	}
 
-	inboxList, err := selfAccount.InboxList()
+	err = s.inboxes.restore(s.address, inboxList, time.Now().Add(cfg.Connection.QRExpiry))
 	if err != nil {
-		log.Fatal("failed to get inbox list:", err)
+		log.Printf("Failed to restore inboxes, keeping them open as unknown: %v", err)
 	}
+	go s.inboxes.run(cfg.Connection.InboxReapInterval)
 
-	inboxAddress = inboxList[0]
+	startup.release()
 
-	log.Println("server address:", inboxList[0])
+	log.Printf("Open inboxes: %d", len(inboxList))
 
 	// Generate or display the application address
-	generateDocument()
+	err = s.generateDocument()
+	if err != nil {
+		return err
+	}
 
 	// Generate initial QR code after account is ready
 	log.Println("\nInitial connection QR code:")
-	displayConnectionQR()
+	s.displayConnectionQR()
 
-	// handle graceful shutdown
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	// servers report failures here, as they serve in the background
+	serveErrs := make(chan error, 2)
 
-	go func() {
-		sig := <-sigs
-		log.Println("Received signal:", sig)
-		err := selfAccount.Close()
-		if err != nil {
-			log.Println("Error closing SDK:", err)
-		} else {
-			log.Println("Self client closed gracefully")
-		}
-		os.Exit(0)
-	}()
+	// Serve connection QR codes over HTTP
+	if cfg.HTTP.Addr != "" {
+		httpServer := s.startHTTPServer(cfg.HTTP.Addr, serveErrs)
+		defer stopHTTPServer(httpServer)
+	}
 
-	defer func() {
-		log.Println("Cleaning up...")
-		err := selfAccount.Close()
+	// Serve the same flows over gRPC
+	if cfg.GRPC.Addr != "" {
+		grpcServer, err := s.startGRPCServer(cfg.GRPC.Addr, serveErrs)
 		if err != nil {
-			log.Println("Error in defer close:", err)
-		} else {
-			log.Println("Self client closed")
+			return err
 		}
-	}()
+		defer stopGRPCServer(grpcServer)
+	}
+
+	// handle graceful shutdown
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	// Keep the server running
 	log.Println("Server running... Press Ctrl+C to exit")
-	select {}
+
+	select {
+	case sig := <-sigs:
+		log.Println("Received signal:", sig)
+		return nil
+	case err := <-serveErrs:
+		return err
+	}
 }
 
 // generateDocument creates or displays the application address (identity document)
-func generateDocument() {
+func (s *server) generateDocument() error {
 	// Check if we already have an identity
-	identityList, err := selfAccount.IdentityList()
+	identityList, err := s.account.IdentityList()
 	if err != nil {
-		log.Fatalf("Failed to get identity list: %v", err)
+		return fmt.Errorf("failed to get identity list: %w", err)
 	}
 
 	if len(identityList) > 0 {
 		log.Printf("Application address: %s", identityList[0])
-		return
+		return nil
 	}
 
 	// Create new signing keys for the identity document
-	identifierAddress, err := selfAccount.KeychainSigningCreate()
+	identifierAddress, err := s.account.KeychainSigningCreate()
 	if err != nil {
-		log.Fatalf("Failed to create identifier key: %v", err)
+		return fmt.Errorf("failed to create identifier key: %w", err)
 	}
 
-	invocationAddress, err := selfAccount.KeychainSigningCreate()
+	invocationAddress, err := s.account.KeychainSigningCreate()
 	if err != nil {
-		log.Fatalf("Failed to create invocation key: %v", err)
+		return fmt.Errorf("failed to create invocation key: %w", err)
 	}
 
-	assertionAddress, err := selfAccount.KeychainSigningCreate()
+	assertionAddress, err := s.account.KeychainSigningCreate()
 	if err != nil {
-		log.Fatalf("Failed to create assertion key: %v", err)
+		return fmt.Errorf("failed to create assertion key: %w", err)
 	}
 
-	authenticationAddress, err := selfAccount.KeychainSigningCreate()
+	authenticationAddress, err := s.account.KeychainSigningCreate()
 	if err != nil {
-		log.Fatalf("Failed to create authentication key: %v", err)
+		return fmt.Errorf("failed to create authentication key: %w", err)
 	}
 
-	messagingAddress := inboxAddress
+	messagingAddress := s.address
 
 	// Build the identity operation
 	operation := identity.NewOperation().
@@ -214,17 +497,19 @@ func generateDocument() {
 		Finish()
 
 	// Execute the identity operation
-	err = selfAccount.IdentityExecute(operation)
+	err = s.account.IdentityExecute(operation)
 	if err != nil {
-		log.Fatalf("Failed to execute identity operation: %v", err)
+		return fmt.Errorf("failed to execute identity operation: %w", err)
 	}
 
 	log.Printf("Application address: %s", identifierAddress)
+
+	return nil
 }
 
 // displayConnectionQR generates and displays a QR code in the terminal
-func displayConnectionQR() {
-	qrCode, expiresAt, err := generateConnectionQR()
+func (s *server) displayConnectionQR() {
+	qrCode, link, expiresAt, err := s.generateConnectionQR()
 	if err != nil {
 		log.Printf("Failed to generate QR code: %v", err)
 		return
@@ -233,27 +518,73 @@ func displayConnectionQR() {
 	log.Println("\n" + qrCode)
 	log.Printf("Expires: %s\n", expiresAt.Format("15:04:05 MST"))
 	log.Println("Scan this QR code with your Self mobile app to establish a connection")
+	if link != "" {
+		log.Println("or open this link on the phone with the app installed:")
+		log.Println(link)
+	}
 	log.Println()
 }
 
-// generateConnectionQR creates a QR code for mobile app connections
-func generateConnectionQR() (string, time.Time, error) {
-	// Open inbox for receiving connection requests
-	currentInboxAddress, err := selfAccount.InboxOpen()
+// connectionRequest is a discovery request for mobile app connections,
+// ready to be encoded as a QR code or link
+type connectionRequest struct {
+	message    *event.AnonymousMessage
+	invitation invitation.Invitation
+	expiresAt  time.Time
+}
+
+// generateConnectionQR creates a QR code and matching deep link for mobile
+// app connections
+func (s *server) generateConnectionQR() (string, string, time.Time, error) {
+	request, err := s.newConnectionRequest(invitation.Options{Creator: "console"})
 	if err != nil {
-		log.Printf("generateConnectionQR: Failed to open inbox: %v", err)
-		return "", time.Time{}, fmt.Errorf("failed to open inbox: %v", err)
+		return "", "", time.Time{}, err
+	}
+
+	// Encode to QR (Unicode for terminal display)
+	qrCode, err := request.message.EncodeToQR(event.QREncodingUnicode)
+	if err != nil {
+		log.Printf("generateConnectionQR: Failed to generate QR code: %v", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to generate QR code: %v", err)
+	}
+
+	link, err := request.link(s.config.Links.BaseURL)
+	if err != nil {
+		log.Printf("generateConnectionQR: Failed to generate link: %v", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to generate link: %v", err)
+	}
+
+	return string(qrCode), link, request.expiresAt, nil
+}
+
+// newConnectionRequest opens an inbox and builds the anonymous discovery
+// request message a mobile app uses to connect to it. The request is
+// recorded as an invitation described by opts.
+func (s *server) newConnectionRequest(opts invitation.Options) (*connectionRequest, error) {
+	// refuse oversized metadata before opening an inbox for it
+	err := invitation.CheckMetadata(opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	expirationTime := time.Now().Add(s.config.Connection.QRExpiry)
+
+	// Open inbox for receiving connection requests, closed again if nobody
+	// connects before the request expires
+	currentInboxAddress, err := s.inboxes.open(inboxPurposeInvitation, expirationTime)
+	if err != nil {
+		log.Printf("newConnectionRequest: Failed to open inbox: %v", err)
+		return nil, fmt.Errorf("failed to open inbox: %v", err)
 	}
 
 	// Generate cryptographic key package for secure communication
-	expirationTime := time.Now().Add(30 * time.Minute)
-	keyPackage, err := selfAccount.ConnectionNegotiateOutOfBand(
+	keyPackage, err := s.account.ConnectionNegotiateOutOfBand(
 		currentInboxAddress,
 		expirationTime,
 	)
 	if err != nil {
-		log.Printf("generateConnectionQR: Failed to generate key package: %v", err)
-		return "", time.Time{}, fmt.Errorf("failed to generate key package: %v", err)
+		log.Printf("newConnectionRequest: Failed to generate key package: %v", err)
+		return nil, fmt.Errorf("failed to generate key package: %v", err)
 	}
 
 	// Build discovery request message
@@ -262,279 +593,170 @@ func generateConnectionQR() (string, time.Time, error) {
 		Expires(expirationTime).
 		Finish()
 	if err != nil {
-		log.Printf("generateConnectionQR: Failed to build discovery request: %v", err)
-		return "", time.Time{}, fmt.Errorf("failed to build discovery request: %v", err)
+		log.Printf("newConnectionRequest: Failed to build discovery request: %v", err)
+		return nil, fmt.Errorf("failed to build discovery request: %v", err)
 	}
 
-	// Create anonymous message and encode to QR (Unicode for terminal display)
+	// Create anonymous message flagged for the configured environment
 	anonymousMsg := event.NewAnonymousMessage(content)
-	anonymousMsg.SetFlags(event.MessageFlagTargetSandbox)
+	s.env.flagMessage(anonymousMsg)
 
-	qrCode, err := anonymousMsg.EncodeToQR(event.QREncodingUnicode)
+	opts.InboxAddress = currentInboxAddress.String()
+	opts.KeyPackage = keyPackage.KeyPackage()
+	opts.ExpiresAt = expirationTime
+
+	inv, err := s.invitations.Issue(opts)
 	if err != nil {
-		log.Printf("generateConnectionQR: Failed to generate QR code: %v", err)
-		return "", time.Time{}, fmt.Errorf("failed to generate QR code: %v", err)
+		log.Printf("newConnectionRequest: Failed to record invitation: %v", err)
+		return nil, fmt.Errorf("failed to record invitation: %v", err)
 	}
 
-	return string(qrCode), expirationTime, nil
+	return &connectionRequest{
+		message:    anonymousMsg,
+		invitation: inv,
+		expiresAt:  expirationTime,
+	}, nil
 }
 
-func handleChatMessage(selfAccount *account.Account, msg *event.Message) {
-	chatMessage, err := message.DecodeChat(msg.Content())
-	if err != nil {
-		log.Printf("HandleChatMessage: Failed to decode chat message from %s: %v", msg.FromAddress(), err)
-		return
+// errUnknownDefinition is returned for requests of a credential that is not
+// defined
+var errUnknownDefinition = errors.New("no such credential definition")
+
+// errUntrustedDefinition is returned for requests of a credential no issuer
+// is trusted for, as every response to them would be rejected
+var errUntrustedDefinition = errors.New("no issuer is trusted for the credential definition")
+
+// sendCredentialRequest asks a peer to present the credential described by
+// the named definition and tracks the request until it is answered
+func (s *server) sendCredentialRequest(peer *signing.PublicKey, name string, origin pending.Origin) (pending.Request, error) {
+	definition, ok := s.definitions[name]
+	if !ok {
+		return pending.Request{}, newFlowError("sendCredentialRequest", stageBuild, name+" credential request", "Sorry, we cannot request that credential.", errUnknownDefinition)
 	}
 
-	command := chatMessage.Message()
-
-	switch command {
-	case "REQUEST_CREDENTIAL_AUTH":
-		sendCredentialRequest(selfAccount, msg, "liveness")
-	case "PROVIDE_CREDENTIAL_EMAIL":
-		sendCredentialRequest(selfAccount, msg, "email")
-	case "PROVIDE_CREDENTIAL_DOCUMENT":
-		sendCredentialRequest(selfAccount, msg, "document")
-	case "PROVIDE_CREDENTIAL_CUSTOM":
-		sendCredentialRequest(selfAccount, msg, "custom")
-	case "REQUEST_GET_CUSTOM_CREDENTIAL":
-		sendCustomCredential(selfAccount, msg)
-	case "REQUEST_DOCUMENT_SIGNING":
-		sendDocumentSigningRequest(selfAccount, msg)
-	default:
-		log.Printf("HandleChatMessage: Unknown command '%s' from %s - ignoring", command, msg.FromAddress())
+	if !s.trust.Trusted(name) {
+		return pending.Request{}, newFlowError("sendCredentialRequest", stageBuild, name+" credential request", "Sorry, we cannot accept that credential from anyone yet.", errUntrustedDefinition)
 	}
-}
 
-func sendCredentialRequest(selfAccount *account.Account, msg *event.Message, credentialType string) {
-	var content *message.Content
-	var err error
-
-	switch credentialType {
-	case "liveness":
-		content, err = message.NewCredentialPresentationRequest().
-			PresentationType("CustomPresentation").
-			Predicates(
-				predicate.NewTree(
-					predicate.Contains(
-						credential.FieldType,
-						credential.CredentialTypeLivenessAndFacialComparison,
-					).And(
-						predicate.NotEmpty(
-							credential.FieldSubjectLivenessAndFacialComparisonSourceImageHash,
-						),
-					),
-				),
-			).
-			Finish()
-
-	case "email":
-		content, err = message.NewCredentialPresentationRequest().
-			PresentationType("CustomPresentation").
-			Predicates(
-				predicate.NewTree(
-					predicate.Contains(
-						credential.FieldType,
-						credential.CredentialTypeEmail,
-					).And(
-						predicate.NotEmpty(
-							credential.FieldSubjectEmailAddress,
-						),
-					),
-				),
-			).
-			Finish()
-
-	case "document":
-		content, err = message.NewCredentialPresentationRequest().
-			PresentationType("CustomPresentation").
-			Predicates(
-				predicate.NewTree(
-					predicate.Contains(
-						credential.FieldType,
-						credential.CredentialTypePassport,
-					).And(
-						predicate.NotEmpty(
-							credential.FieldSubjectPassportDocumentNumber,
-						),
-					),
-				),
-			).
-			Finish()
-
-	case "custom":
-		content, err = message.NewCredentialPresentationRequest().
-			PresentationType("CustomPresentation").
-			Predicates(
-				predicate.NewTree(
-					predicate.Contains(
-						credential.FieldType,
-						"CustomerCredential",
-					).And(
-						predicate.NotEmpty(
-							"/credentialSubject/name",
-						),
-					),
-				),
-			).
-			Finish()
+	reply := fmt.Sprintf("Sorry, we could not request your %s. Please try again.", definition.Description)
+	expires := time.Now().Add(s.config.Credentials.RequestExpiry)
 
-	default:
-		log.Printf("sendCredentialRequest: Unsupported credential type '%s' from %s", credentialType, msg.FromAddress())
-		return
-	}
+	content, err := message.NewCredentialPresentationRequest().
+		PresentationType(s.config.Credentials.PresentationType).
+		Predicates(definition.Predicates()).
+		Expires(expires).
+		Finish()
 
 	if err != nil {
-		log.Fatalf("SendCredentialRequest: Failed to build %s credential request: %v", credentialType, err)
+		return pending.Request{}, newFlowError("sendCredentialRequest", stageBuild, name+" credential request", reply, err)
 	}
 
-	err = selfAccount.MessageSend(msg.FromAddress(), content)
+	req, err := s.sendRequest(peer, content, pending.Request{
+		Kind:       pending.KindCredentialPresentation,
+		Origin:     origin,
+		Subject:    name,
+		Conditions: definition.Root,
+		ExpiresAt:  expires,
+	})
 	if err != nil {
-		log.Fatalf("SendCredentialRequest: Failed to send %s request message to %s: %v", credentialType, msg.FromAddress(), err)
-	} else {
-		log.Printf("SendCredentialRequest: Sent %s credential request to: %s", credentialType, msg.FromAddress())
+		return pending.Request{}, newFlowError("sendCredentialRequest", stageSend, name+" credential request", reply, err)
 	}
+
+	log.Printf("SendCredentialRequest: Sent %s credential request %s to: %s", name, definition.Root, peer)
+	return req, nil
+}
+
+// sendCustomCredential issues the configured custom credential to a peer
+func (s *server) sendCustomCredential(peer *signing.PublicKey) error {
+	_, err := s.issueCredential(peer, s.config.Credentials.CustomType, s.config.Credentials.CustomClaims)
+	return err
 }
 
-func sendCustomCredential(selfAccount *account.Account, msg *event.Message) {
-	credentialType := "CustomerCredential"
-	subjectAddress := credential.AddressKey(msg.FromAddress())
-	issuerAddress := credential.AddressKey(inboxAddress)
+// issueCredential issues a credential of the given type and claims to a
+// peer, and returns the hex encoded ID of the message it was sent in
+func (s *server) issueCredential(peer *signing.PublicKey, credentialType string, claims map[string]any) (string, error) {
+	const reply = "Sorry, we could not issue your credential. Please try again."
+
+	subjectAddress := credential.AddressKey(peer)
+	issuerAddress := credential.AddressKey(s.address)
 
-	customerCredential, err := credential.NewCredential().
+	unsignedCredential, err := credential.NewCredential().
 		CredentialType(credentialType).
 		CredentialSubject(subjectAddress).
-		CredentialSubjectClaims(map[string]any{
-			"name": "Test Name",
-		}).
+		CredentialSubjectClaims(claims).
 		Issuer(issuerAddress).
 		ValidFrom(time.Now()).
-		SignWith(inboxAddress, time.Now()).
+		SignWith(s.address, time.Now()).
 		Finish()
 
 	if err != nil {
-		log.Fatal("sendCustomCredential: failed to build credential", "error", err)
+		return "", newFlowError("issueCredential", stageBuild, "credential", reply, err)
 	}
 
-	customerVerifiableCredential, err := selfAccount.CredentialIssue(customerCredential)
+	verifiableCredential, err := s.account.CredentialIssue(unsignedCredential)
 	if err != nil {
-		log.Fatal("sendCustomCredential: failed to build credential", "error", err)
+		return "", newFlowError("issueCredential", stageIssue, "credential", reply, err)
 	}
 
 	content, err := message.NewCredential().
-		VerifiableCredential(customerVerifiableCredential).
+		VerifiableCredential(verifiableCredential).
 		Finish()
 
 	if err != nil {
-		log.Fatal("sendCustomCredential: failed to encode credential request message", "error", err)
+		return "", newFlowError("issueCredential", stageBuild, "credential message", reply, err)
 	}
 
-	err = selfAccount.MessageSend(msg.FromAddress(), content)
+	err = s.account.MessageSend(peer, content)
 	if err != nil {
-		log.Fatal("sendCustomCredential: failed to send credential message", "error", err)
-	} else {
-		log.Printf("sendCustomCredential: custom credential sent to %s", msg.FromAddress())
+		return "", newFlowError("issueCredential", stageSend, "credential message", reply, err)
 	}
+
+	log.Printf("issueCredential: %s credential sent to %s", credentialType, peer)
+	return hex.EncodeToString(content.ID()), nil
 }
 
-func handleCredentialResponse(msg *event.Message) {
+func (s *server) handleCredentialResponse(msg *event.Message) error {
 	response, err := message.DecodeCredentialPresentationResponse(msg.Content())
 	if err != nil {
-		log.Println("handleCredentialResponse: Failed to decode credential response from", msg.FromAddress(), "error:", err)
-		return
+		return fmt.Errorf("failed to decode credential response: %w", err)
 	}
 
-	var content string
-
-	for i, p := range response.Presentations() {
-		err = p.Validate()
-		if err != nil {
-			log.Printf("handleCredentialResponse: VALIDATION FAILED for presentation %d: %v", i+1, err)
-			continue
-		}
-
-		if !p.Holder().Address().Matches(msg.FromAddress()) {
-			log.Printf("handleCredentialResponse: SECURITY WARNING - presentation holder address mismatch. Expected: %s, Got: %s",
-				msg.FromAddress(), p.Holder().Address())
-			continue
-		}
-
-		for _, credential := range p.Credentials() {
-			err = credential.Validate()
-			if err != nil {
-				log.Printf("handleCredentialResponse: CREDENTIAL VALIDATION FAILED: %v", err)
-				continue
-			}
-
-			if credential.ValidFrom().After(time.Now()) {
-				log.Printf("handleCredentialResponse: WARNING - credential is not yet valid (valid from: %v, current time: %v)",
-					credential.ValidFrom(), time.Now())
-				continue
-			}
-
-			claims, err := credential.CredentialSubjectClaims()
-			if err != nil {
-				log.Println(err)
-				return
-			}
-
-			for k, v := range claims {
-				if k == "sourceImageHash" {
-					content = content + "Authentication = true"
-					continue
-				}
-				if k != "id" && k != "sourceImageHash" && k != "targetImageHash" {
-					content = content + fmt.Sprintf("%s = %v\n", k, v)
-					continue
-				}
-			}
-		}
-	}
-
-	if response.Status().String() == "Accepted" && content != "" {
-	} else if response.Status().String() == "Accepted" && content == "" {
-		content = content + "content empty"
-	} else {
-		content = content + "request rejected"
+	req, err := s.resolveRequest(response.ResponseTo(), pending.KindCredentialPresentation, msg.FromAddress())
+	if err != nil {
+		log.Printf("handleCredentialResponse: Ignoring response from %s: %v", msg.FromAddress(), err)
+		return nil
 	}
 
-	log.Println("handleCredentialResponse: " + content)
+	return s.verificationCompleted(msg.FromAddress(), s.verifyPresentationResponse(msg.FromAddress(), req, response))
 }
 
-func sendDocumentSigningRequest(selfAccount *account.Account, msg *event.Message) {
-	serverAddress := inboxAddress
-	clientAddress := msg.FromAddress()
+// sendDocumentSigningRequest asks a peer to sign an agreement, and tracks
+// the request until it is answered. document is the agreement as a PDF; if
+// it is nil the standard agreement is generated, with the reference printed
+// on it if not empty.
+func (s *server) sendDocumentSigningRequest(peer *signing.PublicKey, reference string, document []byte, origin pending.Origin) (pending.Request, error) {
+	const op = "sendDocumentSigningRequest"
+	const reply = "Sorry, we could not send you the agreement to sign. Please try again."
 
-	pdf := fpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(40, 10, "Document Signing Agreement")
-	pdf.Ln(20)
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(0, 10, "This document represents an agreement between:")
-	pdf.Ln(10)
-	pdf.Cell(0, 10, "Server: "+serverAddress.String())
-	pdf.Ln(10)
-	pdf.Cell(0, 10, "Client: "+clientAddress.String())
-	pdf.Ln(10)
-	pdf.Cell(0, 10, "By signing this agreement, both parties acknowledge")
-	pdf.Ln(10)
-	pdf.Cell(0, 10, "the terms and conditions of this document signing process.")
+	serverAddress := s.address
+	clientAddress := peer
 
-	agreementBuf := bytes.NewBuffer(make([]byte, 1024))
-	err := pdf.Output(agreementBuf)
-	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to generate PDF: %v", err)
+	if document == nil {
+		var err error
+		document, err = s.agreementDocument(peer, reference)
+		if err != nil {
+			return pending.Request{}, newFlowError(op, stageBuild, "agreement PDF", reply, err)
+		}
 	}
 
-	agreementTerms, err := object.New("application/pdf", agreementBuf.Bytes())
+	agreementTerms, err := object.New("application/pdf", document)
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to create agreement object: %v", err)
+		return pending.Request{}, newFlowError(op, stageBuild, "agreement object", reply, err)
 	}
 
-	err = selfAccount.ObjectUpload(agreementTerms, false)
+	err = s.account.ObjectUpload(agreementTerms, false)
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to upload agreement object: %v", err)
+		return pending.Request{}, newFlowError(op, stageUpload, "agreement object", reply, err)
 	}
 
 	claims := map[string]interface{}{
@@ -544,9 +766,12 @@ func sendDocumentSigningRequest(selfAccount *account.Account, msg *event.Message
 			{"type": "signatory", "id": clientAddress.String()},
 		},
 	}
+	if reference != "" {
+		claims["reference"] = reference
+	}
 
 	unsignedAgreementCredential, err := credential.NewCredential().
-		CredentialType("AgreementCredential").
+		CredentialType(s.config.Signing.CredentialType).
 		CredentialSubject(credential.AddressKey(serverAddress)).
 		CredentialSubjectClaims(claims).
 		CredentialSubjectClaim("terms", hex.EncodeToString(agreementTerms.Id())).
@@ -556,12 +781,12 @@ func sendDocumentSigningRequest(selfAccount *account.Account, msg *event.Message
 		Finish()
 
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to create credential: %v", err)
+		return pending.Request{}, newFlowError(op, stageBuild, "agreement credential", reply, err)
 	}
 
-	signedAgreementCredential, err := selfAccount.CredentialIssue(unsignedAgreementCredential)
+	signedAgreementCredential, err := s.account.CredentialIssue(unsignedAgreementCredential)
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to issue credential: %v", err)
+		return pending.Request{}, newFlowError(op, stageIssue, "agreement credential", reply, err)
 	}
 
 	unsignedAgreementPresentation, err := credential.NewPresentation().
@@ -571,51 +796,126 @@ func sendDocumentSigningRequest(selfAccount *account.Account, msg *event.Message
 		Finish()
 
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to create presentation: %v", err)
+		return pending.Request{}, newFlowError(op, stageBuild, "agreement presentation", reply, err)
 	}
 
-	signedAgreementPresentation, err := selfAccount.PresentationIssue(unsignedAgreementPresentation)
+	signedAgreementPresentation, err := s.account.PresentationIssue(unsignedAgreementPresentation)
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to issue presentation: %v", err)
+		return pending.Request{}, newFlowError(op, stageIssue, "agreement presentation", reply, err)
 	}
 
+	expires := time.Now().Add(s.config.Signing.RequestExpiry)
+
 	content, err := message.NewCredentialVerificationRequest().
-		Type("AgreementCredential").
+		Type(s.config.Signing.CredentialType).
 		Evidence("terms", agreementTerms).
 		Proof(signedAgreementPresentation).
-		Expires(time.Now().Add(time.Hour * 24)).
+		Expires(expires).
 		Finish()
 
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to build verification request: %v", err)
+		return pending.Request{}, newFlowError(op, stageBuild, "verification request", reply, err)
 	}
 
-	err = selfAccount.MessageSend(msg.FromAddress(), content)
+	req, err := s.sendRequest(peer, content, pending.Request{
+		Kind:      pending.KindCredentialVerification,
+		Origin:    origin,
+		Subject:   s.config.Signing.CredentialType,
+		ExpiresAt: expires,
+	})
 	if err != nil {
-		log.Fatalf("SendDocumentSigningRequest: Failed to send document signing request to %s: %v", msg.FromAddress(), err)
-	} else {
-		log.Printf("SendDocumentSigningRequest: Successfully sent document signing request to: %s", msg.FromAddress())
+		return pending.Request{}, newFlowError(op, stageSend, "verification request", reply, err)
 	}
+
+	log.Printf("SendDocumentSigningRequest: Successfully sent document signing request to: %s", peer)
+	return req, nil
 }
 
-func handleDocumentSigningResponse(msg *event.Message) {
+// agreementDocument generates the standard agreement between the server and
+// a peer as a PDF
+func (s *server) agreementDocument(peer *signing.PublicKey, reference string) ([]byte, error) {
+	serverAddress := s.address
+	clientAddress := peer
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(40, 10, "Document Signing Agreement")
+	pdf.Ln(20)
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(0, 10, "This document represents an agreement between:")
+	pdf.Ln(10)
+	pdf.Cell(0, 10, "Server: "+serverAddress.String())
+	pdf.Ln(10)
+	pdf.Cell(0, 10, "Client: "+clientAddress.String())
+	pdf.Ln(10)
+	if reference != "" {
+		pdf.Cell(0, 10, "Reference: "+reference)
+		pdf.Ln(10)
+	}
+	pdf.Cell(0, 10, "By signing this agreement, both parties acknowledge")
+	pdf.Ln(10)
+	pdf.Cell(0, 10, "the terms and conditions of this document signing process.")
+
+	var agreementBuf bytes.Buffer
+	err := pdf.Output(&agreementBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	return agreementBuf.Bytes(), nil
+}
+
+func (s *server) handleDocumentSigningResponse(msg *event.Message) error {
 	response, err := message.DecodeCredentialVerificationResponse(msg.Content())
 	if err != nil {
-		log.Printf("handleDocumentSigningResponse: Failed to decode verification response from %s: %v", msg.FromAddress(), err)
-		return
+		return fmt.Errorf("failed to decode verification response: %w", err)
+	}
+
+	req, err := s.resolveRequest(response.ResponseTo(), pending.KindCredentialVerification, msg.FromAddress())
+	if err != nil {
+		log.Printf("handleDocumentSigningResponse: Ignoring response from %s: %v", msg.FromAddress(), err)
+		return nil
 	}
 
+	var reply, outcome string
+	var eventType events.Type
+
 	status := response.Status()
 	if status == message.ResponseStatusAccepted || status == message.ResponseStatusCreated {
 		log.Printf("handleDocumentSigningResponse: Client %s has digitally signed the agreement", msg.FromAddress())
+		reply = "Thank you for signing the agreement."
+		outcome = "signed"
+		eventType = events.AgreementSigned
 	} else if status == message.ResponseStatusUnauthorized || status == message.ResponseStatusForbidden || status == message.ResponseStatusNotAcceptable {
 		log.Printf("handleDocumentSigningResponse: Client %s declined to sign the agreement", msg.FromAddress())
+		reply = "You declined to sign the agreement."
+		outcome = "declined"
+		eventType = events.AgreementDeclined
 	} else {
-		log.Printf("handleDocumentSigningResponse: UNKNOWN RESPONSE STATUS from client: %s", msg.FromAddress())
+		return fmt.Errorf("unknown response status %s", status)
+	}
+
+	agreement := events.AgreementData{
+		PeerAddress: msg.FromAddress().String(),
+		RequestID:   req.ID,
+		Status:      status.String(),
+	}
+
+	s.completeRequest(req.ID, outcome, agreement)
+	s.events.Publish(eventType, agreement)
+
+	err = s.sendChat(msg.FromAddress(), reply)
+	if err != nil {
+		return newFlowError("handleDocumentSigningResponse", stageSend, "agreement reply", "", err)
 	}
+
+	return nil
 }
 
-func handleDiscoveryRequest(selfAccount *account.Account, msg *event.Message) {
+func (s *server) handleDiscoveryRequest(msg *event.Message) error {
+	const op = "handleDiscoveryRequest"
+
 	log.Printf("handleDiscoveryRequest: Processing discovery request from %s", msg.FromAddress())
 
 	// Send a discovery response accepting the request
@@ -625,27 +925,33 @@ func handleDiscoveryRequest(selfAccount *account.Account, msg *event.Message) {
 		Finish()
 
 	if err != nil {
-		log.Printf("handleDiscoveryRequest: Failed to build discovery response: %v", err)
-		return
+		return newFlowError(op, stageBuild, "discovery response", "", err)
 	}
 
-	err = selfAccount.MessageSend(msg.FromAddress(), content)
+	err = s.account.MessageSend(msg.FromAddress(), content)
 	if err != nil {
-		log.Printf("handleDiscoveryRequest: Failed to send discovery response to %s: %v", msg.FromAddress(), err)
-	} else {
-		log.Printf("handleDiscoveryRequest: Successfully sent discovery response to %s", msg.FromAddress())
+		return newFlowError(op, stageSend, "discovery response", "", err)
 	}
+
+	log.Printf("handleDiscoveryRequest: Successfully sent discovery response to %s", msg.FromAddress())
+	return nil
 }
 
-func handleDiscoveryResponse(msg *event.Message) {
+func (s *server) handleDiscoveryResponse(msg *event.Message) error {
 	discoveryResponse, err := message.DecodeDiscoveryResponse(msg.Content())
 	if err != nil {
-		log.Printf("handleDiscoveryResponse: Failed to decode discovery response from %s: %v", msg.FromAddress(), err)
-		return
+		return fmt.Errorf("failed to decode discovery response: %w", err)
 	}
 
 	log.Printf("handleDiscoveryResponse: Received discovery response from %s with status: %s",
 		msg.FromAddress(), discoveryResponse.Status().String())
+
+	s.events.Publish(events.DiscoveryResponse, events.DiscoveryData{
+		PeerAddress: msg.FromAddress().String(),
+		Status:      discoveryResponse.Status().String(),
+	})
+
+	return nil
 }
 
 func generateRandomBytes(size int) ([]byte, error) {
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/contacts"
+	"github.com/joinself/self-sdk-examples/golang/internal/conversation"
+	"github.com/joinself/self-sdk-examples/golang/internal/definition"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+	"github.com/joinself/self-sdk-examples/golang/internal/selfaccount"
+	"github.com/joinself/self-sdk-examples/golang/internal/trust"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// newTestServer returns a server wired like startSelf, on a fake account
+func newTestServer(t *testing.T) (*server, *selfaccount.Fake) {
+	t.Helper()
+
+	fake, err := selfaccount.NewFake()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inboxes, err := fake.InboxList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Links.BaseURL = "https://links.example.com/connect"
+	cfg.Credentials.Trust.AnyIssuer = config.SandboxAnyIssuer
+
+	s := &server{
+		account:     selfaccount.NewClassified(fake),
+		config:      cfg,
+		address:     inboxes[0],
+		contacts:    contacts.NewMemoryStore(),
+		events:      events.NewBus(),
+		invitations: invitation.NewRegistry(time.Hour),
+		requests:    pending.NewRegistry(time.Hour),
+		auths:       newAuthTracker(),
+		sessions:    newSessionStore(),
+		chatLimiter: ratelimit.New(cfg.Chat.RateLimit.Commands, cfg.Chat.RateLimit.Window),
+		chatNotices: ratelimit.New(1, cfg.Chat.RateLimit.Window),
+		httpLimiter: ratelimit.New(cfg.HTTP.RateLimit.Requests, cfg.HTTP.RateLimit.Window),
+	}
+
+	s.definitions, err = definition.Compile(cfg.Credentials.Definitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.trust, err = trust.New(cfg.Credentials.Trust, cfg.Credentials.Definitions, s.address, definition.ResolveType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.inboxes = newInboxManager(s.account, "")
+	s.inboxes.track(s.address, inboxPurposePrimary, time.Time{})
+	s.conversations = conversation.NewManager(cfg.Chat.ConversationTimeout)
+	s.events.Subscribe(s.authenticationEvent)
+
+	s.commands, err = s.newCommandRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, fake
+}
+
+// distrustTestServer replaces a test server's trust policy with one that
+// trusts no issuer, as production does until credentials.trust is set
+func distrustTestServer(t *testing.T, s *server) {
+	t.Helper()
+
+	var err error
+	s.trust, err = trust.New(config.Trust{}, s.config.Credentials.Definitions, s.address, definition.ResolveType)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestAddress(t *testing.T) *signing.PublicKey {
+	t.Helper()
+
+	address, err := selfaccount.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// recordEvents collects the events published on a server's bus
+func recordEvents(s *server) func() []events.Event {
+	var mu sync.Mutex
+	var published []events.Event
+
+	s.events.Subscribe(func(e events.Event) {
+		mu.Lock()
+		published = append(published, e)
+		mu.Unlock()
+	})
+
+	return func() []events.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]events.Event(nil), published...)
+	}
+}
+
+// connectTestPeer connects a new peer through a fresh connection request
+func connectTestPeer(t *testing.T, s *server) *signing.PublicKey {
+	t.Helper()
+
+	request, err := s.newConnectionRequest(invitation.Options{Creator: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inbox, err := signing.FromAddress(request.invitation.InboxAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := newTestAddress(t)
+	if !s.acceptConnection(inbox, peer, []byte("welcome")) {
+		t.Fatal("connection was not accepted")
+	}
+
+	return peer
+}
+
+func TestNewConnectionRequest(t *testing.T) {
+	s, fake := newTestServer(t)
+
+	request, err := s.newConnectionRequest(invitation.Options{Creator: "test", Metadata: map[string]string{"campaign": "spring"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := s.invitations.Get(request.invitation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.State != invitation.StateIssued || inv.Metadata["campaign"] != "spring" {
+		t.Errorf("invitation = %+v", inv)
+	}
+
+	open, _ := fake.InboxList()
+	if len(open) != 2 || open[1].String() != inv.InboxAddress {
+		t.Errorf("open inboxes = %v, want the primary inbox and %s", open, inv.InboxAddress)
+	}
+}
+
+func TestAcceptConnection(t *testing.T) {
+	s, fake := newTestServer(t)
+	published := recordEvents(s)
+
+	peer := connectTestPeer(t, s)
+
+	contact, err := s.contacts.Get(context.Background(), peer.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connections := fake.Connections()
+	if len(connections) != 1 || contact.ConnectionAddress != connections[0].GroupAddress.String() {
+		t.Errorf("contact = %+v, connections = %+v", contact, connections)
+	}
+
+	inv, err := s.invitations.Get(contact.InvitationID)
+	if err != nil || inv.State != invitation.StateConnected || inv.PeerAddress != peer.String() {
+		t.Errorf("invitation = %+v, %v", inv, err)
+	}
+
+	if e := published(); len(e) != 1 || e[0].Type != events.ConnectionEstablished {
+		t.Errorf("events = %+v", e)
+	}
+}
+
+func TestSendCredentialRequest(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	req, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 || !sent[0].To.Matches(peer) {
+		t.Fatalf("sent = %+v", sent)
+	}
+
+	tracked, err := s.requests.Get(req.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked.Kind != pending.KindCredentialPresentation || tracked.Subject != "email" || tracked.PeerAddress != peer.String() {
+		t.Errorf("request = %+v", tracked)
+	}
+
+	_, err = s.sendCredentialRequest(peer, "no-such-definition", pending.OriginChat)
+	if err == nil {
+		t.Error("unknown definitions should not be requested")
+	}
+}
+
+func TestSendCredentialRequestUntrusted(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+	distrustTestServer(t, s)
+
+	_, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+	if !errors.Is(err, errUntrustedDefinition) {
+		t.Errorf("request without trusted issuers = %v, want %v", err, errUntrustedDefinition)
+	}
+
+	if sent := fake.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d messages", len(sent))
+	}
+
+	// definitions with their own issuers are still requested
+	_, err = s.sendCredentialRequest(peer, "custom", pending.OriginChat)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIssueCredential(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	id, err := s.issueCredential(peer, "CustomerCredential", map[string]any{"tier": "gold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 || id == "" {
+		t.Errorf("id = %q, sent = %+v", id, sent)
+	}
+}
+
+func TestSendDocumentSigningRequest(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	req, err := s.sendDocumentSigningRequest(peer, "contract-42", nil, pending.OriginChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Uploaded()) != 1 || len(fake.Sent()) != 1 {
+		t.Errorf("uploaded %d objects and sent %d messages, want 1 of each", len(fake.Uploaded()), len(fake.Sent()))
+	}
+
+	if req.Kind != pending.KindCredentialVerification {
+		t.Errorf("request kind = %s", req.Kind)
+	}
+}
+
+func TestVerificationCompleted(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	req, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.requests.Resolve(req.ID, pending.KindCredentialPresentation, peer.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.verificationCompleted(peer, &verification.Result{
+		PeerAddress: peer.String(),
+		RequestID:   req.ID,
+		Definition:  "email",
+		Decision:    verification.DecisionAccepted,
+		Presentations: []verification.Presentation{{
+			Valid: true,
+			Credentials: []verification.Credential{{
+				Claims: map[string]any{"emailAddress": "ada@example.com"},
+				Valid:  true,
+			}},
+		}},
+		VerifiedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed, err := s.requests.Get(req.ID)
+	if err != nil || completed.Outcome != string(verification.DecisionAccepted) {
+		t.Errorf("request = %+v, %v", completed, err)
+	}
+
+	contact, err := s.contacts.Get(context.Background(), peer.String())
+	if err != nil || contact.Facts["email"].Value != "ada@example.com" {
+		t.Errorf("contact = %+v, %v", contact, err)
+	}
+
+	// the request and the reply
+	if sent := fake.Sent(); len(sent) != 2 {
+		t.Errorf("sent %d messages, want 2", len(sent))
+	}
+}
+
+func TestExpireRequests(t *testing.T) {
+	s, fake := newTestServer(t)
+	peer := connectTestPeer(t, s)
+
+	chat, err := s.sendCredentialRequest(peer, "email", pending.OriginChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := s.sendCredentialRequest(peer, "email", pending.OriginAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var expired []string
+	unsubscribe := s.events.Subscribe(func(e events.Event) {
+		if req, ok := e.Data.(pending.Request); ok && e.Type == events.RequestExpired {
+			mu.Lock()
+			expired = append(expired, req.ID)
+			mu.Unlock()
+		}
+	})
+	defer unsubscribe()
+
+	sent := len(fake.Sent())
+	s.expirePending(time.Now().Add(24 * time.Hour))
+
+	// only the peer who asked in chat is told
+	notices := fake.Sent()[sent:]
+	if len(notices) != 1 || !notices[0].To.Matches(peer) {
+		t.Errorf("sent %d expiry notices, want 1 to the peer", len(notices))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 2 {
+		t.Errorf("published expiry of %v, want %s and %s", expired, chat.ID, api.ID)
+	}
+
+	if req, err := s.requests.Get(api.ID); err != nil || req.Origin != pending.OriginAPI || req.State != pending.StateExpired {
+		t.Errorf("API request = %+v, %v", req, err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/webhook"
+)
+
+// metrics are served to API clients at /debug/vars. They are kept out of the
+// global expvar registry, which also publishes the command line and with it
+// any token passed as a flag.
+var metrics = new(expvar.Map)
+
+var (
+	connectionsEstablished = new(expvar.Int)
+	connectionFailures     = new(expvar.Map)
+	handlerFailures        = new(expvar.Map)
+	requestsExpired        = new(expvar.Int)
+	responsesRejected      = new(expvar.Map)
+)
+
+func init() {
+	metrics.Set("connections_established", connectionsEstablished)
+	metrics.Set("connection_failures", connectionFailures)
+	metrics.Set("handler_failures", handlerFailures)
+	metrics.Set("requests_expired", requestsExpired)
+	metrics.Set("responses_rejected", responsesRejected)
+	metrics.Set("webhook_deliveries", webhook.Deliveries)
+}
+
+// handleMetrics writes the server's metrics as a JSON object
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(metrics.String()))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+	"github.com/joinself/self-sdk-examples/golang/internal/oidc"
+)
+
+// oidcAuthenticator logs users in to OpenID Connect clients by
+// authenticating them with the credentials of a login's scopes
+type oidcAuthenticator struct {
+	s        *server
+	provider *oidc.Provider
+}
+
+// Authenticate asks whoever connects with a new connection request for their
+// credentials. Connected peers are never asked directly, as anyone could
+// start a login naming them.
+func (a *oidcAuthenticator) Authenticate(login oidc.Login) (oidc.Challenge, error) {
+	opts := invitation.Options{
+		Creator: "oidc",
+		Metadata: map[string]string{
+			"client_id": login.ClientID,
+			"login":     login.ID,
+		},
+	}
+
+	request, err := a.s.authenticate(nil, login.Definitions, opts, func(auth authentication) {
+		switch auth.State {
+		case authConnected:
+			a.provider.Connected(login.ID)
+		case authVerified:
+			a.provider.Verified(login.ID, auth.PeerAddress, auth.Credentials)
+		case authRejected:
+			a.provider.Failed(login.ID, auth.Reason)
+		}
+	})
+	if err != nil {
+		return oidc.Challenge{}, err
+	}
+
+	qrCode, err := encodeQRPNG(request.message)
+	if err != nil {
+		return oidc.Challenge{}, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	link, err := request.link(a.s.config.Links.BaseURL)
+	if err != nil {
+		return oidc.Challenge{}, fmt.Errorf("failed to build link: %w", err)
+	}
+
+	return oidc.Challenge{QRCode: qrCode, Link: link}, nil
+}
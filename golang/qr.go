@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/joinself/self-go-sdk/event"
+	"github.com/skip2/go-qrcode"
+)
+
+// qrModuleSize is how many pixels wide each module of a PNG QR code is
+const qrModuleSize = 8
+
+// encodeQRPNG draws the encoded anonymous message as a QR code PNG, with the
+// quiet zone around it. The SDK only draws QR codes as svg or text, so the
+// PNG encodes the same bytes the SDK's QR codes and links carry.
+func encodeQRPNG(msg *event.AnonymousMessage) ([]byte, error) {
+	encoded, err := msg.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	qr, err := qrcode.New(string(encoded), qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+
+	return qr.PNG(-qrModuleSize)
+}
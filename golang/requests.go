@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+)
+
+// sendRequest sends a request to a peer and tracks it until it is answered.
+// The request's ID and peer are filled in from the content and peer, and the
+// tracked request is returned.
+func (s *server) sendRequest(peer *signing.PublicKey, content *message.Content, req pending.Request) (pending.Request, error) {
+	req.ID = hex.EncodeToString(content.ID())
+	req.PeerAddress = peer.String()
+
+	// track before sending, the response can arrive before MessageSend returns
+	tracked, err := s.requests.Track(req)
+	if err != nil {
+		return pending.Request{}, err
+	}
+
+	err = s.account.MessageSend(peer, content)
+	if err != nil {
+		s.requests.Forget(req.ID)
+		return pending.Request{}, err
+	}
+
+	return tracked, nil
+}
+
+// completeRequest records what came of the response to a request, for
+// whoever polls it
+func (s *server) completeRequest(id string, outcome string, result any) {
+	err := s.requests.Complete(id, outcome, result)
+	if err != nil {
+		log.Printf("Failed to record the outcome of request %s: %v", id, err)
+	}
+}
+
+// resolveRequest matches a response from a peer to the request it answers.
+// Responses that do not answer a pending request are counted by why they
+// were rejected.
+func (s *server) resolveRequest(responseTo []byte, kind pending.Kind, peer *signing.PublicKey) (pending.Request, error) {
+	req, err := s.requests.Resolve(hex.EncodeToString(responseTo), kind, peer.String())
+
+	switch {
+	case err == nil:
+		return req, nil
+	case errors.Is(err, pending.ErrDuplicate):
+		responsesRejected.Add("duplicate", 1)
+	case errors.Is(err, pending.ErrLate):
+		responsesRejected.Add("late", 1)
+	default:
+		responsesRejected.Add("unsolicited", 1)
+	}
+
+	return req, err
+}
+
+// expireRequests periodically expires requests that were never answered
+func (s *server) expireRequests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.expirePending(now)
+	}
+}
+
+// expirePending expires the requests that were not answered by now. Peers are told
+// about the ones they asked for in chat; whoever started the others learns
+// from the request.expired event.
+func (s *server) expirePending(now time.Time) {
+	for _, req := range s.requests.Expire(now) {
+		requestsExpired.Add(1)
+		log.Printf("Request %s for %s sent to %s timed out", req.ID, req.Subject, req.PeerAddress)
+		s.events.Publish(events.RequestExpired, req)
+
+		if req.Origin != pending.OriginChat {
+			continue
+		}
+
+		peer, err := signing.FromAddress(req.PeerAddress)
+		if err != nil {
+			continue
+		}
+
+		err = s.sendChat(peer, fmt.Sprintf("Your %s request has expired. Send %s to start again.", s.requestNoun(req), s.config.Chat.Commands.Help))
+		if err != nil {
+			log.Printf("Failed to tell %s about expired request %s: %v", req.PeerAddress, req.ID, err)
+		}
+	}
+}
+
+// requestNoun describes a request to the peer it was sent to
+func (s *server) requestNoun(req pending.Request) string {
+	if req.Kind == pending.KindCredentialVerification {
+		return "signing"
+	}
+
+	definition, ok := s.definitions[req.Subject]
+	if !ok {
+		return req.Subject
+	}
+
+	return definition.Description
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/invitation"
+)
+
+const (
+	// sessionDefinition is the credential a session asks its peer for, the
+	// same liveness check as the auth chat command
+	sessionDefinition = "liveness"
+	// sessionLifetime is how long a session can be looked up after it was
+	// started, long after its connection and credential requests expire
+	sessionLifetime = time.Hour
+	// sessionHeartbeat is how often an idle event stream is kept alive
+	sessionHeartbeat = 15 * time.Second
+)
+
+// session binds a browser to the authentication of whoever connects with
+// the connection request it was handed. The session ID is a bearer secret:
+// anyone holding it can read the session, and a verified session names the
+// peer that verified, so it must only be handed to the browser that started
+// it and the backend that serves that browser.
+type session struct {
+	ID           string    `json:"session_id"`
+	InvitationID string    `json:"invitation_id"`
+	State        authState `json:"state"`
+	// PeerAddress is the peer that verified, set once the session is
+	// verified.
+	PeerAddress string     `json:"peer_address,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func (s session) finished() bool {
+	return s.State == authVerified || s.State == authRejected
+}
+
+// sessionStore holds the sessions started in the last sessionLifetime and
+// the event streams following them
+type sessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*session
+	subscribers map[string]map[chan session]struct{}
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions:    make(map[string]*session),
+		subscribers: make(map[string]map[chan session]struct{}),
+	}
+}
+
+func (st *sessionStore) start(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sessions[id] = &session{ID: id, State: authPending, CreatedAt: time.Now()}
+}
+
+// bind records the connection request handed out for a session
+func (st *sessionStore) bind(id, invitationID string, expiresAt time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[id]
+	if ok {
+		sess.InvitationID = invitationID
+		sess.ExpiresAt = expiresAt
+	}
+}
+
+func (st *sessionStore) forget(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.remove(id)
+}
+
+// remove forgets a session and ends its event streams. The caller must hold
+// st.mu.
+func (st *sessionStore) remove(id string) {
+	delete(st.sessions, id)
+
+	for updates := range st.subscribers[id] {
+		close(updates)
+	}
+	delete(st.subscribers, id)
+}
+
+func (st *sessionStore) get(id string) (session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[id]
+	if !ok {
+		return session{}, false
+	}
+	return *sess, true
+}
+
+// subscribe returns the session as it is now and a channel of its later
+// states. The channel has room for every state a session can move through,
+// so updates are never dropped, and is closed when the session is
+// forgotten.
+func (st *sessionStore) subscribe(id string) (session, <-chan session, func(), bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[id]
+	if !ok {
+		return session{}, nil, nil, false
+	}
+
+	updates := make(chan session, 4)
+	if st.subscribers[id] == nil {
+		st.subscribers[id] = make(map[chan session]struct{})
+	}
+	st.subscribers[id][updates] = struct{}{}
+
+	unsubscribe := func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+
+		// forgotten sessions have no subscribers left
+		if _, ok := st.subscribers[id][updates]; !ok {
+			return
+		}
+
+		delete(st.subscribers[id], updates)
+		if len(st.subscribers[id]) == 0 {
+			delete(st.subscribers, id)
+		}
+	}
+
+	return *sess, updates, unsubscribe, true
+}
+
+// update records an authentication's new state against its session and
+// tells the session's event streams
+func (st *sessionStore) update(id string, auth authentication) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[id]
+	if !ok || sess.finished() {
+		return
+	}
+
+	sess.State = auth.State
+	sess.Reason = auth.Reason
+	if sess.State == authVerified {
+		sess.PeerAddress = auth.PeerAddress
+	}
+	if sess.finished() {
+		now := time.Now()
+		sess.FinishedAt = &now
+	}
+
+	for updates := range st.subscribers[id] {
+		select {
+		case updates <- *sess:
+		default:
+		}
+	}
+}
+
+// expire forgets sessions older than sessionLifetime, ending their event
+// streams
+func (st *sessionStore) expire(now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for id, sess := range st.sessions {
+		if now.Sub(sess.CreatedAt) > sessionLifetime {
+			st.remove(id)
+		}
+	}
+}
+
+// run expires sessions every interval
+func (st *sessionStore) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		st.expire(now)
+	}
+}
+
+// sessionResponse is returned when a session is started
+type sessionResponse struct {
+	SessionID    string    `json:"session_id"`
+	InvitationID string    `json:"invitation_id"`
+	QRCode       string    `json:"qr_code"`
+	Link         string    `json:"link,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	EventsURL    string    `json:"events_url"`
+}
+
+// handleCreateSession starts a session for a browser: it mints a connection
+// request bound to the session and returns it as a base64 PNG QR code and an
+// app link. Whoever connects with it is sent the liveness request at once.
+// The session ID in the response is all it takes to read the session.
+func (s *server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	b, err := generateRandomBytes(16)
+	if err != nil {
+		log.Printf("handleCreateSession: Failed to generate session ID: %v", err)
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(b)
+
+	// record the session first, the peer can connect as soon as the request
+	// exists
+	s.sessions.start(id)
+
+	opts := invitation.Options{
+		Creator:  "session",
+		Metadata: map[string]string{"session": id},
+	}
+
+	request, err := s.authenticate(nil, []string{sessionDefinition}, opts, func(auth authentication) {
+		s.sessions.update(id, auth)
+	})
+	if errors.Is(err, errUntrustedDefinition) {
+		s.sessions.forget(id)
+		http.Error(w, "sessions are unavailable: no issuer is trusted for "+sessionDefinition+" credentials", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		s.sessions.forget(id)
+		log.Printf("handleCreateSession: %v", err)
+		http.Error(w, "failed to create connection request", http.StatusInternalServerError)
+		return
+	}
+
+	qrCode, err := encodeQRPNG(request.message)
+	if err != nil {
+		s.sessions.forget(id)
+		log.Printf("handleCreateSession: Failed to encode QR code: %v", err)
+		http.Error(w, "failed to encode connection request", http.StatusInternalServerError)
+		return
+	}
+
+	link, err := request.link(s.config.Links.BaseURL)
+	if err != nil {
+		s.sessions.forget(id)
+		log.Printf("handleCreateSession: Failed to build link: %v", err)
+		http.Error(w, "failed to encode connection request", http.StatusInternalServerError)
+		return
+	}
+
+	s.sessions.bind(id, request.invitation.ID, request.expiresAt)
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", "/sessions/"+id)
+	writeJSON(w, http.StatusCreated, sessionResponse{
+		SessionID:    id,
+		InvitationID: request.invitation.ID,
+		QRCode:       base64.StdEncoding.EncodeToString(qrCode),
+		Link:         link,
+		ExpiresAt:    request.expiresAt,
+		EventsURL:    "/sessions/" + id + "/events",
+	})
+}
+
+// handleGetSession returns a session's state, for browsers that poll
+func (s *server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.sessions.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, sess)
+}
+
+// handleSessionEvents streams a session's state as Server-Sent Events: a
+// state event with the session as it is now, then one per change, ending
+// once the session is verified or rejected
+func (s *server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sess, updates, unsubscribe, ok := s.sessions.subscribe(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(sessionHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		err := writeSessionEvent(w, sess)
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+
+		if sess.finished() {
+			return
+		}
+
+	wait:
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case update, ok := <-updates:
+				if !ok {
+					// the session was forgotten
+					return
+				}
+				sess = update
+				break wait
+			case <-heartbeat.C:
+				_, err := fmt.Fprint(w, ": keep-alive\n\n")
+				if err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeSessionEvent(w http.ResponseWriter, sess session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: state\ndata: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/ratelimit"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// startTestSession starts a session through the HTTP endpoint
+func startTestSession(t *testing.T, s *server) sessionResponse {
+	t.Helper()
+
+	w := serveTestRequest(s, http.MethodPost, "/sessions", "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /sessions = %d: %s", w.Code, w.Body)
+	}
+
+	var started sessionResponse
+	err := json.Unmarshal(w.Body.Bytes(), &started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return started
+}
+
+// connectSessionPeer connects a new peer with a session's connection
+// request, and returns it with the liveness request it is sent
+func connectSessionPeer(t *testing.T, s *server, started sessionResponse) (*signing.PublicKey, pending.Request) {
+	t.Helper()
+
+	inv, err := s.invitations.Get(started.InvitationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inbox, err := signing.FromAddress(inv.InboxAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := newTestAddress(t)
+	if !s.acceptConnection(inbox, peer, []byte("welcome")) {
+		t.Fatal("connection was not accepted")
+	}
+
+	// the request is sent in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, req := range s.requests.List() {
+			if req.PeerAddress == peer.String() && req.Subject == sessionDefinition {
+				return peer, req
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("the liveness request was never sent")
+	return nil, pending.Request{}
+}
+
+// answerTestRequest completes a credential request with the given decision
+func answerTestRequest(t *testing.T, s *server, peer *signing.PublicKey, req pending.Request, decision verification.Decision) {
+	t.Helper()
+
+	_, err := s.requests.Resolve(req.ID, req.Kind, peer.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.verificationCompleted(peer, &verification.Result{
+		PeerAddress: peer.String(),
+		RequestID:   req.ID,
+		Definition:  req.Subject,
+		Decision:    decision,
+		Presentations: []verification.Presentation{{
+			Valid: decision == verification.DecisionAccepted,
+			Credentials: []verification.Credential{{
+				Claims: map[string]any{"sourceImageHash": "hash"},
+				Valid:  decision == verification.DecisionAccepted,
+			}},
+		}},
+		VerifiedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getTestSession(t *testing.T, s *server, id string) (session, string) {
+	t.Helper()
+
+	w := serveTestRequest(s, http.MethodGet, "/sessions/"+id, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /sessions/%s = %d", id, w.Code)
+	}
+
+	var sess session
+	err := json.Unmarshal(w.Body.Bytes(), &sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess, w.Body.String()
+}
+
+func TestSessionVerified(t *testing.T) {
+	s, _ := newTestServer(t)
+	started := startTestSession(t, s)
+
+	if started.QRCode == "" || started.EventsURL != "/sessions/"+started.SessionID+"/events" {
+		t.Errorf("session = %+v", started)
+	}
+
+	sess, _ := getTestSession(t, s, started.SessionID)
+	if sess.State != authPending || sess.InvitationID != started.InvitationID {
+		t.Errorf("new session = %+v", sess)
+	}
+
+	peer, req := connectSessionPeer(t, s, started)
+
+	sess, body := getTestSession(t, s, started.SessionID)
+	if sess.State != authConnected {
+		t.Errorf("connected session = %+v", sess)
+	}
+	if strings.Contains(body, peer.String()) {
+		t.Errorf("unverified session names its peer: %s", body)
+	}
+
+	answerTestRequest(t, s, peer, req, verification.DecisionAccepted)
+
+	// the verified peer is named, so backends need no separate lookup
+	sess, _ = getTestSession(t, s, started.SessionID)
+	if sess.State != authVerified || sess.FinishedAt == nil || sess.PeerAddress != peer.String() {
+		t.Errorf("verified session = %+v, want it verified by %s", sess, peer)
+	}
+}
+
+func TestSessionRejected(t *testing.T) {
+	s, _ := newTestServer(t)
+	started := startTestSession(t, s)
+
+	peer, req := connectSessionPeer(t, s, started)
+	answerTestRequest(t, s, peer, req, verification.DecisionRejected)
+
+	sess, body := getTestSession(t, s, started.SessionID)
+	if sess.State != authRejected || sess.Reason == "" {
+		t.Errorf("rejected session = %+v", sess)
+	}
+	if strings.Contains(body, peer.String()) {
+		t.Errorf("rejected session names its peer: %s", body)
+	}
+
+	// finished sessions stay finished
+	s.sessions.update(started.SessionID, authentication{State: authVerified})
+	if sess, _ := getTestSession(t, s, started.SessionID); sess.State != authRejected {
+		t.Errorf("session after a late update = %+v", sess)
+	}
+}
+
+func TestSessionNotFound(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	for _, path := range []string{"/sessions/unknown", "/sessions/unknown/events"} {
+		if w := serveTestRequest(s, http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestSessionUntrusted(t *testing.T) {
+	s, _ := newTestServer(t)
+	distrustTestServer(t, s)
+
+	if w := serveTestRequest(s, http.MethodPost, "/sessions", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /sessions without trusted issuers = %d, want 503", w.Code)
+	}
+
+	if n := len(s.invitations.List()); n != 0 {
+		t.Errorf("issued %d invitations", n)
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.httpLimiter = ratelimit.New(1, time.Minute)
+
+	startTestSession(t, s)
+
+	if w := serveTestRequest(s, http.MethodPost, "/sessions", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("POST /sessions over the limit = %d, want 429", w.Code)
+	}
+}
+
+// readSessionEvents reads the state events of a stream until it ends
+func readSessionEvents(t *testing.T, scanner *bufio.Scanner, n int) []session {
+	t.Helper()
+
+	var states []session
+	for len(states) < n && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var sess session
+		err := json.Unmarshal([]byte(data), &sess)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, sess)
+	}
+
+	return states
+}
+
+func TestSessionEvents(t *testing.T) {
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(s.newHTTPHandler())
+	defer srv.Close()
+
+	started := startTestSession(t, s)
+
+	resp, err := http.Get(srv.URL + started.EventsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	if states := readSessionEvents(t, scanner, 1); len(states) != 1 || states[0].State != authPending {
+		t.Fatalf("first event = %+v", states)
+	}
+
+	peer, req := connectSessionPeer(t, s, started)
+	answerTestRequest(t, s, peer, req, verification.DecisionAccepted)
+
+	states := readSessionEvents(t, scanner, 3)
+	if len(states) != 2 || states[0].State != authConnected || states[1].State != authVerified {
+		t.Errorf("events = %+v, want connected then verified and the end of the stream", states)
+	}
+}
+
+func TestSessionExpiryEndsStreams(t *testing.T) {
+	st := newSessionStore()
+	st.start("session")
+
+	_, updates, unsubscribe, ok := st.subscribe("session")
+	if !ok {
+		t.Fatal("session not found")
+	}
+	defer unsubscribe()
+
+	st.expire(time.Now())
+	if _, ok := st.get("session"); !ok {
+		t.Fatal("a new session was forgotten")
+	}
+
+	st.expire(time.Now().Add(2 * sessionLifetime))
+	if _, ok := st.get("session"); ok {
+		t.Error("an old session was kept")
+	}
+
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Error("received an update instead of the end of the stream")
+		}
+	default:
+		t.Error("the stream of a forgotten session was left open")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+)
+
+const (
+	storageKeySize = 32
+	storageKeyEnv  = "SELF_STORAGE_KEY"
+)
+
+// prepareStore returns the self-store directory and the key that encrypts
+// it, and a cleanup function to call once the store is closed. An ephemeral
+// store is a new temporary directory with a throwaway key, removed by
+// cleanup, so nothing survives a restart. A persistent store must have been
+// created for env, and is opened with its storage key.
+func prepareStore(store config.Store, env environment) (string, []byte, func(), error) {
+	if !store.Ephemeral {
+		err := checkStoreEnvironment(store.Path, env)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		key, err := loadStorageKey(store.Path, store.KeyFile)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to load storage key: %w", err)
+		}
+
+		return store.Path, key, func() {}, nil
+	}
+
+	storagePath, err := os.MkdirTemp("", "self-store-")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create ephemeral self-store: %w", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(storagePath)
+	}
+
+	key, err := generateRandomBytes(storageKeySize)
+	if err != nil {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("failed to generate storage key: %w", err)
+	}
+
+	return storagePath, key, cleanup, nil
+}
+
+// loadStorageKey returns the key used to encrypt the self-store. The key is
+// taken from the SELF_STORAGE_KEY environment variable or the key file, in
+// that order. On first run, when neither exists and the store is empty, a new
+// key is generated and written to the key file. The key cannot be rotated:
+// the SDK has no way to re-encrypt a store under a new key.
+func loadStorageKey(storePath, keyPath string) ([]byte, error) {
+	if encoded, ok := os.LookupEnv(storageKeyEnv); ok {
+		key, err := decodeStorageKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", storageKeyEnv, err)
+		}
+		return key, nil
+	}
+
+	encoded, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, err := decodeStorageKey(string(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage key file %s: %w", keyPath, err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read storage key file %s: %w", keyPath, err)
+	}
+
+	// never generate a key for an existing store, it would not be able to open it
+	empty, err := isEmptyDir(storePath)
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, fmt.Errorf("%s exists but no storage key was found in %s or %s", storePath, storageKeyEnv, keyPath)
+	}
+
+	key, err := generateRandomBytes(storageKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate storage key: %w", err)
+	}
+
+	err = writeStorageKey(keyPath, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// writeStorageKey writes a hex encoded storage key to a new file that only
+// the current user can read. It fails if the file already exists.
+func writeStorageKey(keyPath string, key []byte) error {
+	f, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create storage key file %s: %w", keyPath, err)
+	}
+
+	_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write storage key file %s: %w", keyPath, err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync storage key file %s: %w", keyPath, err)
+	}
+
+	return f.Close()
+}
+
+func decodeStorageKey(encoded string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("storage key must be hex encoded: %w", err)
+	}
+
+	if len(key) != storageKeySize {
+		return nil, fmt.Errorf("storage key must be %d bytes, got %d", storageKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+func isEmptyDir(path string) (bool, error) {
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return len(entries) == 0, nil
+}
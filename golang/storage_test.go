@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+)
+
+// unsetStorageKeyEnv hides any storage key in the environment for the rest
+// of the test
+func unsetStorageKeyEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv(storageKeyEnv, "")
+	os.Unsetenv(storageKeyEnv)
+}
+
+func TestLoadStorageKeyFirstRun(t *testing.T) {
+	unsetStorageKeyEnv(t)
+
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "self-store")
+	keyPath := filepath.Join(dir, "self-store.key")
+
+	key, err := loadStorageKey(storePath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != storageKeySize {
+		t.Fatalf("generated a %d byte key, want %d", len(key), storageKeySize)
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file permissions = %v, want 0600", perm)
+	}
+
+	encoded, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != hex.EncodeToString(key)+"\n" {
+		t.Errorf("key file = %q, want the hex encoded key", encoded)
+	}
+
+	// later runs load the same key
+	again, err := loadStorageKey(storePath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, key) {
+		t.Error("the key file was not reused")
+	}
+}
+
+func TestLoadStorageKeyMalformed(t *testing.T) {
+	unsetStorageKeyEnv(t)
+
+	for _, content := range []string{
+		"not hex",
+		hex.EncodeToString(make([]byte, 16)),
+		hex.EncodeToString(make([]byte, storageKeySize)) + "00",
+		"",
+	} {
+		dir := t.TempDir()
+		keyPath := filepath.Join(dir, "self-store.key")
+
+		err := os.WriteFile(keyPath, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = loadStorageKey(filepath.Join(dir, "self-store"), keyPath)
+		if err == nil || !strings.Contains(err.Error(), "invalid storage key file") {
+			t.Errorf("key file %q = %v, want it refused", content, err)
+		}
+	}
+}
+
+func TestLoadStorageKeyEnv(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "self-store.key")
+
+	err := writeStorageKey(keyPath, bytes.Repeat([]byte{1}, storageKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment takes precedence over the key file
+	want := bytes.Repeat([]byte{2}, storageKeySize)
+	t.Setenv(storageKeyEnv, " "+hex.EncodeToString(want)+"\n")
+
+	key, err := loadStorageKey(filepath.Join(dir, "self-store"), keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, want) {
+		t.Error("the key was not taken from the environment")
+	}
+
+	t.Setenv(storageKeyEnv, "not hex")
+
+	_, err = loadStorageKey(filepath.Join(dir, "self-store"), keyPath)
+	if err == nil || !strings.Contains(err.Error(), storageKeyEnv) {
+		t.Errorf("malformed %s = %v, want it refused", storageKeyEnv, err)
+	}
+}
+
+func TestLoadStorageKeyExistingStore(t *testing.T) {
+	unsetStorageKeyEnv(t)
+
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "self-store")
+	keyPath := filepath.Join(dir, "self-store.key")
+
+	err := os.MkdirAll(storePath, 0700)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(storePath, "account.db"), []byte("data"), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new key could never open the store
+	_, err = loadStorageKey(storePath, keyPath)
+	if err == nil {
+		t.Error("a key was generated for an existing store")
+	}
+
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Errorf("key file was written: %v", err)
+	}
+}
+
+func TestWriteStorageKeyExisting(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "self-store.key")
+
+	err := writeStorageKey(keyPath, make([]byte, storageKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeStorageKey(keyPath, bytes.Repeat([]byte{1}, storageKeySize))
+	if err == nil {
+		t.Error("an existing key file was overwritten")
+	}
+}
+
+func TestPrepareStoreEphemeral(t *testing.T) {
+	unsetStorageKeyEnv(t)
+
+	dir := t.TempDir()
+	store := config.Store{
+		Path:      filepath.Join(dir, "self-store"),
+		KeyFile:   filepath.Join(dir, "self-store.key"),
+		Ephemeral: true,
+	}
+
+	path, key, cleanup, err := prepareStore(store, environmentProduction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPath, otherKey, otherCleanup, err := prepareStore(store, environmentProduction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCleanup()
+
+	if path == store.Path || path == otherPath || len(key) != storageKeySize || bytes.Equal(key, otherKey) {
+		t.Errorf("ephemeral stores share %s or their key", path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("ephemeral store was not created: %v", err)
+	}
+
+	cleanup()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("ephemeral store survived cleanup: %v", err)
+	}
+
+	// the configured store and key file are left alone
+	for _, p := range []string{store.Path, store.KeyFile} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("ephemeral mode created %s", p)
+		}
+	}
+}
+
+func TestPrepareStorePersistent(t *testing.T) {
+	unsetStorageKeyEnv(t)
+
+	dir := t.TempDir()
+	store := config.Store{
+		Path:    filepath.Join(dir, "self-store"),
+		KeyFile: filepath.Join(dir, "self-store.key"),
+	}
+
+	path, key, cleanup, err := prepareStore(store, environmentSandbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+
+	if path != store.Path || len(key) != storageKeySize {
+		t.Errorf("persistent store = %s with a %d byte key", path, len(key))
+	}
+
+	err = os.MkdirAll(store.Path, 0700)
+	if err == nil {
+		err = recordStoreEnvironment(store.Path, environmentSandbox)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := prepareStore(store, environmentProduction); err == nil {
+		t.Error("a sandbox store was opened in production")
+	}
+}
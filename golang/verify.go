@@ -0,0 +1,181 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/joinself/self-go-sdk/credential"
+	"github.com/joinself/self-go-sdk/keypair/signing"
+	"github.com/joinself/self-go-sdk/message"
+	"github.com/joinself/self-sdk-examples/golang/internal/events"
+	"github.com/joinself/self-sdk-examples/golang/internal/pending"
+	"github.com/joinself/self-sdk-examples/golang/internal/validity"
+	"github.com/joinself/self-sdk-examples/golang/internal/verification"
+)
+
+// verifyPresentationResponse checks every presentation and credential in a
+// peer's response to a credential request
+func (s *server) verifyPresentationResponse(peer *signing.PublicKey, req pending.Request, response *message.CredentialPresentationResponse) *verification.Result {
+	now := time.Now()
+
+	result := &verification.Result{
+		PeerAddress: peer.String(),
+		RequestID:   req.ID,
+		Definition:  req.Subject,
+		Status:      response.Status().String(),
+		VerifiedAt:  now,
+	}
+
+	accepted := response.Status() == message.ResponseStatusAccepted
+
+	if accepted {
+		for _, p := range response.Presentations() {
+			result.Presentations = append(result.Presentations, s.verifyPresentation(peer, req, p, now))
+		}
+	}
+
+	result.Decide(accepted)
+
+	return result
+}
+
+func (s *server) verifyPresentation(peer *signing.PublicKey, req pending.Request, p *credential.VerifiablePresentation, now time.Time) verification.Presentation {
+	presentation := verification.Presentation{
+		Types:  p.PresentationType(),
+		Holder: p.Holder().String(),
+		Valid:  true,
+	}
+
+	err := p.Validate()
+	if err != nil {
+		presentation.Reject(verification.CodePresentationInvalid, "%v", err)
+		return presentation
+	}
+
+	// only credentials held by the peer that sent them count
+	if !p.Holder().Address().Matches(peer) {
+		presentation.Reject(verification.CodeHolderMismatch, "presentation is held by %s", p.Holder().Address())
+		return presentation
+	}
+
+	for _, c := range p.Credentials() {
+		presentation.Credentials = append(presentation.Credentials, s.verifyCredential(peer, req, c, now))
+	}
+
+	return presentation
+}
+
+func (s *server) verifyCredential(peer *signing.PublicKey, req pending.Request, c *credential.VerifiableCredential, now time.Time) verification.Credential {
+	issuer := c.Issuer().Address()
+
+	result := verification.Credential{
+		Types:      c.CredentialType(),
+		Issuer:     issuer.String(),
+		ValidFrom:  c.ValidFrom(),
+		ValidUntil: c.ValidUntil(),
+		Valid:      true,
+	}
+
+	err := c.Validate()
+	if err != nil {
+		result.Reject(verification.CodeCredentialInvalid, "%v", err)
+		return result
+	}
+
+	reason, trusted := s.trust.Check(req.Subject, result.Types, issuer, peer)
+	if !trusted {
+		result.Valid = false
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	window := validity.Window{ValidFrom: c.ValidFrom(), ValidUntil: c.ValidUntil()}
+	for _, reason := range validity.Check(s.validityRules(req.Subject), window, now) {
+		result.Valid = false
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	claims, err := c.CredentialSubjectClaims()
+	if err != nil {
+		result.Reject(verification.CodeClaimsUnreadable, "%v", err)
+		return result
+	}
+
+	result.Claims = claims
+
+	// the credential must be what was asked for, not just any valid one
+	if req.Conditions != nil && !req.Conditions.Evaluate(credentialDocument(result)) {
+		result.Reject(verification.CodeUnmatched, "does not satisfy %s", req.Conditions)
+	}
+
+	return result
+}
+
+// credentialDocument returns the JSON view of a credential that request
+// predicates are evaluated against
+func credentialDocument(c verification.Credential) map[string]any {
+	types := make([]any, 0, len(c.Types))
+	for _, t := range c.Types {
+		types = append(types, t)
+	}
+
+	doc := map[string]any{
+		"type":              types,
+		"issuer":            c.Issuer,
+		"validFrom":         c.ValidFrom.Format(time.RFC3339),
+		"credentialSubject": c.Claims,
+	}
+
+	if !c.ValidUntil.IsZero() {
+		doc["validUntil"] = c.ValidUntil.Format(time.RFC3339)
+	}
+
+	return doc
+}
+
+// validityRules returns the rules the validity window of a credential
+// presented for the named definition must pass
+func (s *server) validityRules(definition string) []validity.Rule {
+	skew := s.config.Credentials.ClockSkew
+
+	rules := []validity.Rule{
+		validity.NotBefore(skew),
+		validity.NotExpired(skew),
+	}
+
+	maxAge := s.config.Credentials.Definitions[definition].MaxAge
+	if maxAge > 0 {
+		rules = append(rules, validity.MaxAge(maxAge, skew))
+	}
+
+	return rules
+}
+
+// verificationCompleted acts on the result of verifying a credential
+// response: the verified facts are recorded and the peer is told the outcome
+func (s *server) verificationCompleted(peer *signing.PublicKey, result *verification.Result) error {
+	log.Printf("verificationCompleted: %s", result)
+	s.completeRequest(result.RequestID, string(result.Decision), result)
+	s.events.Publish(events.CredentialsVerified, result)
+
+	for _, c := range result.Accepted() {
+		s.recordFacts(peer, claimFacts(c.Claims, result.VerifiedAt))
+	}
+
+	var reply string
+
+	switch result.Decision {
+	case verification.DecisionAccepted:
+		reply = "Thank you, your credentials have been verified."
+	case verification.DecisionRejected:
+		reply = "You declined to share your credentials."
+	default:
+		reply = "Sorry, we could not verify the credentials you shared."
+	}
+
+	err := s.sendChat(peer, reply)
+	if err != nil {
+		return newFlowError("verificationCompleted", stageSend, "verification reply", "", err)
+	}
+
+	return nil
+}
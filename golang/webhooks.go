@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joinself/self-sdk-examples/golang/internal/config"
+	"github.com/joinself/self-sdk-examples/golang/internal/webhook"
+)
+
+// runWebhooksCommand lists the dead letters in the webhook queue, or replays
+// them so the running server delivers them again
+func runWebhooksCommand(cfg *config.Config, args []string) error {
+	if cfg.Webhooks.QueuePath == "" {
+		return fmt.Errorf("webhooks.queue_path is not set")
+	}
+
+	queue, err := webhook.OpenQueue(cfg.Webhooks.QueuePath)
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		dead, err := queue.Dead(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if len(dead) == 0 {
+			log.Println("No dead letters")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DELIVERY\tEVENT\tTYPE\tENDPOINT\tCREATED\tATTEMPTS\tLAST ERROR")
+		for _, d := range dead {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", d.ID, d.EventID, d.EventType, d.Endpoint, d.CreatedAt.Format(time.RFC3339), d.Attempts, d.LastError)
+		}
+		return w.Flush()
+	case "replay":
+		n, err := queue.Replay(context.Background(), args[1:]...)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Replaying %d deliveries", n)
+		return nil
+	default:
+		return fmt.Errorf("unknown webhooks command %q, expected list or replay [delivery...]", args[0])
+	}
+}